@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/repository"
+	"crawlers/pkg/service"
+	"crawlers/pkg/stream"
+	"encoding/json"
+	"fmt"
+	"github.com/jeven2016/mylibs/system"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// newConfigCmd the subcommands checking the config without starting the web server
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "check the config files",
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:           "validate",
+		Short:         "validate the config and print the effective settings of the sites",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateConfig(cmd.Context())
+		},
+	}, &cobra.Command{
+		Use:   "diff",
+		Short: "compare the sites of the config files with the settings stored in mongodb",
+		Long: "compare the sites of the config files with the settings stored in mongodb.\n\n" +
+			"Redis isn't compared since it no longer holds the site settings: they're resolved from the defaults, " +
+			"the config files and mongodb only, redis merely notifies the other instances of the changes. " +
+			"The siteConfig:<name> keys written by the former versions only held the defaults and aren't read anymore.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffConfig(cmd.Context())
+		},
+	})
+	return configCmd
+}
+
+// validateConfig loads the embedded config and the config files, the problems are printed to stderr
+func validateConfig(ctx context.Context) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	fieldErrors, warnings := service.ConfigService.ValidateConfig(func(siteName string) bool {
+		return stream.GetSiteCrawler(siteName) != nil
+	})
+	printFieldErrors("warning: ", warnings)
+	if len(fieldErrors) > 0 {
+		printFieldErrors("", fieldErrors)
+		return fmt.Errorf("the config is invalid, %v problem(s) found", len(fieldErrors))
+	}
+
+	var sites []*dto.EffectiveSiteSettings
+	for _, site := range service.ConfigService.GetConfig().WebSites {
+		settings, err := service.ConfigService.GetEffectiveSiteConfig(ctx, site.Name)
+		if err != nil {
+			return err
+		}
+		settings.Settings = settings.Settings.Redacted()
+		sites = append(sites, settings)
+	}
+	data, err := json.MarshalIndent(sites, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printFieldErrors prints the problems to stderr sorted by their paths
+func printFieldErrors(prefix string, fieldErrors base.FieldErrors) {
+	paths := make([]string, 0, len(fieldErrors))
+	for path := range fieldErrors {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		_, _ = fmt.Fprintf(os.Stderr, "%v%v: %v\n", prefix, path, fieldErrors[path])
+	}
+}
+
+// diffConfig prints the values differing between the config files and mongodb
+func diffConfig(ctx context.Context) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	sys := system.Startup(ctx, &system.StartupParams{
+		EnableMongodb: true,
+		Config:        service.ConfigService.GetConfig().GetServerConfig(),
+	})
+	if sys == nil {
+		return fmt.Errorf("unable to connect to mongodb")
+	}
+	defer system.Stop(ctx)
+
+	diffs, err := service.ConfigService.DiffSiteConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Println("no differences found")
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "SITE\tPATH\tFILE\tMONGODB")
+	for _, diff := range diffs {
+		_, _ = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", diff.SiteName, diff.Path, formatValue(diff.Path, diff.File),
+			formatValue(diff.Path, diff.Stored))
+	}
+	return writer.Flush()
+}
+
+func loadConfig() error {
+	repository.InitRepositories()
+	service.InitServices()
+	return service.ConfigService.LoadInternalConfig(configFile, extraConfigFile)
+}
+
+// formatValue formats the value in json, - is returned if it isn't set
+func formatValue(path string, value any) string {
+	if value == nil {
+		return "-"
+	}
+	if s, ok := value.(string); ok {
+		value = base.RedactValue(path[strings.LastIndex(path, ".")+1:], s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
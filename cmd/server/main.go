@@ -4,6 +4,8 @@ import (
 	"context"
 	"crawlers/pkg/api"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/proxy"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/repository"
 	"crawlers/pkg/service"
 	"crawlers/pkg/stream"
@@ -20,6 +22,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/text/language"
 	"net/http"
+	"os"
 )
 
 //go:embed internal_conf.yaml
@@ -39,6 +42,9 @@ var extraConfigFile *string
 // @termsOfService only for internal use
 // @BasePath /api/v1/
 // @query.collection.format multi
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-Api-Key
 func main() {
 	//printBanner()
 	run()
@@ -55,11 +61,13 @@ func run() {
 		},
 	}
 
-	// the absolute path of yaml config file
-	extraConfigFile = rootCmd.Flags().StringP(flagName, "c", "", "the absolute path of yaml config file")
+	// the absolute path of yaml config file, it's shared with the subcommands
+	extraConfigFile = rootCmd.PersistentFlags().StringP(flagName, "c", "", "the absolute path of yaml config file")
+	rootCmd.AddCommand(newConfigCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		utils.PrintCmdErr(err)
+		os.Exit(1)
 	}
 }
 
@@ -91,6 +99,18 @@ func runServer(server *http.Server) {
 	if sys != nil {
 		//ensure the indexes are created
 		repository.EnsureMongoIndexes(ctx)
+		if audit := service.ConfigService.GetConfig().Audit; audit != nil && audit.Enabled {
+			repository.EnsureAuditIndexes(ctx, audit.Retention())
+		}
+
+		//reload the config files and the site settings once they are changed
+		go service.ConfigService.WatchChanges(ctx)
+
+		//eject the proxies failing the health checks and put them back once they pass
+		go proxy.HealthCheck(ctx)
+
+		//fill the search tokens of the existing documents in background
+		go repository.SearchRepo.EnsureSearchTokens(ctx)
 
 		//global streams
 		if err := stream.LaunchGlobalSiteStream(ctx); err != nil {
@@ -175,6 +195,7 @@ func systemInit(cancelFunc context.CancelFunc, server *http.Server, ctx context.
 			return nil
 		},
 		PostShutdown: func() error {
+			render.Close()
 			if server != nil {
 				zap.S().Info("web server shuts down")
 				if err := server.Shutdown(ctx); err != nil {
@@ -0,0 +1,32 @@
+// Package apitest helps testing the handlers and the middlewares of the api
+package apitest
+
+import (
+	"encoding/json"
+	ginI18n "github.com/gin-contrib/i18n"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+)
+
+// Localize the i18n middleware loading the messages of cmd/server/i18n, the error results are built with them
+func Localize() gin.HandlerFunc {
+	_, file, _, _ := runtime.Caller(0)
+	return ginI18n.Localize(ginI18n.WithBundle(&ginI18n.BundleCfg{
+		DefaultLanguage:  language.Chinese,
+		FormatBundleFile: "json",
+		AcceptLanguage:   []language.Tag{language.Chinese},
+		RootPath:         filepath.Join(filepath.Dir(file), "..", "..", "..", "cmd", "server", "i18n"),
+		UnmarshalFunc:    json.Unmarshal,
+	}))
+}
+
+// Serve sends the request to the engine and returns the recorded response
+func Serve(engine http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// currentApiKey returns the authenticated api key, nil is returned if the authentication is disabled
+func currentApiKey(c *gin.Context) *entity.ApiKey {
+	value, _ := c.Get(base.ContextKeyApiKey)
+	apiKey, _ := value.(*entity.ApiKey)
+	return apiKey
+}
+
+// ensureSiteRole aborts with 403 if the api key isn't granted the role on the site, an empty site name
+// requires the role on all the sites
+func ensureSiteRole(c *gin.Context, siteName, role string) bool {
+	apiKey := currentApiKey(c)
+	if apiKey == nil || apiKey.HasSiteRole(siteName, role) {
+		return true
+	}
+	zap.L().Warn("permission denied", zap.String("apiKey", apiKey.Name), zap.String("siteName", siteName),
+		zap.String("role", role), zap.String("uri", c.Request.RequestURI))
+	name := role
+	if siteName != "" {
+		name = siteName + "/" + role
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden,
+		base.FailsWithParams(c, base.ErrorCode.Forbidden, map[string]string{"name": name}))
+	return false
+}
+
+// ensureSiteIdRole is similar to ensureSiteRole, the site is only retrieved if the role isn't granted on
+// all the sites
+func ensureSiteIdRole(c *gin.Context, siteId primitive.ObjectID, role string) bool {
+	apiKey := currentApiKey(c)
+	if apiKey == nil || apiKey.HasSiteRole("", role) {
+		return true
+	}
+	site, err := service.SiteService.FindById(c, siteId)
+	if err != nil {
+		zap.L().Warn("failed to find site", zap.String("siteId", siteId.Hex()), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return false
+	}
+	var siteName string
+	if site != nil {
+		siteName = site.Name
+	}
+	return ensureSiteRole(c, siteName, role)
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"crawlers/pkg/api/apitest"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the services embed the interfaces, the methods not overridden panic if they're called
+type fakeSiteService struct {
+	service.SiteServiceInterface
+	sites map[primitive.ObjectID]*entity.Site
+}
+
+func (f *fakeSiteService) FindById(_ *gin.Context, id primitive.ObjectID) (*entity.Site, error) {
+	return f.sites[id], nil
+}
+
+func (f *fakeSiteService) ExistsById(_ *gin.Context, id primitive.ObjectID) (bool, error) {
+	return f.sites[id] != nil, nil
+}
+
+type fakeCatalogService struct {
+	service.CatalogServiceInterface
+	catalogs map[primitive.ObjectID]*entity.Catalog
+}
+
+func (f *fakeCatalogService) FindById(_ *gin.Context, id primitive.ObjectID) (*entity.Catalog, error) {
+	if catalog, ok := f.catalogs[id]; ok {
+		copied := *catalog
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeCatalogService) Update(_ *gin.Context, catalog *entity.Catalog) (*entity.Catalog, error) {
+	return catalog, nil
+}
+
+type fakeTaskService struct {
+	service.TaskServiceInterface
+}
+
+func (f *fakeTaskService) FindPage(*gin.Context, string, *dto.TaskFilter, *dto.ListQuery) (*dto.PageResult, error) {
+	return &dto.PageResult{}, nil
+}
+
+func (f *fakeTaskService) Bulk(_ *gin.Context, req *dto.BulkTaskRequest,
+	_ service.TaskPublisher) (*dto.BulkTaskResult, error) {
+	return &dto.BulkTaskResult{Type: req.Type, Action: req.Action}, nil
+}
+
+// accessFixture the kxkm and nsf sites with a catalog of kxkm
+type accessFixture struct {
+	engine    *gin.Engine
+	kxkm, nsf primitive.ObjectID
+	catalog   primitive.ObjectID
+	apiKey    *entity.ApiKey //the key of the current request
+}
+
+func newAccessFixture(t *testing.T) *accessFixture {
+	f := &accessFixture{kxkm: primitive.NewObjectID(), nsf: primitive.NewObjectID(), catalog: primitive.NewObjectID()}
+	siteService, catalogService, taskService := service.SiteService, service.CatalogService, service.TaskService
+	service.SiteService = &fakeSiteService{sites: map[primitive.ObjectID]*entity.Site{
+		f.kxkm: {Id: f.kxkm, Name: base.Kxkm},
+		f.nsf:  {Id: f.nsf, Name: base.SiteNsf},
+	}}
+	service.CatalogService = &fakeCatalogService{catalogs: map[primitive.ObjectID]*entity.Catalog{
+		f.catalog: {Id: f.catalog, SiteId: f.kxkm, Name: "catalog"},
+	}}
+	service.TaskService = &fakeTaskService{}
+	t.Cleanup(func() {
+		service.SiteService, service.CatalogService, service.TaskService = siteService, catalogService, taskService
+	})
+
+	f.engine = gin.New()
+	f.engine.Use(apitest.Localize(), func(c *gin.Context) {
+		if f.apiKey != nil {
+			c.Set(base.ContextKeyApiKey, f.apiKey)
+		}
+	})
+	siteHandler, taskHandler := NewSiteHandler(), NewTaskHandler()
+	f.engine.GET("/sites/:siteId", siteHandler.FindSiteById)
+	f.engine.GET("/catalogs/:catalogId", siteHandler.FindCatalogById)
+	f.engine.PATCH("/catalogs/:catalogId", siteHandler.PatchCatalog)
+	f.engine.GET("/tasks/novels", taskHandler.FindTasksOfNovel)
+	f.engine.POST("/tasks/bulk", taskHandler.BulkTasks)
+	return f
+}
+
+// request sends the request with the api key granted the roles, the authentication is disabled if it's nil
+func (f *accessFixture) request(apiKey *entity.ApiKey, method, path, body string) int {
+	f.apiKey = apiKey
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return apitest.Serve(f.engine, req).Code
+}
+
+func TestSiteRoles(t *testing.T) {
+	f := newAccessFixture(t)
+	levels := map[string]int{base.RoleViewer: 1, base.RoleOperator: 2, base.RoleAdmin: 3}
+	bulk := func(action, siteName string) string {
+		return fmt.Sprintf(`{"type":"novel","action":%q,"filter":{"siteName":%q}}`, action, siteName)
+	}
+	endpoints := []struct {
+		method, path, body string
+		role               string
+		siteName           string
+	}{
+		{http.MethodGet, "/sites/" + f.kxkm.Hex(), "", base.RoleViewer, base.Kxkm},
+		{http.MethodGet, "/sites/" + f.nsf.Hex(), "", base.RoleViewer, base.SiteNsf},
+		{http.MethodGet, "/catalogs/" + f.catalog.Hex(), "", base.RoleViewer, base.Kxkm},
+		{http.MethodPatch, "/catalogs/" + f.catalog.Hex(), `{"name":"renamed"}`, base.RoleAdmin, base.Kxkm},
+		{http.MethodGet, "/tasks/novels?siteName=" + base.Kxkm, "", base.RoleViewer, base.Kxkm},
+		{http.MethodGet, "/tasks/novels?siteName=" + base.SiteNsf, "", base.RoleViewer, base.SiteNsf},
+		{http.MethodGet, "/tasks/novels", "", base.RoleViewer, ""},
+		{http.MethodPost, "/tasks/bulk", bulk(base.TaskActionReset, base.Kxkm), base.RoleOperator, base.Kxkm},
+		{http.MethodPost, "/tasks/bulk", bulk(base.TaskActionReset, base.SiteNsf), base.RoleOperator, base.SiteNsf},
+		{http.MethodPost, "/tasks/bulk", bulk(base.TaskActionDelete, base.Kxkm), base.RoleAdmin, base.Kxkm},
+	}
+
+	// the key is granted the role on kxkm only
+	for role, level := range levels {
+		apiKey := &entity.ApiKey{Name: role, SiteRoles: map[string]string{base.Kxkm: role}}
+		for _, e := range endpoints {
+			expected := http.StatusForbidden
+			if e.siteName == base.Kxkm && level >= levels[e.role] {
+				expected = http.StatusOK
+			}
+			if status := f.request(apiKey, e.method, e.path, e.body); status != expected {
+				t.Errorf("%v %v %v with the %v role on kxkm: expected %v, but it's %v", e.method, e.path, e.body,
+					role, expected, status)
+			}
+		}
+	}
+
+	// the roles implied by the scopes apply to all the sites, and everything is allowed without authentication
+	for _, e := range endpoints {
+		if status := f.request(&entity.ApiKey{Scopes: []string{base.ScopeAdmin}}, e.method, e.path, e.body); status != http.StatusOK {
+			t.Errorf("%v %v should be allowed with the admin scope, but it's %v", e.method, e.path, status)
+		}
+		if status := f.request(nil, e.method, e.path, e.body); status != http.StatusOK {
+			t.Errorf("%v %v should be allowed without authentication, but it's %v", e.method, e.path, status)
+		}
+	}
+	readKey := &entity.ApiKey{Scopes: []string{base.ScopeRead}}
+	if status := f.request(readKey, http.MethodPost, "/tasks/bulk", bulk(base.TaskActionReset, base.SiteNsf)); status != http.StatusForbidden {
+		t.Errorf("the read scope shouldn't allow the bulk actions, but it's %v", status)
+	}
+}
+
+func TestPatchCatalogMovingSite(t *testing.T) {
+	f := newAccessFixture(t)
+	path := "/catalogs/" + f.catalog.Hex()
+	move := fmt.Sprintf(`{"siteId":%q}`, f.nsf.Hex())
+
+	kxkmAdmin := &entity.ApiKey{Name: "kxkm-admin", SiteRoles: map[string]string{base.Kxkm: base.RoleAdmin}}
+	if status := f.request(kxkmAdmin, http.MethodPatch, path, move); status != http.StatusForbidden {
+		t.Errorf("moving the catalog should require the admin role on the target site, but it's %v", status)
+	}
+	if status := f.request(kxkmAdmin, http.MethodPatch, path, fmt.Sprintf(`{"siteId":%q}`, f.kxkm.Hex())); status != http.StatusOK {
+		t.Errorf("the site id unchanged shouldn't be checked again, but it's %v", status)
+	}
+
+	nsfOperator := &entity.ApiKey{Name: "nsf-operator",
+		SiteRoles: map[string]string{base.Kxkm: base.RoleAdmin, base.SiteNsf: base.RoleOperator}}
+	if status := f.request(nsfOperator, http.MethodPatch, path, move); status != http.StatusForbidden {
+		t.Errorf("moving the catalog should require the admin role on the target site, but it's %v", status)
+	}
+
+	bothAdmin := &entity.ApiKey{Name: "both-admin",
+		SiteRoles: map[string]string{base.Kxkm: base.RoleAdmin, base.SiteNsf: base.RoleAdmin}}
+	if status := f.request(bothAdmin, http.MethodPatch, path, move); status != http.StatusOK {
+		t.Errorf("the admin of both sites should move the catalog, but it's %v", status)
+	}
+}
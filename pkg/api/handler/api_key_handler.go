@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// ApiKeyHandler handler for managing the api keys
+type ApiKeyHandler struct{}
+
+func NewApiKeyHandler() *ApiKeyHandler {
+	return &ApiKeyHandler{}
+}
+
+// CreateApiKey create an api key
+// @Tags API
+// @Summary  创建API Key
+// @Description 创建API Key并指定全局权限(read, submit-tasks, admin)或者各站点的角色(viewer, operator, admin)。返回的key只显示一次，服务端只保存其哈希值
+// @Param   request	body   dto.ApiKeyRequest   true   "名称和权限"
+// @Accept  application/json
+// @Produce application/json
+// @Success 201 {object} base.ApiResult{payload=dto.CreatedApiKey}
+// @Router /api-keys [post]
+func (h *ApiKeyHandler) CreateApiKey(c *gin.Context) {
+	var req dto.ApiKeyRequest
+	if !bindJson(c, &req) {
+		return
+	}
+	created, err := service.ApiKeyService.Create(c, &req)
+	if err != nil {
+		zap.L().Warn("failed to create api key", zap.String("name", req.Name), zap.Error(err))
+		if errors.Is(err, base.ErrDuplicatedDocument) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, base.FailsWithParams(c, base.ErrorCode.Duplicated,
+				map[string]string{"key": "name", "name": req.Name}))
+			return
+		}
+		abortWithParamError(c, err)
+		return
+	}
+	zap.L().Info("api key created", zap.String("name", req.Name), zap.Strings("scopes", req.Scopes),
+		zap.String("by", c.GetString(base.ContextKeyCaller)))
+	addAuditIds(c, created.Id.Hex())
+	c.JSON(http.StatusCreated, base.Success(created))
+}
+
+// FindApiKeys list the api keys
+// @Tags API
+// @Summary  查询API Key
+// @Description 查询所有API Key，不包括key本身
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=[]entity.ApiKey}
+// @Router /api-keys [get]
+func (h *ApiKeyHandler) FindApiKeys(c *gin.Context) {
+	if keys, err := service.ApiKeyService.FindAll(c); err != nil {
+		zap.L().Warn("failed to find api keys", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		c.JSON(http.StatusOK, base.Success(keys))
+	}
+}
+
+// PatchApiKey update the scopes, site roles or disabled flag of an api key
+// @Tags API
+// @Summary  更新API Key的权限
+// @Param   keyId	path   string   true   "API Key ID"
+// @Param   request	body   dto.ApiKeyPatch   true   "需要更新的字段"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=entity.ApiKey}
+// @Router /api-keys/{keyId} [patch]
+func (h *ApiKeyHandler) PatchApiKey(c *gin.Context) {
+	keyId := c.Param("keyId")
+	objectId := ensureValidId(c, keyId)
+	if objectId == nil {
+		return
+	}
+	var patch dto.ApiKeyPatch
+	if !bindJson(c, &patch) {
+		return
+	}
+	apiKey, err := service.ApiKeyService.Update(c, *objectId, &patch)
+	if err != nil {
+		zap.L().Warn("failed to update api key", zap.String("keyId", keyId), zap.Error(err))
+		if errors.Is(err, base.ErrApiKeyNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	zap.L().Info("api key updated", zap.String("keyId", keyId), zap.String("by", c.GetString(base.ContextKeyCaller)))
+	c.JSON(http.StatusOK, base.Success(apiKey))
+}
+
+// DeleteApiKey delete an api key
+// @Tags API
+// @Summary  删除API Key
+// @Description 删除API Key，之后使用该key的请求会被拒绝
+// @Param   keyId	path   string   true   "API Key ID"
+// @Success 204
+// @Router /api-keys/{keyId} [delete]
+func (h *ApiKeyHandler) DeleteApiKey(c *gin.Context) {
+	keyId := c.Param("keyId")
+	objectId := ensureValidId(c, keyId)
+	if objectId == nil {
+		return
+	}
+	deleted, err := service.ApiKeyService.DeleteById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to delete api key", zap.String("keyId", keyId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if !deleted {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	zap.L().Info("api key deleted", zap.String("keyId", keyId), zap.String("by", c.GetString(base.ContextKeyCaller)))
+	c.Status(http.StatusNoContent)
+}
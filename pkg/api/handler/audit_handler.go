@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// AuditHandler handler for querying the audit logs
+type AuditHandler struct{}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// FindAuditLogs list the audit logs
+// @Tags API
+// @Summary  查询审计日志
+// @Description 分页查询修改操作的审计日志，默认按时间倒序，name按uri过滤，status按http状态码过滤
+// @Param   caller	query   string   false   "调用者"
+// @Param   method	query   string   false   "http方法"
+// @Param   route	query   string   false   "路由，例如/api/v1/sites/:siteId"
+// @Param   affectedId	query   string   false   "受影响的文档ID"
+// @Param   status	query   int   false   "http状态码"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, time, status, caller"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   name	query   string   false   "uri包含"
+// @Param   from	query   string   false   "起始时间，RFC3339格式"
+// @Param   to	query   string   false   "截止时间，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult{items=[]entity.AuditLog}}
+// @Router /audit [get]
+func (h *AuditHandler) FindAuditLogs(c *gin.Context) {
+	var query dto.ListQuery
+	var filter dto.AuditFilter
+	if !bindQuery(c, &query) || !bindQuery(c, &filter) {
+		return
+	}
+	if result, err := service.AuditService.FindPage(c, &filter, &query); err != nil {
+		zap.L().Warn("failed to find audit logs", zap.Error(err))
+		abortWithParamError(c, err)
+	} else {
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
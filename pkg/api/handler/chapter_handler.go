@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/downloader"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"errors"
+	"github.com/duke-git/lancet/v2/fileutil"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// ChapterHandler handler for chapters and their downloaded assets
+type ChapterHandler struct{}
+
+func NewChapterHandler() *ChapterHandler {
+	return &ChapterHandler{}
+}
+
+// FindChapterAssets list the download manifest of a chapter
+// @Tags API
+// @Summary  查询章节的下载清单
+// @Description 查询章节中每一页图片的来源地址、本地路径、大小、hash及下载状态
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Produce application/json
+// @Success 200 {array} entity.ChapterAsset
+// @Router /chapters/{chapterId}/assets [get]
+func (h *ChapterHandler) FindChapterAssets(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	if chapter := h.ensureChapterExists(c, chapterId); chapter != nil {
+		if assets, err := service.ChapterAssetService.FindByChapterId(c, chapter.Id); err != nil {
+			zap.L().Warn("failed to find chapter assets", zap.String("chapterId", chapterId), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		} else {
+			if assets == nil {
+				assets = []*entity.ChapterAsset{}
+			}
+			zap.L().Info("found chapter assets", zap.String("chapterId", chapterId), zap.Int("count", len(assets)))
+			c.JSON(http.StatusOK, assets)
+		}
+	}
+}
+
+// ServeChapterAsset serve a downloaded picture of the chapter
+// @Tags API
+// @Summary  获取章节的图片
+// @Description 返回已下载到本地的章节图片
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Param   page	path   int   true   "图片页码"
+// @Produce image/jpeg
+// @Success 200
+// @Router /chapters/{chapterId}/assets/{page} [get]
+func (h *ChapterHandler) ServeChapterAsset(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	objectId := ensureValidId(c, chapterId)
+	if objectId == nil {
+		return
+	}
+	page, err := strconv.Atoi(c.Param("page"))
+	if err != nil {
+		zap.L().Warn("invalid page", zap.String("page", c.Param("page")))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.BadRequest, map[string]string{"name": "page"}))
+		return
+	}
+
+	asset, err := service.ChapterAssetService.FindByChapterIdAndPage(c, *objectId, page)
+	if err != nil {
+		zap.L().Warn("failed to find chapter asset", zap.String("chapterId", chapterId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if asset == nil || asset.Status != base.AssetStatusDownloaded || !fileutil.IsExist(asset.LocalPath) {
+		zap.L().Warn("chapter asset not found", zap.String("chapterId", chapterId), zap.Int("page", page))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	c.File(asset.LocalPath)
+}
+
+// RepairChapterAssets re-download the missing or failed assets of a chapter
+// @Tags API
+// @Summary  修复章节下载
+// @Description 只重新下载章节中下载失败或本地文件丢失的图片
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Produce application/json
+// @Success 200 {object} downloader.RepairResult
+// @Router /chapters/{chapterId}/assets/repair [post]
+func (h *ChapterHandler) RepairChapterAssets(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	if chapter := h.ensureChapterExists(c, chapterId); chapter != nil {
+		if result, err := downloader.RepairChapterAssets(c, chapter.Id); err != nil {
+			zap.L().Warn("failed to repair chapter assets", zap.String("chapterId", chapterId), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		} else {
+			c.JSON(http.StatusOK, result)
+		}
+	}
+}
+
+// FindChapterContent get the content of a chapter
+// @Tags API
+// @Summary  查询章节内容
+// @Description 查询章节内容，章节被拆分为多页时按页码顺序合并
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Produce application/json
+// @Success 200 {object} dto.ChapterContent
+// @Router /chapters/{chapterId}/content [get]
+func (h *ChapterHandler) FindChapterContent(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	chapter := h.ensureChapterExists(c, chapterId)
+	if chapter == nil {
+		return
+	}
+	if content, err := service.ContentService.FindChapterContent(c, chapter); err != nil {
+		zap.L().Warn("failed to find chapter content", zap.String("chapterId", chapterId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		c.JSON(http.StatusOK, content)
+	}
+}
+
+// FindContentRevisions list the revisions of the chapter content
+// @Tags API
+// @Summary  查询章节内容的历史版本
+// @Description 查询章节内容的所有版本(不包含内容)，最新版本在前
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Param   page	query   int   false   "内容分页，默认为0"
+// @Produce application/json
+// @Success 200 {array} entity.ContentRevision
+// @Router /chapters/{chapterId}/revisions [get]
+func (h *ChapterHandler) FindContentRevisions(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	chapter := h.ensureChapterExists(c, chapterId)
+	if chapter == nil {
+		return
+	}
+	page, ok := h.queryInt(c, "page")
+	if !ok {
+		return
+	}
+	if revisions, err := service.ContentService.FindRevisions(c, chapter.Id, page); err != nil {
+		zap.L().Warn("failed to find content revisions", zap.String("chapterId", chapterId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		if revisions == nil {
+			revisions = []*entity.ContentRevision{}
+		}
+		zap.L().Info("found content revisions", zap.String("chapterId", chapterId), zap.Int("count", len(revisions)))
+		c.JSON(http.StatusOK, revisions)
+	}
+}
+
+// DiffContentRevisions compare two revisions of the chapter content
+// @Tags API
+// @Summary  比较章节内容的两个版本
+// @Description 按行比较章节内容的两个版本，to默认为当前版本，from默认为to的上一个版本
+// @Param   chapterId	path   string   true   "Chapter ID"
+// @Param   from	query   int   false   "旧版本号"
+// @Param   to	query   int   false   "新版本号"
+// @Param   page	query   int   false   "内容分页，默认为0"
+// @Produce application/json
+// @Success 200 {object} dto.ContentDiff
+// @Router /chapters/{chapterId}/revisions/diff [get]
+func (h *ChapterHandler) DiffContentRevisions(c *gin.Context) {
+	chapterId := c.Param("chapterId")
+	chapter := h.ensureChapterExists(c, chapterId)
+	if chapter == nil {
+		return
+	}
+	var params = map[string]int{"page": 0, "from": 0, "to": 0}
+	for name := range params {
+		value, ok := h.queryInt(c, name)
+		if !ok {
+			return
+		}
+		params[name] = value
+	}
+
+	if diff, err := service.ContentService.DiffRevisions(c, chapter.Id, params["page"], params["from"],
+		params["to"]); err != nil {
+		zap.L().Warn("failed to diff content revisions", zap.String("chapterId", chapterId),
+			zap.Any("params", params), zap.Error(err))
+		if errors.Is(err, base.ErrRevisionNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// parse an optional non-negative integer query parameter
+func (h *ChapterHandler) queryInt(c *gin.Context, name string) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		zap.L().Warn("invalid query parameter", zap.String("name", name), zap.String("value", value))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.BadRequest, map[string]string{"name": name}))
+		return 0, false
+	}
+	return number, true
+}
+
+// check if the chapter exists
+func (h *ChapterHandler) ensureChapterExists(c *gin.Context, chapterId string) *entity.Chapter {
+	objectId := ensureValidId(c, chapterId)
+	if objectId == nil {
+		return nil
+	}
+	chapter, err := service.ChapterService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find chapter", zap.String("chapterId", chapterId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return nil
+	}
+	if chapter == nil {
+		zap.L().Warn("chapter not found", zap.String("chapterId", chapterId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return nil
+	}
+	return chapter
+}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"crawlers/pkg/base"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"net/http"
+	"strings"
 )
 
 // convert json string to model object
@@ -20,6 +23,39 @@ func bindJson(c *gin.Context, obj any) bool {
 	return true
 }
 
+// convert query parameters to model object
+func bindQuery(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		zap.L().Warn("failed to bind query parameters", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithError(c, err))
+		return false
+	}
+	return true
+}
+
+// abortWithParamError responds 400 for an invalid query parameter, otherwise 500
+func abortWithParamError(c *gin.Context, err error) {
+	var paramErr *base.ParamError
+	if errors.As(err, &paramErr) {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.BadRequest, map[string]string{"name": paramErr.Name}))
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+}
+
+// validate the model object, e.g. the one patched by a request
+func validate(c *gin.Context, obj any) bool {
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		zap.L().Warn("validation failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithError(c, err))
+		return false
+	}
+	return true
+}
+
 func ensureValidId(c *gin.Context, id string) *primitive.ObjectID {
 	if id == "" {
 		zap.L().Warn("invalid id", zap.String("id", id))
@@ -36,3 +72,24 @@ func ensureValidId(c *gin.Context, id string) *primitive.ObjectID {
 	}
 	return &objectId
 }
+
+// addAuditIds adds the ids of the created or deleted documents into the audit log of the request
+func addAuditIds(c *gin.Context, ids ...string) {
+	c.Set(base.ContextKeyAudit, append(c.GetStringSlice(base.ContextKeyAudit), ids...))
+}
+
+// ensureCaller returns the identity of the api caller, it's set into the context by the authentication
+// or taken from the X-Caller-Id header
+func ensureCaller(c *gin.Context) string {
+	if caller := c.GetString(base.ContextKeyCaller); caller != "" {
+		return caller
+	}
+	caller := strings.TrimSpace(c.GetHeader(base.HeaderCallerId))
+	if caller == "" {
+		zap.L().Warn("caller identity is missing")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.Required, map[string]string{"name": base.HeaderCallerId}))
+		return ""
+	}
+	return caller
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// NovelHandler read-only handler for novels and their chapters
+type NovelHandler struct{}
+
+func NewNovelHandler() *NovelHandler {
+	return &NovelHandler{}
+}
+
+// FindCatalogNovels list the novels of a catalog
+// @Tags API
+// @Summary  查询目录下的Novel
+// @Description 分页查询目录下的Novel
+// @Param   catalogId	path   string   true   "目录ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: order(默认), id, name, created"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   name	query   string   false   "名称包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /catalogs/{catalogId}/novels [get]
+func (h *NovelHandler) FindCatalogNovels(c *gin.Context) {
+	catalogId := c.Param("catalogId")
+	objectId := ensureValidId(c, catalogId)
+	if objectId == nil {
+		return
+	}
+	var query dto.ListQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	catalog, err := service.CatalogService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find catalog", zap.String("catalogId", catalogId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if catalog == nil {
+		zap.L().Warn("catalog not found", zap.String("catalogId", catalogId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+
+	if result, err := service.NovelService.FindByCatalogId(c, *objectId, &query); err != nil {
+		zap.L().Warn("failed to find novels", zap.String("catalogId", catalogId), zap.Error(err))
+		abortWithParamError(c, err)
+	} else {
+		zap.L().Info("found novels", zap.String("catalogId", catalogId), zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
+
+// FindNovelById get a novel
+// @Tags API
+// @Summary  查询Novel
+// @Param   novelId	path   string   true   "Novel ID"
+// @Produce application/json
+// @Success 200 {object} entity.Novel
+// @Router /novels/{novelId} [get]
+func (h *NovelHandler) FindNovelById(c *gin.Context) {
+	if novel := h.ensureNovelExists(c, c.Param("novelId")); novel != nil {
+		c.JSON(http.StatusOK, novel)
+	}
+}
+
+// FindNovelChapters list the chapters of a novel
+// @Tags API
+// @Summary  查询Novel的章节
+// @Description 按章节顺序分页查询Novel的章节
+// @Param   novelId	path   string   true   "Novel ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: order(默认), id, name, created"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   name	query   string   false   "名称包含"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /novels/{novelId}/chapters [get]
+func (h *NovelHandler) FindNovelChapters(c *gin.Context) {
+	novelId := c.Param("novelId")
+	novel := h.ensureNovelExists(c, novelId)
+	if novel == nil {
+		return
+	}
+	var query dto.ListQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	if result, err := service.ChapterService.FindByNovelId(c, novel.Id, &query); err != nil {
+		zap.L().Warn("failed to find chapters", zap.String("novelId", novelId), zap.Error(err))
+		abortWithParamError(c, err)
+	} else {
+		zap.L().Info("found chapters", zap.String("novelId", novelId), zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
+
+// check if the novel exists
+func (h *NovelHandler) ensureNovelExists(c *gin.Context, novelId string) *entity.Novel {
+	objectId := ensureValidId(c, novelId)
+	if objectId == nil {
+		return nil
+	}
+	novel, err := service.NovelService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find novel", zap.String("novelId", novelId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return nil
+	}
+	if novel == nil {
+		zap.L().Warn("novel not found", zap.String("novelId", novelId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return nil
+	}
+	return novel
+}
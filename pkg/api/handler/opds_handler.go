@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/export"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+)
+
+// OpdsHandler OPDS 1.2 catalog for the e-reader apps, e.g. KOReader
+type OpdsHandler struct{}
+
+func NewOpdsHandler() *OpdsHandler {
+	return &OpdsHandler{}
+}
+
+// Root the navigation feed of the sites
+// @Tags OPDS
+// @Summary  OPDS根目录
+// @Produce application/atom+xml
+// @Success 200 {object} dto.OpdsFeed
+// @Router /opds [get]
+func (h *OpdsHandler) Root(c *gin.Context) {
+	if feed, err := service.OpdsService.RootFeed(c); err != nil {
+		zap.L().Warn("failed to build opds feed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		renderXml(c, dto.OpdsNavigationType, feed)
+	}
+}
+
+// Site the navigation feed of the catalogs in a site
+// @Tags OPDS
+// @Summary  OPDS站点目录
+// @Param   siteId	path   string   true   "站点ID"
+// @Produce application/atom+xml
+// @Success 200 {object} dto.OpdsFeed
+// @Router /opds/sites/{siteId} [get]
+func (h *OpdsHandler) Site(c *gin.Context) {
+	siteId := c.Param("siteId")
+	objectId := ensureValidId(c, siteId)
+	if objectId == nil {
+		return
+	}
+	site, err := service.SiteService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find site", zap.String("siteId", siteId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if site == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+
+	if feed, err := service.OpdsService.SiteFeed(c, site); err != nil {
+		zap.L().Warn("failed to build opds feed", zap.String("siteId", siteId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		renderXml(c, dto.OpdsNavigationType, feed)
+	}
+}
+
+// Catalog the acquisition feed of the novels in a catalog
+// @Tags OPDS
+// @Summary  OPDS分类下的Novel
+// @Param   catalogId	path   string   true   "目录ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Produce application/atom+xml
+// @Success 200 {object} dto.OpdsFeed
+// @Router /opds/catalogs/{catalogId} [get]
+func (h *OpdsHandler) Catalog(c *gin.Context) {
+	catalogId := c.Param("catalogId")
+	objectId := ensureValidId(c, catalogId)
+	if objectId == nil {
+		return
+	}
+	var page dto.PageRequest
+	if !bindQuery(c, &page) {
+		return
+	}
+	catalog, err := service.CatalogService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find catalog", zap.String("catalogId", catalogId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if catalog == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+
+	if feed, err := service.OpdsService.CatalogFeed(c, catalog, &page); err != nil {
+		zap.L().Warn("failed to build opds feed", zap.String("catalogId", catalogId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		renderXml(c, dto.OpdsAcquisitionType, feed)
+	}
+}
+
+// Search the acquisition feed of the novels matched
+// @Tags OPDS
+// @Summary  OPDS搜索
+// @Param   q	query   string   true   "关键字"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Produce application/atom+xml
+// @Success 200 {object} dto.OpdsFeed
+// @Router /opds/search [get]
+func (h *OpdsHandler) Search(c *gin.Context) {
+	var req dto.OpdsRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+	if req.Query == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.Required, map[string]string{"name": "q"}))
+		return
+	}
+
+	if feed, err := service.OpdsService.SearchFeed(c, req.Query, &req.PageRequest); err != nil {
+		zap.L().Warn("failed to build opds feed", zap.String("query", req.Query), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		renderXml(c, dto.OpdsAcquisitionType, feed)
+	}
+}
+
+// OpenSearch the OpenSearch description
+// @Tags OPDS
+// @Summary  OpenSearch描述
+// @Produce application/opensearchdescription+xml
+// @Success 200 {object} dto.OpenSearchDescription
+// @Router /opds/opensearch.xml [get]
+func (h *OpdsHandler) OpenSearch(c *gin.Context) {
+	renderXml(c, dto.OpenSearchType, service.OpdsService.OpenSearch())
+}
+
+// Cover the downloaded cover picture of a novel
+// @Tags OPDS
+// @Summary  Novel封面
+// @Param   novelId	path   string   true   "Novel ID"
+// @Produce image/jpeg
+// @Router /opds/novels/{novelId}/cover [get]
+func (h *OpdsHandler) Cover(c *gin.Context) {
+	novel := h.ensureNovelExists(c)
+	if novel == nil {
+		return
+	}
+	cover, err := service.ExportService.FindCover(c, novel)
+	if err != nil {
+		zap.L().Warn("failed to find cover", zap.String("novelId", novel.Id.Hex()), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if cover == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	c.File(cover)
+}
+
+// Epub export a novel as EPUB
+// @Tags OPDS
+// @Summary  导出EPUB
+// @Param   novelId	path   string   true   "Novel ID"
+// @Produce application/epub+zip
+// @Router /opds/novels/{novelId}/epub [get]
+func (h *OpdsHandler) Epub(c *gin.Context) {
+	novel := h.ensureNovelExists(c)
+	if novel == nil {
+		return
+	}
+	book, err := service.ExportService.BuildBook(c, novel)
+	if err != nil {
+		zap.L().Warn("failed to export novel", zap.String("novelId", novel.Id.Hex()), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+
+	setAttachment(c, export.MimeTypeEpub, novel.Name+".epub")
+	if err = export.WriteEpub(c.Writer, book); err != nil {
+		//the response has been partially written
+		zap.L().Warn("failed to write epub", zap.String("novelId", novel.Id.Hex()), zap.Error(err))
+	}
+}
+
+// Cbz export a comic as CBZ
+// @Tags OPDS
+// @Summary  导出CBZ
+// @Param   novelId	path   string   true   "Novel ID"
+// @Produce application/vnd.comicbook+zip
+// @Router /opds/novels/{novelId}/cbz [get]
+func (h *OpdsHandler) Cbz(c *gin.Context) {
+	novel := h.ensureNovelExists(c)
+	if novel == nil {
+		return
+	}
+	pages, err := service.ExportService.FindComicPages(c, novel)
+	if err != nil {
+		zap.L().Warn("failed to export comic", zap.String("novelId", novel.Id.Hex()), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if len(pages) == 0 {
+		zap.L().Warn("no pictures downloaded for comic", zap.String("novelId", novel.Id.Hex()))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+
+	setAttachment(c, export.MimeTypeCbz, novel.Name+".cbz")
+	if err = export.WriteCbz(c.Writer, pages); err != nil {
+		zap.L().Warn("failed to write cbz", zap.String("novelId", novel.Id.Hex()), zap.Error(err))
+	}
+}
+
+func (h *OpdsHandler) ensureNovelExists(c *gin.Context) *entity.Novel {
+	novelId := c.Param("novelId")
+	objectId := ensureValidId(c, novelId)
+	if objectId == nil {
+		return nil
+	}
+	novel, err := service.NovelService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find novel", zap.String("novelId", novelId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return nil
+	}
+	if novel == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return nil
+	}
+	return novel
+}
+
+// the xml declaration is written as well since some readers require it
+func renderXml(c *gin.Context, contentType string, data any) {
+	body, err := xml.Marshal(data)
+	if err != nil {
+		zap.L().Warn("failed to marshal xml", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+func setAttachment(c *gin.Context, contentType, fileName string) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%v", url.PathEscape(fileName)))
+	c.Status(http.StatusOK)
+}
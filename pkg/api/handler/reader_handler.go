@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// ReaderHandler handler for the reading progress and bookmarks of the api caller
+type ReaderHandler struct{}
+
+func NewReaderHandler() *ReaderHandler {
+	return &ReaderHandler{}
+}
+
+// SaveProgress update the reading progress of a novel
+// @Tags API
+// @Summary  更新阅读进度
+// @Description 记录调用者在Novel上最后阅读的章节和位置
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   novelId	path   string   true   "Novel ID"
+// @Param   progress	body   dto.ProgressRequest   true   "阅读进度"
+// @Produce application/json
+// @Success 200 {object} entity.ReadingProgress
+// @Router /me/progress/{novelId} [put]
+func (h *ReaderHandler) SaveProgress(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	novelId := c.Param("novelId")
+	objectId := ensureValidId(c, novelId)
+	if objectId == nil {
+		return
+	}
+	var req dto.ProgressRequest
+	if !bindJson(c, &req) {
+		return
+	}
+
+	novel, err := service.NovelService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find novel", zap.String("novelId", novelId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if novel == nil {
+		zap.L().Warn("novel not found", zap.String("novelId", novelId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+
+	if progress, err := service.ReaderService.SaveProgress(c, caller, novel.Id, &req); err != nil {
+		zap.L().Warn("failed to save reading progress", zap.String("novelId", novelId),
+			zap.String("chapterId", req.ChapterId), zap.Error(err))
+		h.abortWithError(c, err)
+	} else {
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// FindProgress get the reading progress of a novel
+// @Tags API
+// @Summary  查询阅读进度
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   novelId	path   string   true   "Novel ID"
+// @Produce application/json
+// @Success 200 {object} entity.ReadingProgress
+// @Router /me/progress/{novelId} [get]
+func (h *ReaderHandler) FindProgress(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	novelId := c.Param("novelId")
+	objectId := ensureValidId(c, novelId)
+	if objectId == nil {
+		return
+	}
+
+	progress, err := service.ReaderService.FindProgress(c, caller, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find reading progress", zap.String("novelId", novelId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if progress == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+// ContinueReading list the novels read recently
+// @Tags API
+// @Summary  继续阅读
+// @Description 按最近阅读时间倒序返回调用者阅读过的Novel，包含当前章节和下一章节
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /me/continue-reading [get]
+func (h *ReaderHandler) ContinueReading(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	var page dto.PageRequest
+	if !bindQuery(c, &page) {
+		return
+	}
+
+	if result, err := service.ReaderService.ContinueReading(c, caller, &page); err != nil {
+		zap.L().Warn("failed to find reading progresses", zap.String("caller", caller), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
+
+// CreateBookmark create a bookmark in a chapter
+// @Tags API
+// @Summary  添加书签
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   bookmark	body   dto.BookmarkRequest   true   "书签"
+// @Produce application/json
+// @Success 200 {object} entity.Bookmark
+// @Router /me/bookmarks [post]
+func (h *ReaderHandler) CreateBookmark(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	var req dto.BookmarkRequest
+	if !bindJson(c, &req) {
+		return
+	}
+
+	if bookmark, err := service.ReaderService.CreateBookmark(c, caller, &req); err != nil {
+		zap.L().Warn("failed to create bookmark", zap.String("chapterId", req.ChapterId), zap.Error(err))
+		h.abortWithError(c, err)
+	} else {
+		c.JSON(http.StatusOK, bookmark)
+	}
+}
+
+// FindBookmarks list the bookmarks
+// @Tags API
+// @Summary  查询书签
+// @Description 按创建时间倒序分页查询调用者的书签
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   novelId	query   string   false   "Novel ID，只查询该Novel的书签"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /me/bookmarks [get]
+func (h *ReaderHandler) FindBookmarks(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	var page dto.PageRequest
+	if !bindQuery(c, &page) {
+		return
+	}
+	var novelId *primitive.ObjectID
+	if id := c.Query("novelId"); id != "" {
+		if novelId = ensureValidId(c, id); novelId == nil {
+			return
+		}
+	}
+
+	if result, err := service.ReaderService.FindBookmarks(c, caller, novelId, &page); err != nil {
+		zap.L().Warn("failed to find bookmarks", zap.String("caller", caller), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
+
+// DeleteBookmark delete a bookmark
+// @Tags API
+// @Summary  删除书签
+// @Param   X-Caller-Id	header   string   true   "调用者标识"
+// @Param   bookmarkId	path   string   true   "书签ID"
+// @Success 204
+// @Router /me/bookmarks/{bookmarkId} [delete]
+func (h *ReaderHandler) DeleteBookmark(c *gin.Context) {
+	caller := ensureCaller(c)
+	if caller == "" {
+		return
+	}
+	bookmarkId := c.Param("bookmarkId")
+	objectId := ensureValidId(c, bookmarkId)
+	if objectId == nil {
+		return
+	}
+
+	deleted, err := service.ReaderService.DeleteBookmark(c, caller, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to delete bookmark", zap.String("bookmarkId", bookmarkId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if !deleted {
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// the chapter in the request body is invalid if it isn't found
+func (h *ReaderHandler) abortWithError(c *gin.Context, err error) {
+	if errors.Is(err, base.ErrChapterNotFound) {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.BadRequest, map[string]string{"name": "chapterId"}))
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+}
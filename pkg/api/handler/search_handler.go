@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/service"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// SearchHandler handler for full-text search
+type SearchHandler struct{}
+
+func NewSearchHandler() *SearchHandler {
+	return &SearchHandler{}
+}
+
+// Search full-text search over novels and chapters
+// @Tags API
+// @Summary  全文检索
+// @Description 按novel名称、作者、描述或章节内容检索，结果按相关度排序并高亮匹配的内容
+// @Param   q	query   string   true   "关键字"
+// @Param   type	query   string   false   "novel或chapter，默认为novel"
+// @Param   siteId	query   string   false   "站点ID"
+// @Param   catalogId	query   string   false   "目录ID"
+// @Param   crawlerType	query   int   false   "资源抓取类型"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.SearchResult}
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	var req dto.SearchRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+	if req.Type != "" && req.Type != base.SearchTypeNovel && req.Type != base.SearchTypeChapter {
+		zap.L().Warn("invalid search type", zap.String("type", req.Type))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.BadRequest, map[string]string{"name": "type"}))
+		return
+	}
+
+	var siteId, catalogId *primitive.ObjectID
+	if req.SiteId != "" {
+		if siteId = ensureValidId(c, req.SiteId); siteId == nil {
+			return
+		}
+	}
+	if req.CatalogId != "" {
+		if catalogId = ensureValidId(c, req.CatalogId); catalogId == nil {
+			return
+		}
+	}
+
+	if result, err := service.SearchService.Search(c, &req, siteId, catalogId); err != nil {
+		zap.L().Warn("failed to search", zap.String("q", req.Query), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		zap.L().Info("search completed", zap.String("q", req.Query), zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/service"
+	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jeven2016/mylibs/system"
 	"github.com/jeven2016/mylibs/utils"
@@ -21,30 +23,67 @@ func NewSiteHandler() *SiteHandler {
 	return &SiteHandler{}
 }
 
+// FindSites list the sites
+// @Tags API
+// @Summary  查询网站
+// @Description 分页查询网站，支持按名称过滤和排序
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, name, displayName, created"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   name	query   string   false   "名称包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /sites [get]
 func (h *SiteHandler) FindSites(c *gin.Context) {
-	if sites, err := service.SiteService.FindSites(c); err != nil {
-		zap.L().Warn("failed to find sites", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError,
-			base.FailsWithError(c, err))
+	var query dto.ListQuery
+	if !bindQuery(c, &query) {
 		return
+	}
+	if result, err := service.SiteService.FindPage(c, &query); err != nil {
+		zap.L().Warn("failed to find sites", zap.Error(err))
+		abortWithParamError(c, err)
 	} else {
-		zap.L().Info("found sites", zap.Int("count", len(sites)))
-		c.JSON(http.StatusOK, sites)
+		zap.L().Info("found sites", zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
 	}
 }
 
+// FindSiteCatalogs list the catalogs of a site
+// @Tags API
+// @Summary  查询网站下的目录
+// @Description 分页查询网站下的目录，支持按名称过滤和排序
+// @Param   siteId	path   string   true   "站点ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, name, created"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   name	query   string   false   "名称包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /sites/{siteId}/catalogs [get]
 func (h *SiteHandler) FindSiteCatalogs(c *gin.Context) {
 	siteId := c.Param("siteId")
-	siteObjectId := ensureValidId(c, siteId)
+	siteObjectId := h.ensureValidSiteId(c, siteId, base.RoleViewer)
 	if siteObjectId == nil {
 		return
 	}
-	if catalogs, err := service.CatalogService.FindCatalogsBySiteId(c, *siteObjectId); err != nil {
+	var query dto.ListQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+	if result, err := service.CatalogService.FindPageBySiteId(c, *siteObjectId, &query); err != nil {
 		zap.L().Warn("failed to find catalogs", zap.String("siteId", siteId), zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		abortWithParamError(c, err)
 	} else {
-		zap.L().Info("found catalogs", zap.Int("count", len(catalogs)))
-		c.JSON(http.StatusOK, catalogs)
+		zap.L().Info("found catalogs", zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
 	}
 }
 
@@ -65,6 +104,9 @@ func (h *SiteHandler) FindSiteSettings(c *gin.Context) {
 		c.Status(http.StatusNotFound)
 		return
 	}
+	if !ensureSiteRole(c, site.Name, base.RoleViewer) {
+		return
+	}
 
 	if siteSettings, err := service.SiteService.FindSettings(c, *siteObjectId); err != nil {
 		zap.L().Warn("failed to find site settings", zap.String("siteId", siteId), zap.Error(err))
@@ -77,33 +119,122 @@ func (h *SiteHandler) FindSiteSettings(c *gin.Context) {
 			return
 		}
 		zap.L().Info("found site settings", zap.String("siteId", siteId))
-		c.JSON(http.StatusOK, siteSettings)
+		c.JSON(http.StatusOK, siteSettings.Redacted())
 	}
 }
 
+// FindEffectiveSiteSettings find the merged settings of a site
+// @Tags API
+// @Summary  查询站点生效的设置
+// @Description 查询按优先级(默认值 < YAML配置 < 数据库中的站点设置)合并后的站点设置，sources为每个值的来源: default, yaml, mongo
+// @Param   siteId	path   string   true   "站点ID"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.EffectiveSiteSettings}
+// @Router /sites/{siteId}/settings/effective [get]
+func (h *SiteHandler) FindEffectiveSiteSettings(c *gin.Context) {
+	siteId := c.Param("siteId")
+	objectId := ensureValidId(c, siteId)
+	if objectId == nil {
+		return
+	}
+	site, err := service.SiteService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find site", zap.String("siteId", siteId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if site == nil {
+		zap.L().Warn("site does not exist", zap.String("siteId", siteId))
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.Fails(c, base.ErrorCode.SiteNotFound))
+		return
+	}
+	if !ensureSiteRole(c, site.Name, base.RoleViewer) {
+		return
+	}
+	if effective, err := service.ConfigService.GetEffectiveSiteConfig(c, site.Name); err != nil {
+		zap.L().Warn("failed to resolve the site settings", zap.String("siteId", siteId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+	} else {
+		effective.Settings = effective.Settings.Redacted()
+		c.JSON(http.StatusOK, base.Success(effective))
+	}
+}
+
+// SaveSiteSettings replace the settings of a site
+// @Tags API
+// @Summary  保存站点设置
+// @Param   siteId	path   string   true   "站点ID"
+// @Param   settings	body   entity.SiteSettings   true   "站点设置"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} entity.SiteSettings
+// @Router /sites/{siteId}/settings [put]
 func (h *SiteHandler) SaveSiteSettings(c *gin.Context) {
 	siteId := c.Param("siteId")
-	siteObjectId := ensureValidId(c, siteId)
+	siteObjectId := h.ensureValidSiteId(c, siteId, base.RoleAdmin)
 	if siteObjectId == nil {
 		return
 	}
 	var siteSettings entity.SiteSettings
-	if !bindJson(c, &siteSettings) {
+	if !h.bindSettings(c, &siteSettings) {
 		return
 	}
+	siteSettings.SiteId = *siteObjectId
 
 	if siteSettings, err := service.SiteService.SaveSettings(c, &siteSettings); err != nil {
-		zap.L().Warn("failed to find site settings", zap.String("siteId", siteId), zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		zap.L().Warn("failed to save site settings", zap.String("siteId", siteId), zap.Error(err))
+		h.abortWithError(c, err, "site", siteId)
 	} else {
-		if siteSettings == nil {
-			zap.L().Warn("site settings not found even though save succeeded", zap.String("siteId", siteId))
-			c.Status(http.StatusNotFound)
-			return
-		}
-		zap.L().Info("Site settings saved successfully", zap.Any("siteSettings", siteSettings))
-		c.JSON(http.StatusOK, siteSettings)
+		zap.L().Info("Site settings saved successfully", zap.Any("siteSettings", siteSettings.Redacted()))
+		c.JSON(http.StatusOK, siteSettings.Redacted())
+	}
+}
+
+// bindSettings rejects the unknown keys and the values of wrong types with the field errors
+func (h *SiteHandler) bindSettings(c *gin.Context, siteSettings *entity.SiteSettings) bool {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.FailsWithError(c, err))
+		return false
 	}
+	var document map[string]any
+	if err = json.Unmarshal(data, &document); err != nil {
+		zap.L().Warn("failed to convert json", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.FailsWithError(c, err))
+		return false
+	}
+	if fieldErrors := service.CheckSiteSettingsDocument(document, "json"); fieldErrors != nil {
+		zap.L().Warn("invalid site settings", zap.Error(fieldErrors))
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithFieldErrors(c, base.ErrorCode.InvalidSettings, fieldErrors))
+		return false
+	}
+	if err = json.Unmarshal(data, siteSettings); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.FailsWithError(c, err))
+		return false
+	}
+	return validate(c, siteSettings)
+}
+
+// DeleteSiteSettings delete the settings of a site
+// @Tags API
+// @Summary  删除站点设置
+// @Param   siteId	path   string   true   "站点ID"
+// @Success 204
+// @Router /sites/{siteId}/settings [delete]
+func (h *SiteHandler) DeleteSiteSettings(c *gin.Context) {
+	siteId := c.Param("siteId")
+	siteObjectId := h.ensureValidSiteId(c, siteId, base.RoleAdmin)
+	if siteObjectId == nil {
+		return
+	}
+	if err := service.SiteService.DeleteSettings(c, *siteObjectId); err != nil {
+		zap.L().Warn("failed to delete site settings", zap.String("siteId", siteId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	zap.L().Info("site settings are deleted", zap.String("siteId", siteId))
+	c.Status(http.StatusNoContent)
 }
 
 // CreateSite create a site
@@ -132,27 +263,118 @@ func (h *SiteHandler) CreateSite(c *gin.Context) {
 		Entity:        site,
 		Collection:    base.CollectionSite,
 		RedisCacheKey: utils.GenKey(base.SiteKeyExistsPrefix, site.Name),
+		ExistsFunc: func() (bool, error) {
+			return service.SiteService.ExistsByName(c, site.Name)
+		},
 	})
 }
 
-func (h *SiteHandler) DeleteSite(c *gin.Context) {
+// UpdateSite replace a site
+// @Tags API
+// @Summary  更新网站
+// @Param   siteId	path   string   true   "站点ID"
+// @Param   site	body   entity.Site   true   "网站"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} entity.Site
+// @Router /sites/{siteId} [put]
+func (h *SiteHandler) UpdateSite(c *gin.Context) {
 	siteId := c.Param("siteId")
+	objectId := h.ensureValidSiteId(c, siteId, base.RoleAdmin)
+	if objectId == nil {
+		return
+	}
+	var site entity.Site
+	if !bindJson(c, &site) {
+		return
+	}
+	site.Id = *objectId
+	h.doUpdateSite(c, &site)
+}
 
-	objectId := h.ensureValidSiteId(c, siteId)
+// PatchSite update some fields of a site
+// @Tags API
+// @Summary  部分更新网站
+// @Param   siteId	path   string   true   "站点ID"
+// @Param   site	body   dto.SitePatch   true   "需要更新的字段"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} entity.Site
+// @Router /sites/{siteId} [patch]
+func (h *SiteHandler) PatchSite(c *gin.Context) {
+	siteId := c.Param("siteId")
+	objectId := ensureValidId(c, siteId)
 	if objectId == nil {
 		return
 	}
-	if err := service.SiteService.DeleteById(c, *objectId); err != nil {
-		zap.L().Warn("failed to delete site", zap.String("siteId", siteId), zap.Error(err))
+	site, err := service.SiteService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find site", zap.String("siteId", siteId), zap.Error(err))
 		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
 		return
 	}
-	zap.L().Info("site is deleted", zap.String("siteId", siteId))
-	c.Status(http.StatusOK)
+	if site == nil {
+		zap.L().Warn("site does not exist", zap.String("siteId", siteId))
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.Fails(c, base.ErrorCode.SiteNotFound))
+		return
+	}
+	if !ensureSiteRole(c, site.Name, base.RoleAdmin) {
+		return
+	}
+	var patch dto.SitePatch
+	if !bindJson(c, &patch) {
+		return
+	}
+	patch.Apply(site)
+	if !validate(c, site) {
+		return
+	}
+	h.doUpdateSite(c, site)
+}
 
+func (h *SiteHandler) doUpdateSite(c *gin.Context, site *entity.Site) {
+	if updated, err := service.SiteService.Update(c, site); err != nil {
+		zap.L().Warn("failed to update site", zap.String("siteId", site.Id.Hex()), zap.Error(err))
+		h.abortWithError(c, err, "site", site.Name)
+	} else {
+		zap.L().Info("site is updated", zap.String("siteId", site.Id.Hex()))
+		c.JSON(http.StatusOK, updated)
+	}
 }
 
-func (h *SiteHandler) ensureValidSiteId(c *gin.Context, siteId string) *primitive.ObjectID {
+// DeleteSite delete a site
+// @Tags API
+// @Summary  删除网站
+// @Description 删除网站及其设置，cascade为true时同时删除目录、任务、Novel、章节和内容
+// @Param   siteId	path   string   true   "站点ID"
+// @Param   cascade	query   bool   false   "是否级联删除"
+// @Param   deleteFiles	query   bool   false   "级联删除时是否删除下载的文件"
+// @Produce application/json
+// @Success 200 {object} dto.DeleteResult
+// @Router /sites/{siteId} [delete]
+func (h *SiteHandler) DeleteSite(c *gin.Context) {
+	siteId := c.Param("siteId")
+
+	objectId := h.ensureValidSiteId(c, siteId, base.RoleAdmin)
+	if objectId == nil {
+		return
+	}
+	var opts dto.DeleteOptions
+	if !bindQuery(c, &opts) {
+		return
+	}
+	result, err := service.SiteService.DeleteById(c, *objectId, &opts)
+	if err != nil {
+		zap.L().Warn("failed to delete site", zap.String("siteId", siteId), zap.Any("result", result), zap.Error(err))
+		h.abortWithError(c, err, "site", siteId)
+		return
+	}
+	zap.L().Info("site is deleted", zap.String("siteId", siteId), zap.Any("result", result))
+	c.JSON(http.StatusOK, result)
+}
+
+// ensureValidSiteId checks if the site exists and the caller is granted the role on it
+func (h *SiteHandler) ensureValidSiteId(c *gin.Context, siteId string, role string) *primitive.ObjectID {
 	objectId := ensureValidId(c, siteId)
 	if objectId != nil {
 		siteExists, err := service.SiteService.ExistsById(c, *objectId)
@@ -161,6 +383,9 @@ func (h *SiteHandler) ensureValidSiteId(c *gin.Context, siteId string) *primitiv
 			c.AbortWithStatusJSON(http.StatusBadRequest, base.Fails(c, base.ErrorCode.SiteNotFound))
 			return nil
 		}
+		if !ensureSiteIdRole(c, *objectId, role) {
+			return nil
+		}
 	}
 	return objectId
 }
@@ -200,6 +425,9 @@ func (h *SiteHandler) CreateCatalog(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, base.Fails(c, base.ErrorCode.SiteNotFound))
 		return
 	}
+	if !ensureSiteIdRole(c, catalog.SiteId, base.RoleAdmin) {
+		return
+	}
 
 	h.doCreate(c, &dto.CreateRequest{
 		Key:           "catalog",
@@ -207,6 +435,9 @@ func (h *SiteHandler) CreateCatalog(c *gin.Context) {
 		Entity:        catalog,
 		Collection:    base.CollectionCatalog,
 		RedisCacheKey: utils.GenKey(base.CatalogKeyExistsPrefix, catalog.Name),
+		ExistsFunc: func() (bool, error) {
+			return service.CatalogService.ExistsByName(c, catalog.Name)
+		},
 	})
 }
 
@@ -215,7 +446,7 @@ func (h *SiteHandler) doCreate(c *gin.Context, req *dto.CreateRequest) {
 	col := system.GetSystem().GetCollection(req.Collection)
 
 	exists, err := utils.Exists(c, req.RedisCacheKey, func() (any, error) {
-		return service.CatalogService.ExistsByName(c, req.Name)
+		return req.ExistsFunc()
 	})
 	if err != nil {
 		zap.L().Warn("failed to check if it exists", zap.Error(err), zap.Any("request", req.Entity))
@@ -227,7 +458,8 @@ func (h *SiteHandler) doCreate(c *gin.Context, req *dto.CreateRequest) {
 		zap.L().Warn("it's duplicated to save", zap.Any(req.Key, req.Name), zap.Error(err))
 		c.AbortWithStatusJSON(http.StatusBadRequest,
 			base.FailsWithParams(c, base.ErrorCode.Duplicated, map[string]string{
-				req.Key: req.Name,
+				"key":  req.Key,
+				"name": req.Name,
 			}))
 		return
 	}
@@ -238,6 +470,9 @@ func (h *SiteHandler) doCreate(c *gin.Context, req *dto.CreateRequest) {
 			base.FailsWithMessage(base.ErrorCode.Unexpected, err.Error()))
 		return
 	} else {
+		if id, ok := obj.InsertedID.(primitive.ObjectID); ok {
+			addAuditIds(c, id.Hex())
+		}
 		c.JSON(http.StatusCreated, obj)
 	}
 }
@@ -254,7 +489,7 @@ func (h *SiteHandler) doCreate(c *gin.Context, req *dto.CreateRequest) {
 func (h *SiteHandler) FindSiteById(c *gin.Context) {
 	siteId := c.Param("siteId")
 
-	objectId := h.ensureValidSiteId(c, siteId)
+	objectId := h.ensureValidSiteId(c, siteId, base.RoleViewer)
 	if objectId == nil {
 		return
 	}
@@ -280,17 +515,147 @@ func (h *SiteHandler) FindSiteById(c *gin.Context) {
 // @Success 200 array entity.Catalog
 // @Router /catalogs/{catalogId} [get]
 func (h *SiteHandler) FindCatalogById(c *gin.Context) {
+	if catalog := h.ensureCatalogExists(c, c.Param("catalogId"), base.RoleViewer); catalog != nil {
+		zap.L().Info("found catalog", zap.Any("catalog", catalog))
+		c.JSON(http.StatusOK, catalog)
+	}
+}
+
+// UpdateCatalog replace a catalog
+// @Tags API
+// @Summary  更新目录
+// @Param   catalogId	path   string   true   "目录ID"
+// @Param   catalog	body   entity.Catalog   true   "目录"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} entity.Catalog
+// @Router /catalogs/{catalogId} [put]
+func (h *SiteHandler) UpdateCatalog(c *gin.Context) {
+	existing := h.ensureCatalogExists(c, c.Param("catalogId"), base.RoleAdmin)
+	if existing == nil {
+		return
+	}
+	var catalog entity.Catalog
+	if !bindJson(c, &catalog) {
+		return
+	}
+	//moving the catalog into another site requires the admin role on both of them
+	if catalog.SiteId != existing.SiteId && !ensureSiteIdRole(c, catalog.SiteId, base.RoleAdmin) {
+		return
+	}
+	catalog.Id = existing.Id
+	h.doUpdateCatalog(c, &catalog)
+}
+
+// PatchCatalog update some fields of a catalog
+// @Tags API
+// @Summary  部分更新目录
+// @Param   catalogId	path   string   true   "目录ID"
+// @Param   catalog	body   dto.CatalogPatch   true   "需要更新的字段"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} entity.Catalog
+// @Router /catalogs/{catalogId} [patch]
+func (h *SiteHandler) PatchCatalog(c *gin.Context) {
+	catalog := h.ensureCatalogExists(c, c.Param("catalogId"), base.RoleAdmin)
+	if catalog == nil {
+		return
+	}
+	var patch dto.CatalogPatch
+	if !bindJson(c, &patch) {
+		return
+	}
+	//moving the catalog into another site requires the admin role on both of them
+	if patch.SiteId != nil && *patch.SiteId != catalog.SiteId && !ensureSiteIdRole(c, *patch.SiteId, base.RoleAdmin) {
+		return
+	}
+	patch.Apply(catalog)
+	if !validate(c, catalog) {
+		return
+	}
+	h.doUpdateCatalog(c, catalog)
+}
+
+func (h *SiteHandler) doUpdateCatalog(c *gin.Context, catalog *entity.Catalog) {
+	if updated, err := service.CatalogService.Update(c, catalog); err != nil {
+		zap.L().Warn("failed to update catalog", zap.String("catalogId", catalog.Id.Hex()), zap.Error(err))
+		h.abortWithError(c, err, "catalog", catalog.Name)
+	} else {
+		zap.L().Info("catalog is updated", zap.String("catalogId", catalog.Id.Hex()))
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteCatalog delete a catalog
+// @Tags API
+// @Summary  删除目录
+// @Description 删除目录，cascade为true时同时删除任务、Novel、章节和内容
+// @Param   catalogId	path   string   true   "目录ID"
+// @Param   cascade	query   bool   false   "是否级联删除"
+// @Param   deleteFiles	query   bool   false   "级联删除时是否删除下载的文件"
+// @Produce application/json
+// @Success 200 {object} dto.DeleteResult
+// @Router /catalogs/{catalogId} [delete]
+func (h *SiteHandler) DeleteCatalog(c *gin.Context) {
 	catalogId := c.Param("catalogId")
+	catalog := h.ensureCatalogExists(c, catalogId, base.RoleAdmin)
+	if catalog == nil {
+		return
+	}
+	var opts dto.DeleteOptions
+	if !bindQuery(c, &opts) {
+		return
+	}
+	result, err := service.CatalogService.DeleteById(c, catalog.Id, &opts)
+	if err != nil {
+		zap.L().Warn("failed to delete catalog", zap.String("catalogId", catalogId), zap.Any("result", result),
+			zap.Error(err))
+		h.abortWithError(c, err, "catalog", catalogId)
+		return
+	}
+	zap.L().Info("catalog is deleted", zap.String("catalogId", catalogId), zap.Any("result", result))
+	c.JSON(http.StatusOK, result)
+}
+
+// check if the catalog exists and the caller is granted the role on its site
+func (h *SiteHandler) ensureCatalogExists(c *gin.Context, catalogId string, role string) *entity.Catalog {
 	objectId := ensureValidId(c, catalogId)
+	if objectId == nil {
+		return nil
+	}
+	catalog, err := service.CatalogService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find catalog", zap.String("catalogId", catalogId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			base.FailsWithMessage(base.ErrorCode.Unexpected, err.Error()))
+		return nil
+	}
+	if catalog == nil {
+		zap.L().Warn("catalog not found", zap.String("catalogId", catalogId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return nil
+	}
+	if !ensureSiteIdRole(c, catalog.SiteId, role) {
+		return nil
+	}
+	return catalog
+}
 
-	if objectId != nil {
-		if catalog, err := service.CatalogService.FindById(c, *objectId); err != nil {
-			zap.L().Warn("failed to find catalog", zap.String("catalogId", catalogId), zap.Error(err))
-			c.AbortWithStatusJSON(http.StatusInternalServerError,
-				base.FailsWithMessage(base.ErrorCode.Unexpected, err.Error()))
-		} else {
-			zap.L().Info("found catalog", zap.Any("catalog", catalog))
-			c.JSON(http.StatusOK, catalog)
-		}
+// abortWithError converts the errors of the site and catalog services into responses
+func (h *SiteHandler) abortWithError(c *gin.Context, err error, key, name string) {
+	var fieldErrors base.FieldErrors
+	switch {
+	case errors.As(err, &fieldErrors):
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithFieldErrors(c, base.ErrorCode.InvalidSettings, fieldErrors))
+	case errors.Is(err, base.ErrSiteNotFound):
+		c.AbortWithStatusJSON(http.StatusBadRequest, base.Fails(c, base.ErrorCode.SiteNotFound))
+	case errors.Is(err, base.ErrCatalogNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+	case errors.Is(err, base.ErrDuplicatedDocument):
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			base.FailsWithParams(c, base.ErrorCode.Duplicated, map[string]string{"key": key, "name": name}))
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
 	}
 }
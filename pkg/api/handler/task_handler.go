@@ -2,9 +2,11 @@ package handler
 
 import (
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/service"
 	"crawlers/pkg/stream"
+	"errors"
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/gin-gonic/gin"
 	"github.com/jeven2016/mylibs/system"
@@ -22,40 +24,275 @@ func NewTaskHandler() *TaskHandler {
 	return &TaskHandler{}
 }
 
+// FindTasksOfCatalogPage list the catalog page tasks
+// @Tags API
+// @Summary  查询目录页面任务
+// @Description 分页查询目录页面任务，name按url过滤
+// @Param   catalogId	query   string   false   "目录ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, url, status, retries, created, updated"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   status	query   int   false   "任务状态"
+// @Param   siteName	query   string   false   "站点名称"
+// @Param   minRetries	query   int   false   "最少重试次数"
+// @Param   name	query   string   false   "url包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /tasks/catalog-pages [get]
 func (h *TaskHandler) FindTasksOfCatalogPage(c *gin.Context) {
-	catalogId := c.Query("catalogId")
-	objectId := ensureValidId(c, catalogId)
+	h.findTasks(c, base.TaskTypeCatalogPage)
+}
 
-	if objectId == nil {
+// FindTasksOfNovel list the novel tasks
+// @Tags API
+// @Summary  查询Novel任务
+// @Description 分页查询Novel任务，name按url过滤
+// @Param   catalogId	query   string   false   "目录ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, url, status, retries, created, updated"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   status	query   int   false   "任务状态"
+// @Param   siteName	query   string   false   "站点名称"
+// @Param   minRetries	query   int   false   "最少重试次数"
+// @Param   name	query   string   false   "url包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /tasks/novels [get]
+func (h *TaskHandler) FindTasksOfNovel(c *gin.Context) {
+	h.findTasks(c, base.TaskTypeNovel)
+}
+
+// FindTasksOfChapter list the chapter tasks
+// @Tags API
+// @Summary  查询章节任务
+// @Description 分页查询章节任务，name按url过滤
+// @Param   novelId	query   string   false   "NovelID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, url, status, retries, created, updated"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   status	query   int   false   "任务状态"
+// @Param   siteName	query   string   false   "站点名称"
+// @Param   minRetries	query   int   false   "最少重试次数"
+// @Param   name	query   string   false   "url包含"
+// @Param   from	query   string   false   "创建时间起始，RFC3339格式"
+// @Param   to	query   string   false   "创建时间截止，RFC3339格式"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult}
+// @Router /tasks/chapters [get]
+func (h *TaskHandler) FindTasksOfChapter(c *gin.Context) {
+	h.findTasks(c, base.TaskTypeChapter)
+}
+
+func (h *TaskHandler) findTasks(c *gin.Context, taskType string) {
+	var query dto.ListQuery
+	var filter dto.TaskFilter
+	if !bindQuery(c, &query) || !bindQuery(c, &filter) {
 		return
 	}
-	if catalogs, err := service.CatalogPageTaskService.FindTasksByCatalogId(c, *objectId); err != nil {
-		zap.L().Warn("failed to find catalogPage tasks", zap.String("catalogId", catalogId), zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError,
-			base.FailsWithMessage(base.ErrorCode.Unexpected, err.Error()))
+	//the keys granted roles on some sites only need to filter the tasks by site name
+	if !ensureSiteRole(c, query.SiteName, base.RoleViewer) {
 		return
+	}
+	if result, err := service.TaskService.FindPage(c, taskType, &filter, &query); err != nil {
+		zap.L().Warn("failed to find tasks", zap.String("type", taskType), zap.Error(err))
+		abortWithParamError(c, err)
 	} else {
-		zap.L().Info("found catalogPage tasks", zap.Int("count", len(catalogs)))
-		c.JSON(http.StatusOK, catalogs)
+		zap.L().Info("found tasks", zap.String("type", taskType), zap.Int64("total", result.Total))
+		c.JSON(http.StatusOK, base.Success(result))
 	}
 }
 
-func (h *TaskHandler) FindTasksOfNovel(c *gin.Context) {
-	catalogId := c.Query("catalogId")
-	objectId := ensureValidId(c, catalogId)
+// BulkTasks apply an action on the tasks matching the filter
+// @Tags API
+// @Summary  批量操作任务
+// @Description 对匹配条件的任务批量执行操作: requeue重新发送到消息队列，reset重置为未开始，delete删除，finish标记为完成。dryRun为true时只返回匹配的任务数量。只拥有部分站点角色的API Key需要指定siteName
+// @Param   request	body   dto.BulkTaskRequest   true   "批量操作请求"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.BulkTaskResult}
+// @Router /tasks/bulk [post]
+func (h *TaskHandler) BulkTasks(c *gin.Context) {
+	var req dto.BulkTaskRequest
+	if !bindJson(c, &req) {
+		return
+	}
+	role := base.RoleOperator
+	if req.Action == base.TaskActionDelete {
+		role = base.RoleAdmin
+	}
+	if !ensureSiteRole(c, req.Filter.SiteName, role) {
+		return
+	}
+	result, err := service.TaskService.Bulk(c, &req, stream.PublishTask)
+	if err != nil {
+		zap.L().Warn("failed to apply the bulk action on tasks", zap.String("type", req.Type),
+			zap.String("action", req.Action), zap.Any("result", result), zap.Error(err))
+		abortWithParamError(c, err)
+		return
+	}
+	zap.L().Info("bulk action applied on tasks", zap.Any("result", result))
+	c.JSON(http.StatusOK, base.Success(result))
+}
 
-	if objectId == nil {
+// FindDeferredNovelTasks list the novel tasks waiting to be started
+// @Tags API
+// @Summary  查询等待启动的Novel任务
+// @Description 分页查询以DownloadNow=false创建的Novel任务，包括名称和封面，用于手动选择启动
+// @Param   catalogId	query   string   false   "目录ID"
+// @Param   page	query   int   false   "页码，从1开始"
+// @Param   size	query   int   false   "每页数量，默认为20"
+// @Param   cursor	query   string   false   "上一页最后一条的ID，按ID排序时使用"
+// @Param   sort	query   string   false   "排序字段: id, name, created"
+// @Param   order	query   string   false   "asc或desc"
+// @Param   siteName	query   string   false   "站点名称"
+// @Param   name	query   string   false   "名称包含"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.PageResult{items=[]dto.DeferredNovel}}
+// @Router /tasks/novels/deferred [get]
+func (h *TaskHandler) FindDeferredNovelTasks(c *gin.Context) {
+	var catalogId *primitive.ObjectID
+	if id := c.Query("catalogId"); id != "" {
+		if catalogId = ensureValidId(c, id); catalogId == nil {
+			return
+		}
+	}
+	var query dto.ListQuery
+	if !bindQuery(c, &query) {
 		return
 	}
-	if novelTasks, err := service.NovelTaskService.FindByCatalogId(c, *objectId); err != nil {
-		zap.L().Warn("failed to find novel tasks", zap.String("catalogId", catalogId), zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError,
-			base.FailsWithMessage(base.ErrorCode.Unexpected, err.Error()))
+	if !ensureSiteRole(c, query.SiteName, base.RoleViewer) {
 		return
+	}
+	if result, err := service.NovelTaskService.FindDeferred(c, catalogId, &query); err != nil {
+		zap.L().Warn("failed to find deferred novel tasks", zap.Error(err))
+		abortWithParamError(c, err)
 	} else {
-		zap.L().Info("found novel tasks", zap.Int("count", len(novelTasks)))
-		c.JSON(http.StatusOK, novelTasks)
+		c.JSON(http.StatusOK, base.Success(result))
+	}
+}
+
+// StartNovelTask start a deferred novel task
+// @Tags API
+// @Summary  启动等待中的Novel任务
+// @Description 以DownloadNow=true重新发送等待启动的Novel任务
+// @Param   taskId	path   string   true   "任务ID"
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=entity.NovelTask}
+// @Router /tasks/novels/{taskId}/start [post]
+func (h *TaskHandler) StartNovelTask(c *gin.Context) {
+	taskId := c.Param("taskId")
+	objectId := ensureValidId(c, taskId)
+	if objectId == nil {
+		return
+	}
+	task, err := service.NovelTaskService.FindById(c, *objectId)
+	if err != nil {
+		zap.L().Warn("failed to find novel task", zap.String("taskId", taskId), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	if task == nil {
+		zap.L().Warn("novel task not found", zap.String("taskId", taskId))
+		c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+		return
+	}
+	if !ensureSiteRole(c, task.SiteName, base.RoleOperator) {
+		return
+	}
+	task, err = service.NovelTaskService.StartById(c, *objectId, stream.PublishTask)
+	if err != nil {
+		zap.L().Warn("failed to start novel task", zap.String("taskId", taskId), zap.Error(err))
+		if errors.Is(err, base.ErrTaskNotDeferred) {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				base.FailsWithParams(c, base.ErrorCode.TaskNotDeferred, map[string]string{"name": taskId}))
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+		return
+	}
+	zap.L().Info("deferred novel task started", zap.String("taskId", taskId), zap.String("url", task.Url))
+	c.JSON(http.StatusOK, base.Success(task))
+}
+
+// StartDeferredNovelTasks start the deferred novel tasks of a catalog or the selected ones
+// @Tags API
+// @Summary  批量启动等待中的Novel任务
+// @Description 启动目录下所有等待启动的Novel任务，或者启动指定ID的任务，已启动的任务会被忽略
+// @Param   request	body   dto.StartTasksRequest   true   "目录ID或任务ID列表"
+// @Accept  application/json
+// @Produce application/json
+// @Success 200 {object} base.ApiResult{payload=dto.StartTasksResult}
+// @Router /tasks/novels/deferred/start [post]
+func (h *TaskHandler) StartDeferredNovelTasks(c *gin.Context) {
+	var req dto.StartTasksRequest
+	if !bindJson(c, &req) {
+		return
+	}
+	if !h.ensureStartAllowed(c, &req) {
+		return
 	}
+	result, err := service.NovelTaskService.StartDeferred(c, &req, stream.PublishTask)
+	if err != nil {
+		zap.L().Warn("failed to start deferred novel tasks", zap.Any("request", req), zap.Any("result", result),
+			zap.Error(err))
+		abortWithParamError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, base.Success(result))
+}
+
+// ensureStartAllowed checks the operator role on the site of the catalog and the sites of the selected tasks,
+// the tasks are only retrieved if the role isn't granted on all the sites
+func (h *TaskHandler) ensureStartAllowed(c *gin.Context, req *dto.StartTasksRequest) bool {
+	if apiKey := currentApiKey(c); apiKey == nil || apiKey.HasSiteRole("", base.RoleOperator) {
+		return true
+	}
+	if req.CatalogId != "" {
+		catalogId := ensureValidId(c, req.CatalogId)
+		if catalogId == nil {
+			return false
+		}
+		catalog, err := service.CatalogService.FindById(c, *catalogId)
+		if err != nil {
+			zap.L().Warn("failed to find catalog", zap.String("catalogId", req.CatalogId), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+			return false
+		}
+		if catalog == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, base.Fails(c, base.ErrorCode.NotFound))
+			return false
+		}
+		if !ensureSiteIdRole(c, catalog.SiteId, base.RoleOperator) {
+			return false
+		}
+	}
+	for _, id := range req.Ids {
+		taskId := ensureValidId(c, id)
+		if taskId == nil {
+			return false
+		}
+		task, err := service.NovelTaskService.FindById(c, *taskId)
+		if err != nil {
+			zap.L().Warn("failed to find novel task", zap.String("taskId", id), zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+			return false
+		}
+		//the missing tasks are skipped while starting
+		if task != nil && !ensureSiteRole(c, task.SiteName, base.RoleOperator) {
+			return false
+		}
+	}
+	return true
 }
 
 // CreateCatalogPageTask handler for catalog page request and to parse the novel links for further processing
@@ -76,12 +313,15 @@ func (h *TaskHandler) CreateCatalogPageTask(c *gin.Context) {
 	var sp stream.TaskProcessor
 	var site *entity.Site
 	var hasError bool
-	var urls []string
+	var pageUrls *base.PageUrls
 	var err error
 
 	if site, hasError = h.getTaskEntity(c, pageTask.CatalogId); hasError {
 		return
 	}
+	if !ensureSiteRole(c, site.Name, base.RoleOperator) {
+		return
+	}
 
 	//if multiple pages need to handle
 	if sp = stream.GetSiteTaskProcessor(site.Name); sp == nil {
@@ -89,8 +329,8 @@ func (h *TaskHandler) CreateCatalogPageTask(c *gin.Context) {
 		zap.L().Warn("no processor found for this siteKey", zap.String("siteKey", site.Name))
 		return
 	}
-	//parse all page urls if page parameter is specified in such format: page=1-5
-	urls, err = sp.ParsePageUrls(site.Name, pageTask.Url)
+	//parse all page urls if page parameter is specified in such format: page=1-5, see base.PageSpec
+	pageUrls, err = sp.ParsePageUrls(c, site.Name, pageTask.Url)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, base.FailsWithMessage(base.ErrorCode.IllegalPageUrl, err.Error()))
 		zap.L().Warn("failed to process pageUrl", zap.String("pageUrl", pageTask.Url), zap.Error(err))
@@ -98,7 +338,7 @@ func (h *TaskHandler) CreateCatalogPageTask(c *gin.Context) {
 	}
 
 	// publish corresponding messages for these urls
-	for _, url := range urls {
+	for i, url := range pageUrls.Urls {
 		if url == "" {
 			zap.L().Warn("invalid page url", zap.String("pageUrl", url))
 			continue
@@ -106,12 +346,18 @@ func (h *TaskHandler) CreateCatalogPageTask(c *gin.Context) {
 
 		//construct  a catalog page message
 		pageMsg := &entity.CatalogPageTask{
-			SiteName:   site.Name,
-			CatalogId:  pageTask.CatalogId,
-			Url:        url,
-			Attributes: pageTask.Attributes,
-			Status:     base.TaskStatusNotStared,
+			SiteName:    site.Name,
+			CatalogId:   pageTask.CatalogId,
+			Url:         url,
+			Attributes:  pageTask.Attributes,
+			Status:      base.TaskStatusNotStared,
+			DownloadNow: pageTask.DownloadNow,
+		}
+		//the open-ended range continues from the last page
+		if i == len(pageUrls.Urls)-1 {
+			pageMsg.Continuation = pageUrls.Continuation
 		}
+		pageMsg.NextPages = pageUrls.NextPages
 
 		//publish it
 		if err = system.GetSystem().RedisClient.PublishMessage(c, pageMsg, stream.CatalogPageUrlStream); err != nil {
@@ -121,7 +367,7 @@ func (h *TaskHandler) CreateCatalogPageTask(c *gin.Context) {
 			return
 		}
 	}
-	zap.S().Info("published", strconv.Itoa(len(urls)), "task messages for catalog page:", pageTask.Url)
+	zap.S().Info("published", strconv.Itoa(len(pageUrls.Urls)), "task messages for catalog page:", pageTask.Url)
 	c.Status(http.StatusAccepted)
 }
 
@@ -173,6 +419,9 @@ func (h *TaskHandler) CreateNovelPageTask(c *gin.Context) {
 	if site, hasError = h.getTaskEntity(c, novelTask.CatalogId); hasError {
 		return
 	}
+	if !ensureSiteRole(c, site.Name, base.RoleOperator) {
+		return
+	}
 	novelTask.Status = base.TaskStatusNotStared
 	novelTask.SiteName = site.Name
 
@@ -202,5 +451,6 @@ func (h *TaskHandler) DeleteNovelPageTasks(c *gin.Context) {
 		return
 	}
 	zap.S().Info("novel page(s) deleted", ids)
+	addAuditIds(c, idArray...)
 	c.Status(http.StatusNoContent)
 }
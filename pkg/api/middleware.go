@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// authenticate requires a valid api key in the X-Api-Key header, as a bearer token or as the password of the
+// basic auth, the key and the caller identity are set into the context. The mutating requests are audited with
+// the name of the key, the caller of a rejected request is the prefix of its key.
+func authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(base.HeaderApiKey)
+		if key == "" {
+			if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+				key = token
+			} else if _, password, ok := c.Request.BasicAuth(); ok {
+				key = password
+			}
+		}
+		apiKey, err := service.ApiKeyService.Authenticate(c, strings.TrimSpace(key))
+		if err != nil {
+			zap.L().Error("failed to authenticate", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, base.FailsWithError(c, err))
+			return
+		}
+		if apiKey == nil {
+			if key = strings.TrimSpace(key); key != "" {
+				c.Set(base.ContextKeyCaller, "rejected:"+service.KeyPrefix(key))
+			}
+			zap.L().Warn("unauthenticated request", zap.String("method", c.Request.Method),
+				zap.String("uri", c.Request.RequestURI), zap.String("clientIp", c.ClientIP()))
+			//the e-readers prompt for the credentials of the basic auth
+			c.Header("WWW-Authenticate", `Basic realm="crawlers"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, base.Fails(c, base.ErrorCode.Unauthorized))
+			return
+		}
+		c.Set(base.ContextKeyApiKey, apiKey)
+		c.Set(base.ContextKeyCaller, apiKey.Name)
+
+		c.Next()
+
+		if isMutating(c.Request.Method) {
+			zap.L().Info("audit", zap.String("apiKey", apiKey.Name), zap.String("method", c.Request.Method),
+				zap.String("uri", c.Request.RequestURI), zap.Int("status", c.Writer.Status()),
+				zap.String("clientIp", c.ClientIP()))
+		}
+	}
+}
+
+// audit records the mutating requests into the audit logs after they are handled, including the rejected ones.
+// The affected ids are the id parameters of the route and the ones added by the handlers. The X-Caller-Id header
+// is only trusted if the authentication is disabled, otherwise the caller without a valid key is anonymous.
+func audit(settings *service.AuditSettings, authEnabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		body := peekBody(c, settings.BodyLimit())
+
+		c.Next()
+
+		params := make(map[string]string)
+		var affectedIds []string
+		for _, param := range c.Params {
+			params[param.Key] = param.Value
+			if strings.HasSuffix(param.Key, "Id") {
+				affectedIds = append(affectedIds, param.Value)
+			}
+		}
+		for key, values := range c.Request.URL.Query() {
+			params[key] = strings.Join(values, ",")
+		}
+		caller := c.GetString(base.ContextKeyCaller)
+		if caller == "" && !authEnabled {
+			caller = strings.TrimSpace(c.GetHeader(base.HeaderCallerId))
+		}
+		if caller == "" && authEnabled {
+			caller = base.CallerAnonymous
+		}
+		log := &entity.AuditLog{
+			Caller:      caller,
+			Method:      c.Request.Method,
+			Route:       c.FullPath(),
+			Uri:         c.Request.RequestURI,
+			Params:      params,
+			Body:        body,
+			AffectedIds: append(affectedIds, c.GetStringSlice(base.ContextKeyAudit)...),
+			Status:      c.Writer.Status(),
+			ClientIp:    c.ClientIP(),
+		}
+		zap.L().Info("audit", zap.String("caller", log.Caller), zap.String("method", log.Method),
+			zap.String("uri", log.Uri), zap.Int("status", log.Status), zap.String("clientIp", log.ClientIp))
+		if err := service.AuditService.Record(c, log); err != nil {
+			zap.L().Error("failed to record audit log", zap.String("uri", log.Uri), zap.Error(err))
+		}
+	}
+}
+
+// peekBody returns the json body without consuming it, the part beyond the limit is dropped
+func peekBody(c *gin.Context, limit int) string {
+	if c.Request.Body == nil || c.ContentType() != binding.MIMEJSON {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), c.Request.Body), c.Request.Body}
+	if err != nil {
+		zap.L().Warn("failed to read the request body", zap.Error(err))
+		return ""
+	}
+	if len(data) > limit {
+		return base.RedactJson(strings.ToValidUTF8(string(data[:limit]), "")) + "..."
+	}
+	return base.RedactJson(string(data))
+}
+
+// requireScope rejects the request if the authenticated key isn't granted the scope
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(base.ContextKeyApiKey)
+		if apiKey, ok := value.(*entity.ApiKey); !ok || !apiKey.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden,
+				base.FailsWithParams(c, base.ErrorCode.Forbidden, map[string]string{"name": scope}))
+			return
+		}
+		c.Next()
+	}
+}
+
+// scopeGroup returns a group of routes requiring the scope, or the parent group if the authentication is disabled
+func scopeGroup(parent *gin.RouterGroup, authEnabled bool, scope string) *gin.RouterGroup {
+	if !authEnabled {
+		return parent
+	}
+	return parent.Group("", requireScope(scope))
+}
+
+// basicAuth protects /metrics and /swagger if the username is configured
+func basicAuth(auth *service.AuthSettings) []gin.HandlerFunc {
+	if auth == nil || auth.BasicAuth.Username == "" {
+		return nil
+	}
+	return []gin.HandlerFunc{gin.BasicAuth(gin.Accounts{auth.BasicAuth.Username: auth.BasicAuth.Password})}
+}
+
+func isMutating(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"crawlers/pkg/api/apitest"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"crawlers/pkg/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const authConfig = `
+auth:
+  enabled: true
+  adminKey: config-admin-key
+  basicAuth:
+    username: ops
+    password: ops-password
+`
+
+// loadConfig the previous config is restored once the test finishes
+func loadConfig(t *testing.T, yamlConfig string) {
+	previous := service.ConfigService
+	t.Cleanup(func() {
+		service.ConfigService = previous
+	})
+	service.ConfigService = service.NewConfigService()
+	if err := service.ConfigService.LoadInternalConfig(yamlConfig, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeApiKeyRepo the keys are found by the hashes of the plain keys
+type fakeApiKeyRepo struct {
+	keys map[string]*entity.ApiKey
+}
+
+func (f *fakeApiKeyRepo) FindAll(context.Context) ([]*entity.ApiKey, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) FindById(context.Context, primitive.ObjectID) (*entity.ApiKey, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) FindByHash(_ context.Context, hash string) (*entity.ApiKey, error) {
+	return f.keys[hash], nil
+}
+
+func (f *fakeApiKeyRepo) ExistsByName(context.Context, string) (bool, error) {
+	return false, errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) Insert(context.Context, *entity.ApiKey) (*primitive.ObjectID, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) Update(context.Context, *entity.ApiKey) error {
+	return errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) DeleteById(context.Context, primitive.ObjectID) (bool, error) {
+	return false, errors.New("not supported")
+}
+
+func (f *fakeApiKeyRepo) UpdateLastUsed(context.Context, primitive.ObjectID, time.Time) error {
+	return nil
+}
+
+// fakeAuditService keeps the audit logs recorded
+type fakeAuditService struct {
+	service.AuditServiceInterface
+	logs []*entity.AuditLog
+}
+
+func (f *fakeAuditService) Record(_ *gin.Context, log *entity.AuditLog) error {
+	f.logs = append(f.logs, log)
+	return nil
+}
+
+// useApiKeys the keys are granted the scopes, a key with no scope is disabled
+func useApiKeys(t *testing.T, keys map[string][]string) {
+	fake := &fakeApiKeyRepo{keys: map[string]*entity.ApiKey{}}
+	for key, scopes := range keys {
+		fake.keys[base.HashText(key)] = &entity.ApiKey{Name: key, Scopes: scopes, Disabled: len(scopes) == 0}
+	}
+	previousRepo, previousService := repository.ApiKeyRepo, service.ApiKeyService
+	repository.ApiKeyRepo, service.ApiKeyService = fake, service.NewApiKeyService()
+	t.Cleanup(func() {
+		repository.ApiKeyRepo, service.ApiKeyService = previousRepo, previousService
+	})
+}
+
+// newAuthEngine the routes respond the caller if they're allowed
+func newAuthEngine() *gin.Engine {
+	engine := gin.New()
+	engine.Use(apitest.Localize())
+	group := engine.Group(base.ApiPrefix, authenticate())
+	respond := func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(base.ContextKeyCaller))
+	}
+	scopeGroup(group, true, base.ScopeRead).GET("/read", respond)
+	scopeGroup(group, true, base.ScopeAdmin).GET("/admin", respond)
+	return engine
+}
+
+func TestAuthenticate(t *testing.T) {
+	loadConfig(t, authConfig)
+	useApiKeys(t, map[string][]string{"reader-key": {base.ScopeRead}, "disabled-key": nil})
+	engine := newAuthEngine()
+
+	basic := httptest.NewRequest(http.MethodGet, base.ApiPrefix+"/read", nil)
+	basic.SetBasicAuth("e-reader", "reader-key")
+	cases := []struct {
+		name   string
+		path   string
+		header map[string]string
+		req    *http.Request
+		status int
+		caller string
+	}{
+		{name: "missing key", path: "/read", status: http.StatusUnauthorized},
+		{name: "wrong key", path: "/read", header: map[string]string{base.HeaderApiKey: "wrong-key"},
+			status: http.StatusUnauthorized},
+		{name: "disabled key", path: "/read", header: map[string]string{base.HeaderApiKey: "disabled-key"},
+			status: http.StatusUnauthorized},
+		{name: "basic auth of another scheme", path: "/read", header: map[string]string{"Authorization": "reader-key"},
+			status: http.StatusUnauthorized},
+		{name: "x-api-key", path: "/read", header: map[string]string{base.HeaderApiKey: "reader-key"},
+			status: http.StatusOK, caller: "reader-key"},
+		{name: "bearer", path: "/read", header: map[string]string{"Authorization": "Bearer reader-key"},
+			status: http.StatusOK, caller: "reader-key"},
+		{name: "basic auth", req: basic, status: http.StatusOK, caller: "reader-key"},
+		{name: "insufficient scope", path: "/admin", header: map[string]string{base.HeaderApiKey: "reader-key"},
+			status: http.StatusForbidden},
+		{name: "admin key", path: "/admin", header: map[string]string{"Authorization": "Bearer config-admin-key"},
+			status: http.StatusOK, caller: "admin"},
+		{name: "admin key implies read", path: "/read", header: map[string]string{base.HeaderApiKey: "config-admin-key"},
+			status: http.StatusOK, caller: "admin"},
+		{name: "admin key with a different length", path: "/admin",
+			header: map[string]string{base.HeaderApiKey: "config-admin-key-"}, status: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := c.req
+		if req == nil {
+			req = httptest.NewRequest(http.MethodGet, base.ApiPrefix+c.path, nil)
+			for key, value := range c.header {
+				req.Header.Set(key, value)
+			}
+		}
+		resp := apitest.Serve(engine, req)
+		if resp.Code != c.status || (c.status == http.StatusOK && resp.Body.String() != c.caller) {
+			t.Errorf("%v: unexpected response %v %v", c.name, resp.Code, resp.Body.String())
+		}
+		if c.status == http.StatusUnauthorized && resp.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: the challenge of the basic auth should be sent", c.name)
+		}
+	}
+}
+
+func TestProtectedEndpoints(t *testing.T) {
+	loadConfig(t, authConfig)
+	useApiKeys(t, map[string][]string{"tasks-key": {base.ScopeSubmitTasks}})
+	engine := RegisterEndpoints(apitest.Localize())
+
+	metrics := func(username, password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		return apitest.Serve(engine, req).Code
+	}
+	if status := metrics("", ""); status != http.StatusUnauthorized {
+		t.Errorf("/metrics should require the basic auth, but it responds %v", status)
+	}
+	if status := metrics("ops", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("/metrics should reject the wrong password, but it responds %v", status)
+	}
+	if status := metrics("ops", "ops-password"); status != http.StatusOK {
+		t.Errorf("/metrics should be served with the basic auth, but it responds %v", status)
+	}
+
+	opds := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, base.OpdsPrefix+"/search?q=a", nil)
+		if key != "" {
+			req.SetBasicAuth("e-reader", key)
+		}
+		return apitest.Serve(engine, req).Code
+	}
+	if status := opds(""); status != http.StatusUnauthorized {
+		t.Errorf("the opds catalog should require an api key, but it responds %v", status)
+	}
+	if status := opds("tasks-key"); status != http.StatusForbidden {
+		t.Errorf("the opds catalog should require the read scope, but it responds %v", status)
+	}
+}
+
+func TestAuditCaller(t *testing.T) {
+	loadConfig(t, authConfig)
+	useApiKeys(t, map[string][]string{"ck_1234567-writer": {base.ScopeSubmitTasks}})
+	fake := &fakeAuditService{}
+	previous := service.AuditService
+	service.AuditService = fake
+	t.Cleanup(func() {
+		service.AuditService = previous
+	})
+
+	post := func(authEnabled bool, header map[string]string) string {
+		engine := gin.New()
+		engine.Use(apitest.Localize())
+		group := engine.Group(base.ApiPrefix, audit(&service.AuditSettings{Enabled: true}, authEnabled))
+		if authEnabled {
+			group.Use(authenticate())
+		}
+		group.POST("/tasks", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodPost, base.ApiPrefix+"/tasks", nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		apitest.Serve(engine, req)
+		return fake.logs[len(fake.logs)-1].Caller
+	}
+
+	// the X-Caller-Id header can't be trusted if the authentication is enabled
+	cases := []struct {
+		name   string
+		header map[string]string
+		caller string
+	}{
+		{"missing key", nil, base.CallerAnonymous},
+		{"caller id", map[string]string{base.HeaderCallerId: "someone"}, base.CallerAnonymous},
+		{"rejected key", map[string]string{base.HeaderApiKey: "ck_7654321-unknown", base.HeaderCallerId: "someone"},
+			"rejected:ck_7654321"},
+		{"valid key", map[string]string{base.HeaderApiKey: "ck_1234567-writer", base.HeaderCallerId: "someone"},
+			"ck_1234567-writer"},
+	}
+	for _, c := range cases {
+		if caller := post(true, c.header); caller != c.caller {
+			t.Errorf("%v: the caller should be %v, but it's %v", c.name, c.caller, caller)
+		}
+	}
+	if caller := post(false, map[string]string{base.HeaderCallerId: "someone"}); caller != "someone" {
+		t.Errorf("the caller id should be used without authentication, but it's %v", caller)
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	_ "crawlers/docs"
 	"crawlers/pkg/api/handler"
+	"crawlers/pkg/base"
+	"crawlers/pkg/service"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -33,36 +35,115 @@ func RegisterEndpoints(localeCfg gin.HandlerFunc) *gin.Engine {
 
 	hd := handler.NewTaskHandler()
 	siteHandler := handler.NewSiteHandler()
+	chapterHandler := handler.NewChapterHandler()
+	searchHandler := handler.NewSearchHandler()
+	novelHandler := handler.NewNovelHandler()
+	readerHandler := handler.NewReaderHandler()
+	opdsHandler := handler.NewOpdsHandler()
+	apiKeyHandler := handler.NewApiKeyHandler()
+	auditHandler := handler.NewAuditHandler()
 
 	//gin-swagger 同时还提供了 DisablingWrapHandler 函数，方便我们通过设置某些环境变量来禁用Swagger。
 	//此时如果将环境变量 NAME_OF_ENV_VARIABLE设置为任意值，则 /swagger/*any 将返回404响应，就像未指定路由时一样
 	//engine.GET("/swagger/*any", ginSwagger.DisablingWrapHandler(swaggerFiles.taskHandler, "NAME_OF_ENV_VARIABLE"))
 	// no prefix /api/v1
-	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	cfg := service.ConfigService.GetConfig()
+	auth := cfg.Auth
+	authEnabled := auth != nil && auth.Enabled
+	engine.GET("/swagger/*any", append(basicAuth(auth), ginSwagger.WrapHandler(swaggerFiles.Handler))...)
 	engine.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
-	engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	engine.GET("/metrics", append(basicAuth(auth), gin.WrapH(promhttp.Handler()))...)
 
-	// with prefix /api/v1
-	routerGroup := engine.Group("/api/v1")
+	// OPDS catalog for the e-reader apps, no prefix /api/v1. The e-readers send the api key as the password
+	// of the basic auth.
+	opdsGroup := engine.Group(base.OpdsPrefix)
+	if authEnabled {
+		opdsGroup.Use(authenticate(), requireScope(base.ScopeRead))
+	}
+	opdsGroup.GET("", opdsHandler.Root)
+	opdsGroup.GET("/opensearch.xml", opdsHandler.OpenSearch)
+	opdsGroup.GET("/search", opdsHandler.Search)
+	opdsGroup.GET("/sites/:siteId", opdsHandler.Site)
+	opdsGroup.GET("/catalogs/:catalogId", opdsHandler.Catalog)
+	opdsGroup.GET("/novels/:novelId/cover", opdsHandler.Cover)
+	opdsGroup.GET("/novels/:novelId/epub", opdsHandler.Epub)
+	opdsGroup.GET("/novels/:novelId/cbz", opdsHandler.Cbz)
 
-	routerGroup.GET("/sites", siteHandler.FindSites)
-	routerGroup.GET("/sites/:siteId", siteHandler.FindSiteById)
-	routerGroup.DELETE("/sites/:siteId", siteHandler.DeleteSite)
-	routerGroup.GET("/sites/:siteId/catalogs", siteHandler.FindSiteCatalogs)
+	// with prefix /api/v1, the api key is required if the authentication is enabled
+	routerGroup := engine.Group(base.ApiPrefix)
+	if cfg.Audit != nil && cfg.Audit.Enabled {
+		//audit first so that the rejected requests are recorded as well
+		routerGroup.Use(audit(cfg.Audit, authEnabled))
+	}
+	if authEnabled {
+		routerGroup.Use(authenticate())
+	}
+	readGroup := scopeGroup(routerGroup, authEnabled, base.ScopeRead)
+	taskGroup := scopeGroup(routerGroup, authEnabled, base.ScopeSubmitTasks)
+	adminGroup := scopeGroup(routerGroup, authEnabled, base.ScopeAdmin)
+	//the roles on the sites are checked in the handlers, a key may only be granted roles on some sites
+	siteGroup := routerGroup
 
-	routerGroup.GET("/sites/:siteId/settings", siteHandler.FindSiteSettings)
+	readGroup.GET("/sites", siteHandler.FindSites)
+	siteGroup.GET("/sites/:siteId", siteHandler.FindSiteById)
+	siteGroup.GET("/sites/:siteId/catalogs", siteHandler.FindSiteCatalogs)
+	siteGroup.GET("/sites/:siteId/settings", siteHandler.FindSiteSettings)
+	siteGroup.GET("/sites/:siteId/settings/effective", siteHandler.FindEffectiveSiteSettings)
+	siteGroup.GET("/catalogs/:catalogId", siteHandler.FindCatalogById)
 
-	routerGroup.GET("/tasks/catalog-pages", hd.FindTasksOfCatalogPage)
-	routerGroup.GET("/tasks/novels", hd.FindTasksOfNovel)
+	readGroup.GET("/catalogs/:catalogId/novels", novelHandler.FindCatalogNovels)
+	readGroup.GET("/novels/:novelId", novelHandler.FindNovelById)
+	readGroup.GET("/novels/:novelId/chapters", novelHandler.FindNovelChapters)
 
-	routerGroup.POST("/catalogs", siteHandler.CreateCatalog)
-	routerGroup.POST("/catalogs/:catalogId", siteHandler.FindCatalogById)
-	routerGroup.POST("/sites", siteHandler.CreateSite)
-	routerGroup.POST("/tasks/catalog-pages", hd.CreateCatalogPageTask)
-	routerGroup.POST("/tasks/novels", hd.CreateNovelPageTask)
+	readGroup.GET("/chapters/:chapterId/content", chapterHandler.FindChapterContent)
+	readGroup.GET("/chapters/:chapterId/assets", chapterHandler.FindChapterAssets)
+	readGroup.GET("/chapters/:chapterId/assets/:page", chapterHandler.ServeChapterAsset)
+	readGroup.GET("/chapters/:chapterId/revisions", chapterHandler.FindContentRevisions)
+	readGroup.GET("/chapters/:chapterId/revisions/diff", chapterHandler.DiffContentRevisions)
 
-	routerGroup.DELETE("/tasks/novels", hd.DeleteNovelPageTasks)
+	readGroup.GET("/search", searchHandler.Search)
+
+	//the reading progress and bookmarks belong to the caller
+	readGroup.PUT("/me/progress/:novelId", readerHandler.SaveProgress)
+	readGroup.GET("/me/progress/:novelId", readerHandler.FindProgress)
+	readGroup.GET("/me/continue-reading", readerHandler.ContinueReading)
+	readGroup.POST("/me/bookmarks", readerHandler.CreateBookmark)
+	readGroup.GET("/me/bookmarks", readerHandler.FindBookmarks)
+	readGroup.DELETE("/me/bookmarks/:bookmarkId", readerHandler.DeleteBookmark)
+
+	siteGroup.GET("/tasks/catalog-pages", hd.FindTasksOfCatalogPage)
+	siteGroup.GET("/tasks/novels", hd.FindTasksOfNovel)
+	siteGroup.GET("/tasks/chapters", hd.FindTasksOfChapter)
+	siteGroup.GET("/tasks/novels/deferred", hd.FindDeferredNovelTasks)
+
+	siteGroup.POST("/tasks/catalog-pages", hd.CreateCatalogPageTask)
+	siteGroup.POST("/tasks/novels", hd.CreateNovelPageTask)
+	siteGroup.POST("/tasks/bulk", hd.BulkTasks)
+	siteGroup.POST("/tasks/novels/deferred/start", hd.StartDeferredNovelTasks)
+	siteGroup.POST("/tasks/novels/:taskId/start", hd.StartNovelTask)
+	taskGroup.POST("/chapters/:chapterId/assets/repair", chapterHandler.RepairChapterAssets)
 	//routerGroup.POST("/tasks/schedule-task", hd.RunScheduleTask)
 
+	adminGroup.POST("/sites", siteHandler.CreateSite)
+	siteGroup.PUT("/sites/:siteId", siteHandler.UpdateSite)
+	siteGroup.PATCH("/sites/:siteId", siteHandler.PatchSite)
+	siteGroup.DELETE("/sites/:siteId", siteHandler.DeleteSite)
+	siteGroup.PUT("/sites/:siteId/settings", siteHandler.SaveSiteSettings)
+	siteGroup.DELETE("/sites/:siteId/settings", siteHandler.DeleteSiteSettings)
+
+	siteGroup.POST("/catalogs", siteHandler.CreateCatalog)
+	siteGroup.PUT("/catalogs/:catalogId", siteHandler.UpdateCatalog)
+	siteGroup.PATCH("/catalogs/:catalogId", siteHandler.PatchCatalog)
+	siteGroup.DELETE("/catalogs/:catalogId", siteHandler.DeleteCatalog)
+
+	adminGroup.DELETE("/tasks/novels", hd.DeleteNovelPageTasks)
+
+	adminGroup.POST("/api-keys", apiKeyHandler.CreateApiKey)
+	adminGroup.GET("/api-keys", apiKeyHandler.FindApiKeys)
+	adminGroup.PATCH("/api-keys/:keyId", apiKeyHandler.PatchApiKey)
+	adminGroup.DELETE("/api-keys/:keyId", apiKeyHandler.DeleteApiKey)
+
+	adminGroup.GET("/audit", auditHandler.FindAuditLogs)
+
 	return engine
 }
@@ -16,7 +16,7 @@ type ApiResult struct {
 }
 
 func HttpStatusCode(err error) int {
-	var internalErr AppError
+	var internalErr *AppError
 	var ok bool
 	if ok = errors.As(err, &internalErr); ok {
 		if internalErr.Code == ErrorCode.OK {
@@ -47,14 +47,14 @@ func FailsWithMessage(code int, message string) *ApiResult {
 }
 
 func FailsWithError(c *gin.Context, err error) *ApiResult {
-	var internalErr AppError
+	var internalErr *AppError
 	var ok bool
 	if ok = errors.As(err, &internalErr); ok {
 
 		if internalErr.Message != "" {
 			return &ApiResult{
 				Ok:       false,
-				AppError: &internalErr,
+				AppError: internalErr,
 			}
 		}
 
@@ -85,6 +85,18 @@ func FailsWithParams(ctx *gin.Context, code int, params map[string]string) *ApiR
 	}
 }
 
+// FailsWithFieldErrors the errors of the fields are returned in FieldErrors
+func FailsWithFieldErrors(ctx *gin.Context, code int, fieldErrors FieldErrors) *ApiResult {
+	return &ApiResult{
+		Ok: false,
+		AppError: &AppError{
+			Code:    code,
+			Message: getErrMessage(ctx, code, nil),
+		},
+		FieldErrors: fieldErrors,
+	}
+}
+
 func Success(payload any) *ApiResult {
 	return &ApiResult{
 		Ok: true,
@@ -115,7 +127,7 @@ func getErrMessage(ctx *gin.Context, code int, params map[string]string) string
 				TemplateData: params,
 			})
 	} else {
-		msg = ginI18n.MustGetMessage(ctx, code)
+		msg = ginI18n.MustGetMessage(ctx, strconv.Itoa(code))
 	}
 
 	return msg
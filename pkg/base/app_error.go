@@ -1,6 +1,9 @@
 package base
 
-import "strings"
+import (
+	"sort"
+	"strings"
+)
 
 type AppError struct {
 	Code    int    `json:"code,omitempty"`
@@ -21,3 +24,31 @@ func NewAppError(code int, messages ...string) *AppError {
 		Message: msg,
 	}
 }
+
+// ParamError the value of a request parameter is invalid
+type ParamError struct {
+	Name string
+}
+
+func (e *ParamError) Error() string {
+	return "invalid parameter " + e.Name
+}
+
+// FieldErrors the invalid fields keyed by their paths, e.g. regexSettings.parsePageRegex
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	paths := make([]string, 0, len(e))
+	for path := range e {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	for i, path := range paths {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(path + ": " + e[path])
+	}
+	return sb.String()
+}
@@ -16,8 +16,42 @@ const (
 
 	BulkBatchSize = 10 // 批量保存的文档数量
 
+	ApiPrefix  = "/api/v1"
+	OpdsPrefix = "/opds"
+
 	ParentTypeChapter = "chapter"
 	ParentTypeNovel   = "novel"
+
+	SearchTypeNovel   = "novel"
+	SearchTypeChapter = "chapter"
+
+	HeaderCallerId   = "X-Caller-Id" //identity of the api caller
+	HeaderApiKey     = "X-Api-Key"
+	ContextKeyCaller = "caller"
+	ContextKeyApiKey = "apiKey"   //the authenticated api key
+	ContextKeyAudit  = "auditIds" //ids of the documents affected by a mutating request
+
+	CallerAnonymous = "anonymous" //the caller without a valid api key if the authentication is enabled
+
+	// scopes of the api keys, admin implies the others
+	ScopeRead        = "read"
+	ScopeSubmitTasks = "submit-tasks"
+	ScopeAdmin       = "admin"
+
+	// roles of the api keys on a site, a role implies the lower ones
+	RoleViewer   = "viewer"
+	RoleOperator = "operator" //submits and cancels the tasks
+	RoleAdmin    = "admin"    //edits the settings and deletes
+
+	TaskTypeCatalogPage = "catalogPage"
+	TaskTypeNovel       = "novel"
+	TaskTypeChapter     = "chapter"
+
+	// bulk actions of the tasks
+	TaskActionRequeue = "requeue" //reset the tasks and publish them into the streams again
+	TaskActionReset   = "reset"   //reset the status to not started
+	TaskActionDelete  = "delete"
+	TaskActionFinish  = "finish" //mark the tasks finished
 )
 
 // db column
@@ -32,11 +66,22 @@ const (
 	ColumnParentId    = "parentId"
 	ColumnPageNo      = "page"
 	ColumnSiteId      = "siteId"
+	ColumnHash        = "hash"
+	ColumnChapterId   = "chapterId"
+	ColumnContentId   = "contentId"
+	ColumnRevision    = "revision"
+	ColumnOrder       = "order"
+	ColumnCaller      = "caller"
+	ColumnStatus      = "status"
+	ColumnRetries     = "retries"
+	ColumnDownloadNow = "downloadNow"
+	ColumnTime        = "time"
 
 	//for catalog
 	ColumnsiteId = "siteId"
 
 	AttrAuthor = "author"
+	AttrCover  = "cover" //url of the cover picture found in the catalog page
 )
 
 // db collection
@@ -50,10 +95,41 @@ const (
 	CollectionChapterTask     = "chapterTask"
 	CollectionCatalogPageTask = "catalogPageTask"
 	CollectionContent         = "content"
+	CollectionImageHash       = "imageHash"
+	CollectionChapterAsset    = "chapterAsset"
+	CollectionContentRevision = "contentRevision"
+	CollectionReadingProgress = "readingProgress"
+	CollectionBookmark        = "bookmark"
+	CollectionApiKey          = "apiKey"
+	CollectionAuditLog        = "auditLog"
 )
 
 var ConfigFiles = []string{"/etc/crawlers/crawlers.yaml"}
 
+// the environment variables overriding the config keys, e.g. CRAWLERS_MONGODB_URI overrides mongodb.uri, the value
+// is read from the file if the name ends with _FILE, e.g. CRAWLERS_REDIS_PASSWORD_FILE=/run/secrets/redis-password
+const (
+	EnvPrefix     = "CRAWLERS_"
+	EnvFileSuffix = "_FILE"
+)
+
+// sources of the site settings in the order of precedence, a higher one overrides the lower ones
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceYaml    = "yaml"
+	ConfigSourceMongo   = "mongo"
+)
+
+// AttributeDirectory the attribute of a site specifying where the files are saved
+const AttributeDirectory = "directory"
+
+// the redis channel notifying all the instances that the settings of a site are changed, the message is
+// the site name or ConfigChangedAll
+const (
+	ChannelSiteConfigChanged = "siteConfig:changed"
+	ConfigChangedAll         = "*"
+)
+
 type CrawlerResourceType int
 
 const (
@@ -93,8 +169,52 @@ const (
 	TaskStatusRetryFailed
 )
 
+// AssetStatus 章节资源(图片)的下载状态
+type AssetStatus int
+
+const (
+	AssetStatusDownloaded AssetStatus = iota + 1
+	AssetStatusFailed
+	AssetStatusBlocked
+)
+
 var ErrDecodingDocument = errors.New("document retrieved without decoding process")
 var ErrDuplicatedDocument = errors.New("document is duplicated")
 var ErrDocumentIdExists = errors.New("document's ID exists")
+var ErrChapterNotFound = errors.New("chapter not found")
+var ErrRevisionNotFound = errors.New("revision not found")
+var ErrSiteNotFound = errors.New("site not found")
+var ErrCatalogNotFound = errors.New("catalog not found")
+var ErrTaskNotDeferred = errors.New("task is not deferred")
+var ErrApiKeyNotFound = errors.New("api key not found")
 
 const DefaultRetries = 3
+
+// image link modes for duplicated images
+const (
+	ImageLinkModeHardlink  = "hardlink"
+	ImageLinkModeReference = "reference"
+)
+
+// how the pages of a site are fetched
+const (
+	RenderHttp    = "http"    //the pages are fetched by the http client
+	RenderBrowser = "browser" //the pages are rendered by the headless browser
+)
+
+// the resource types blocked while rendering the pages in the browser
+var RenderResourceTypes = []string{"image", "media", "font", "stylesheet", "script", "xhr", "fetch", "websocket",
+	"other"}
+
+// how a proxy of the pool is picked for a request
+const (
+	ProxyStrategyRoundRobin = "round-robin" //the proxies are used in turn
+	ProxyStrategySticky     = "sticky"      //the requests of a host are sent through the same proxy
+	ProxyStrategyRandom     = "random"
+)
+
+// the pagination modes of the catalog pages
+const (
+	PageModeNumber = "number" //the page parameter is expanded into the pages, see PageSpec
+	PageModeNext   = "next"   //the next page links returned by the crawler are followed
+)
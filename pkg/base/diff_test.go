@@ -0,0 +1,43 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	lines := DiffLines("a\nb\nc\nd", "a\nc\nd\ne")
+	var ops []DiffOp
+	for _, line := range lines {
+		ops = append(ops, line.Op)
+	}
+	expected := []DiffOp{DiffEqual, DiffDelete, DiffEqual, DiffEqual, DiffInsert}
+	if len(ops) != len(expected) {
+		t.Fatalf("unexpected diff: %v", lines)
+	}
+	for i := range expected {
+		if ops[i] != expected[i] {
+			t.Errorf("line %v should be %v, but it's %v", i, expected[i], ops[i])
+		}
+	}
+	if lines[1].Text != "b" || lines[1].OldLine != 2 {
+		t.Error("the deleted line should be b")
+	}
+	if lines[4].Text != "e" || lines[4].NewLine != 4 {
+		t.Error("the inserted line should be e")
+	}
+}
+
+func TestDiffSameText(t *testing.T) {
+	for _, line := range DiffLines("a\nb", "a\nb") {
+		if line.Op != DiffEqual {
+			t.Error("all lines should be equal")
+		}
+	}
+}
+
+func TestDiffEmptyText(t *testing.T) {
+	lines := DiffLines("", "a\nb")
+	if len(lines) != 2 || lines[0].Op != DiffInsert || lines[1].Op != DiffInsert {
+		t.Errorf("all lines should be inserted: %v", lines)
+	}
+}
@@ -7,25 +7,31 @@ var ErrorCode *errorCodeInfo
 type errorCodeInfo struct {
 	OK int
 
-	NotFound   int
-	Unexpected int
-	BadRequest int
-	Required   int
-	Duplicated int
+	NotFound     int
+	Unauthorized int
+	Forbidden    int
+	Unexpected   int
+	BadRequest   int
+	Required     int
+	Duplicated   int
 
 	SiteNotFound          int
 	ProcessorNotFound     int
 	IllegalPageUrl        int
 	ExcludedNovelPageTask int
 	IdsRequired           int
+	TaskNotDeferred       int
+	InvalidSettings       int
 }
 
 func init() {
 	ErrorCode = &errorCodeInfo{
-		OK:         200,
-		NotFound:   404,
-		Unexpected: 500,
-		BadRequest: 400,
+		OK:           200,
+		NotFound:     404,
+		Unauthorized: 401,
+		Forbidden:    403,
+		Unexpected:   500,
+		BadRequest:   400,
 
 		Required:   1003,
 		Duplicated: 1004,
@@ -35,5 +41,7 @@ func init() {
 		IllegalPageUrl:        1102,
 		ExcludedNovelPageTask: 1103,
 		IdsRequired:           1104,
+		TaskNotDeferred:       1105,
+		InvalidSettings:       1106,
 	}
 }
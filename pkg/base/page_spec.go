@@ -0,0 +1,377 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultMaxPages the max number of pages a catalog page url can be expanded into
+	DefaultMaxPages = 1000
+
+	// the url of a page is generated by replacing it with the page parameter
+	pagePlaceholder = "{page}"
+
+	// the last page is probed up to it while resolving "last N"
+	maxProbedPage = 1 << 16
+)
+
+var (
+	regexMap = sync.Map{}
+
+	ErrInvalidPageSpec = errors.New("invalid page parameter")
+)
+
+// PageRange a range of the page spec, To is ignored if it's open-ended and From is ignored if Last is set
+type PageRange struct {
+	From      int
+	To        int
+	Step      int
+	OpenEnded bool
+	Last      int
+}
+
+// PageSpec the page parameter of a catalog page url, it's a list of the items separated by commas:
+//
+//	3        a single page
+//	1-8      a range, 8-1 is in reverse order
+//	1-100:2  a range with a step
+//	5-       an open-ended range crawled until a page returns no items, it should be the last item
+//	last5    the last 5 pages
+type PageSpec struct {
+	Ranges []PageRange
+}
+
+// ParsePageSpec parses the page parameter, the error wraps ErrInvalidPageSpec
+func ParsePageSpec(spec string) (*PageSpec, error) {
+	items := strings.Split(spec, ",")
+	pageSpec := &PageSpec{}
+	for i, item := range items {
+		pageRange, err := parsePageRange(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidPageSpec, item, err)
+		}
+		if pageRange.OpenEnded && i != len(items)-1 {
+			return nil, fmt.Errorf("%w %q: the open-ended range should be the last one", ErrInvalidPageSpec, item)
+		}
+		pageSpec.Ranges = append(pageSpec.Ranges, *pageRange)
+	}
+	return pageSpec, nil
+}
+
+func parsePageRange(item string) (*PageRange, error) {
+	if item == "" {
+		return nil, errors.New("empty item")
+	}
+	if last, ok := strings.CutPrefix(strings.ToLower(item), "last"); ok {
+		n, err := parsePage(strings.TrimSpace(last))
+		if err != nil || n == 0 {
+			return nil, errors.New("the number of the last pages should be a positive integer")
+		}
+		return &PageRange{Last: n, Step: 1}, nil
+	}
+
+	pageRange := &PageRange{Step: 1}
+	bounds, step, hasStep := strings.Cut(item, ":")
+	if hasStep {
+		var err error
+		if pageRange.Step, err = parsePage(step); err != nil || pageRange.Step == 0 {
+			return nil, errors.New("the step should be a positive integer")
+		}
+	}
+	from, to, isRange := strings.Cut(bounds, "-")
+	var err error
+	if pageRange.From, err = parsePage(from); err != nil {
+		return nil, err
+	}
+	switch {
+	case !isRange:
+		if hasStep {
+			return nil, errors.New("the step is only allowed in a range")
+		}
+		pageRange.To = pageRange.From
+	case to == "":
+		pageRange.OpenEnded = true
+	default:
+		if pageRange.To, err = parsePage(to); err != nil {
+			return nil, err
+		}
+	}
+	return pageRange, nil
+}
+
+func parsePage(value string) (int, error) {
+	page, err := strconv.ParseUint(value, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a page number", value)
+	}
+	return int(page), nil
+}
+
+// HasLast whether the last page is required to resolve the pages
+func (s *PageSpec) HasLast() bool {
+	for _, r := range s.Ranges {
+		if r.Last > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Pages expands the ranges into the pages, an open-ended range only contributes its first page and is
+// returned so that the following pages are crawled one by one. The lastPage is only used by "last N".
+func (s *PageSpec) Pages(lastPage, maxPages int) ([]int, *PageRange, error) {
+	var pages []int
+	var openRange *PageRange
+	add := func(page int) error {
+		if len(pages) >= maxPages {
+			return fmt.Errorf("%w: more than %v pages", ErrInvalidPageSpec, maxPages)
+		}
+		pages = append(pages, page)
+		return nil
+	}
+	for i := range s.Ranges {
+		r := s.Ranges[i]
+		switch {
+		case r.Last > 0:
+			r.From, r.To = lastPage-r.Last+1, lastPage
+			if r.From < 1 {
+				r.From = 1
+			}
+		case r.OpenEnded:
+			openRange = &s.Ranges[i]
+			r.To = r.From
+		}
+		if r.From <= r.To {
+			for page := r.From; page <= r.To; page += r.Step {
+				if err := add(page); err != nil {
+					return nil, nil, err
+				}
+			}
+		} else {
+			for page := r.From; page >= r.To; page -= r.Step {
+				if err := add(page); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	return pages, openRange, nil
+}
+
+// PageOptions how the urls of the pages are generated
+type PageOptions struct {
+	Prefix string
+	Suffix string
+
+	// the page parameter is an item offset if it's set, e.g. start=0&count=20, the page n(starting from 1)
+	// is converted into the offset (n-1)*PageSize
+	PageSize int
+
+	// DefaultMaxPages is used if it's not set
+	MaxPages int
+
+	// whether a page has any items without side effects, it's used to probe the last page for "last N" which is
+	// rejected if it's nil
+	HasItems func(url string) (bool, error)
+}
+
+// PageContinuation continues an open-ended range, the next page is crawled only if the current one has items
+type PageContinuation struct {
+	// the url with the page parameter replaced by a placeholder
+	Template  string `bson:"template" json:"template"`
+	Page      int    `bson:"page" json:"page"`
+	Step      int    `bson:"step" json:"step"`
+	PageSize  int    `bson:"pageSize" json:"pageSize"`
+	Remaining int    `bson:"remaining" json:"remaining"`
+}
+
+// Next returns the url of the next page and the continuation for it, nil is returned if the cap is reached
+func (p *PageContinuation) Next() (string, *PageContinuation) {
+	if p.Remaining <= 0 {
+		return "", nil
+	}
+	next := *p
+	next.Page += p.Step
+	next.Remaining--
+	return pageUrl(p.Template, next.Page, p.PageSize), &next
+}
+
+// PageUrls the urls expanded from a catalog page url
+type PageUrls struct {
+	Urls []string
+
+	// the open-ended range continues from the last url if it's set
+	Continuation *PageContinuation
+
+	// the next page links are followed from each url if it's set
+	NextPages *NextPageChain
+}
+
+// ExpandPageUrl expands the page parameter matched by the first group of the regex into the urls of the pages,
+// the url is returned as it is if the regex doesn't match
+func ExpandPageUrl(pageRegex, url string, opts *PageOptions) (*PageUrls, error) {
+	regex, err := compilePageRegex(pageRegex)
+	if err != nil {
+		return nil, err
+	}
+	submatch := regex.FindStringSubmatch(url)
+	if len(submatch) != 2 {
+		return &PageUrls{Urls: []string{url}}, nil
+	}
+	spec, err := ParsePageSpec(submatch[1])
+	if err != nil {
+		return nil, err
+	}
+
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	template := regex.ReplaceAllLiteralString(url, opts.Prefix+pagePlaceholder+opts.Suffix)
+	lastPage := 0
+	if spec.HasLast() {
+		if opts.HasItems == nil {
+			return nil, fmt.Errorf("%w: last N isn't supported by the crawler: %w", ErrInvalidPageSpec,
+				&ParamError{Name: "url"})
+		}
+		if lastPage, err = probeLastPage(template, opts.PageSize, opts.HasItems); err != nil {
+			return nil, err
+		}
+	}
+	pages, openRange, err := spec.Pages(lastPage, maxPages)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if opts.PageSize > 0 && page == 0 {
+			return nil, fmt.Errorf("%w: the pages start from 1 for the offset-based pagination", ErrInvalidPageSpec)
+		}
+	}
+
+	pageUrls := &PageUrls{}
+	for _, page := range pages {
+		pageUrls.Urls = append(pageUrls.Urls, pageUrl(template, page, opts.PageSize))
+	}
+	if openRange != nil {
+		pageUrls.Continuation = &PageContinuation{
+			Template:  template,
+			Page:      openRange.From,
+			Step:      openRange.Step,
+			PageSize:  opts.PageSize,
+			Remaining: maxPages - len(pages),
+		}
+	}
+	return pageUrls, nil
+}
+
+// GenPageUrls 解析page=1, page=1,2-8, page=1-100:2等分页参数，并返回拼装好的URL，开放区间只返回第一页
+func GenPageUrls(pageRegex, url, pagePrefix, pageSuffix string) ([]string, error) {
+	pageUrls, err := ExpandPageUrl(pageRegex, url, &PageOptions{Prefix: pagePrefix, Suffix: pageSuffix})
+	if err != nil {
+		return nil, err
+	}
+	return pageUrls.Urls, nil
+}
+
+func compilePageRegex(pageRegex string) (*regexp.Regexp, error) {
+	if regex, ok := regexMap.Load(pageRegex); ok {
+		return regex.(*regexp.Regexp), nil
+	}
+	regex, err := regexp.Compile(pageRegex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid page regex %q: %v", ErrInvalidPageSpec, pageRegex, err)
+	}
+	regexMap.Store(pageRegex, regex)
+	return regex, nil
+}
+
+func pageUrl(template string, page, pageSize int) string {
+	value := page
+	if pageSize > 0 {
+		value = (page - 1) * pageSize
+	}
+	return strings.Replace(template, pagePlaceholder, strconv.Itoa(value), 1)
+}
+
+// probeLastPage finds the last page with items by doubling the page and then the binary search
+func probeLastPage(template string, pageSize int, hasItems func(url string) (bool, error)) (int, error) {
+	probe := func(page int) (bool, error) {
+		return hasItems(pageUrl(template, page, pageSize))
+	}
+	ok, err := probe(1)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: no pages found", ErrInvalidPageSpec)
+	}
+
+	low, high := 1, 2
+	for {
+		if high > maxProbedPage {
+			return 0, fmt.Errorf("%w: more than %v pages found", ErrInvalidPageSpec, maxProbedPage)
+		}
+		if ok, err = probe(high); err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		low, high = high, high*2
+	}
+	// low has items and high hasn't
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if ok, err = probe(mid); err != nil {
+			return 0, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low, nil
+}
+
+// NextPageChain follows the next page links from a catalog page, the urls crawled are remembered by their
+// hashes to detect the loops
+type NextPageChain struct {
+	Pages    int      `bson:"pages" json:"pages"`
+	MaxPages int      `bson:"maxPages" json:"maxPages"`
+	Visited  []string `bson:"visited" json:"visited"`
+}
+
+// NewNextPageChain DefaultMaxPages is used if maxPages isn't positive
+func NewNextPageChain(maxPages int) *NextPageChain {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	return &NextPageChain{Pages: 1, MaxPages: maxPages}
+}
+
+// Next returns the chain for the next page of the current one, an error is returned if the next url is
+// crawled already or the max pages are reached
+func (c *NextPageChain) Next(currentUrl, nextUrl string) (*NextPageChain, error) {
+	if c.Pages >= c.MaxPages {
+		return nil, fmt.Errorf("the max pages %v are reached", c.MaxPages)
+	}
+	visited := append(append([]string{}, c.Visited...), urlHash(currentUrl))
+	nextHash := urlHash(nextUrl)
+	for _, hash := range visited {
+		if hash == nextHash {
+			return nil, fmt.Errorf("the next page %v is crawled already", nextUrl)
+		}
+	}
+	return &NextPageChain{Pages: c.Pages + 1, MaxPages: c.MaxPages, Visited: visited}, nil
+}
+
+// urlHash a short hash of the url, it's enough to tell the pages of a chain apart
+func urlHash(url string) string {
+	return HashText(url)[:16]
+}
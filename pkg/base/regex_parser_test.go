@@ -1,6 +1,8 @@
 package base
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -61,3 +63,131 @@ func TestNsfPage(t *testing.T) {
 		t.Error("urls length error")
 	}
 }
+
+func TestStepAndReverseRanges(t *testing.T) {
+	var url = "https://www.baidu.com?page=1-9:4,8-6&format=json"
+	urls, err := GenPageUrls(urlRegex, url, "page=", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1", "5", "9", "8", "7", "6"}
+	if len(urls) != len(expected) {
+		t.Fatalf("unexpected urls: %v", urls)
+	}
+	for i, page := range expected {
+		if urls[i] != "https://www.baidu.com?page="+page+"&format=json" {
+			t.Errorf("url %v should be of page %v, but it's %v", i, page, urls[i])
+		}
+	}
+}
+
+func TestLastPages(t *testing.T) {
+	var url = "https://abc.com/category/18/last3.html"
+	var probed []string
+	pageUrls, err := ExpandPageUrl(nsfRegex, url, &PageOptions{
+		Suffix: ".html",
+		HasItems: func(url string) (bool, error) {
+			probed = append(probed, url)
+			var page int
+			_, err := fmt.Sscanf(url, "https://abc.com/category/18/%d.html", &page)
+			return page <= 37, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pageUrls.Urls) != 3 || pageUrls.Urls[0] != "https://abc.com/category/18/35.html" ||
+		pageUrls.Urls[2] != "https://abc.com/category/18/37.html" {
+		t.Errorf("unexpected urls: %v", pageUrls.Urls)
+	}
+	if len(probed) > 15 {
+		t.Errorf("too many pages probed: %v", len(probed))
+	}
+
+	var paramErr *ParamError
+	if _, err = GenPageUrls(nsfRegex, url, "", ".html"); !errors.Is(err, ErrInvalidPageSpec) ||
+		!errors.As(err, &paramErr) {
+		t.Errorf("last N should be rejected without probing, but the error is %v", err)
+	}
+}
+
+func TestOpenEndedRange(t *testing.T) {
+	var url = "https://www.baidu.com?page=1,3-:2&format=json"
+	pageUrls, err := ExpandPageUrl(urlRegex, url, &PageOptions{Prefix: "page=", MaxPages: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pageUrls.Urls) != 2 || pageUrls.Urls[1] != "https://www.baidu.com?page=3&format=json" {
+		t.Errorf("unexpected urls: %v", pageUrls.Urls)
+	}
+
+	continuation := pageUrls.Continuation
+	var next []string
+	for continuation != nil {
+		var nextUrl string
+		if nextUrl, continuation = continuation.Next(); continuation != nil {
+			next = append(next, nextUrl)
+		}
+	}
+	if len(next) != 2 || next[0] != "https://www.baidu.com?page=5&format=json" ||
+		next[1] != "https://www.baidu.com?page=7&format=json" {
+		t.Errorf("the open-ended range should continue up to the max pages, but it's %v", next)
+	}
+
+	if _, err = GenPageUrls(urlRegex, "https://www.baidu.com?page=3-,5", "page=", ""); err == nil {
+		t.Error("the open-ended range should be the last one")
+	}
+}
+
+func TestOffsetPages(t *testing.T) {
+	var url = "https://abc.com/list?start=1-3&count=20"
+	pageUrls, err := ExpandPageUrl("(?:start=)([^\\&]+)", url, &PageOptions{Prefix: "start=", PageSize: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"0", "20", "40"}
+	for i, offset := range expected {
+		if pageUrls.Urls[i] != "https://abc.com/list?start="+offset+"&count=20" {
+			t.Errorf("url %v should be at offset %v, but it's %v", i, offset, pageUrls.Urls[i])
+		}
+	}
+
+	if _, err = ExpandPageUrl("(?:start=)([^\\&]+)", "https://abc.com/list?start=0&count=20",
+		&PageOptions{Prefix: "start=", PageSize: 20}); err == nil {
+		t.Error("the page 0 should be rejected for the offset-based pagination")
+	}
+}
+
+func TestInvalidPageSpecs(t *testing.T) {
+	for _, spec := range []string{"1-100:0", "3:2", "a-5", "last0", "1--3", "5-3-1"} {
+		if _, err := ParsePageSpec(spec); !errors.Is(err, ErrInvalidPageSpec) {
+			t.Errorf("%v should be invalid, but the error is %v", spec, err)
+		}
+	}
+	if _, err := ExpandPageUrl(urlRegex, "https://www.baidu.com?page=1-2000", &PageOptions{}); err == nil {
+		t.Error("the pages beyond the cap should be rejected")
+	}
+	if _, err := GenPageUrls("(page=", "https://www.baidu.com?page=1", "page=", ""); err == nil {
+		t.Error("the invalid regex should be reported")
+	}
+}
+
+func TestNextPageChain(t *testing.T) {
+	chain := NewNextPageChain(3)
+	chain, err := chain.Next("https://abc.com/list", "https://abc.com/list?p=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chain.Next("https://abc.com/list?p=2", "https://abc.com/list"); err == nil {
+		t.Error("the loop should be detected")
+	}
+	if chain, err = chain.Next("https://abc.com/list?p=2", "https://abc.com/list?p=3"); err != nil {
+		t.Fatal(err)
+	}
+	if chain.Pages != 3 || len(chain.Visited) != 2 {
+		t.Errorf("unexpected chain: %+v", chain)
+	}
+	if _, err = chain.Next("https://abc.com/list?p=3", "https://abc.com/list?p=4"); err == nil {
+		t.Error("the max pages should be reached")
+	}
+}
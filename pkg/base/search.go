@@ -0,0 +1,143 @@
+package base
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+const (
+	highlightPrefix = "<em>"
+	highlightSuffix = "</em>"
+	ellipsis        = "..."
+)
+
+// isCJK 中日韩文字没有空格分词，需要按n-gram切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// Tokenize splits the text into lower case words, the CJK runs are split into bigrams(a single character is kept as is)
+// since the mongo text index only splits words by spaces and punctuations
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// SearchTokens returns the distinct tokens of the texts joined by spaces, it's saved into the text index field
+func SearchTokens(texts ...string) string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, text := range texts {
+		for _, token := range Tokenize(text) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// StripTags removes the html tags of the content
+func StripTags(content string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRegex.ReplaceAllString(content, " ")))
+}
+
+// Highlight returns a snippet around the first matched term with at most maxRunes characters,
+// the matched terms are wrapped with <em></em>
+func Highlight(text string, terms []string, maxRunes int) string {
+	runes := []rune(text)
+	lowerRunes := make([]rune, len(runes))
+	for i, r := range runes {
+		lowerRunes[i] = unicode.ToLower(r)
+	}
+
+	//mark the matched characters
+	matched := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(strings.ToLower(term))
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lowerRunes); i++ {
+			if string(lowerRunes[i:i+len(termRunes)]) == string(termRunes) {
+				for j := i; j < i+len(termRunes); j++ {
+					matched[j] = true
+				}
+				if first == -1 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		if first > maxRunes/4 {
+			start = first - maxRunes/4
+		}
+		end = start + maxRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxRunes
+		}
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString(ellipsis)
+	}
+	for i := start; i < end; i++ {
+		if matched[i] && (i == start || !matched[i-1]) {
+			builder.WriteString(highlightPrefix)
+		}
+		builder.WriteRune(runes[i])
+		if matched[i] && (i == end-1 || !matched[i+1]) {
+			builder.WriteString(highlightSuffix)
+		}
+	}
+	if end < len(runes) {
+		builder.WriteString(ellipsis)
+	}
+	return builder.String()
+}
@@ -0,0 +1,42 @@
+package base
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("斗破苍穹 Chapter1, 龙")
+	expected := []string{"斗破", "破苍", "苍穹", "chapter1", "龙"}
+	if len(tokens) != len(expected) {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+	for i := range expected {
+		if tokens[i] != expected[i] {
+			t.Errorf("token %v should be %v, but it's %v", i, expected[i], tokens[i])
+		}
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	if tokens := SearchTokens("苍穹", "苍穹 abc"); tokens != "苍穹 abc" {
+		t.Errorf("tokens should be distinct: %v", tokens)
+	}
+}
+
+func TestStripTags(t *testing.T) {
+	if text := StripTags("<p>第一章&amp;</p>"); text != "第一章&" {
+		t.Errorf("tags should be removed: %v", text)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	snippet := Highlight("从前有座山，山里有座庙", Tokenize("山里"), 0)
+	if snippet != "从前有座山，<em>山里</em>有座庙" {
+		t.Errorf("unexpected snippet: %v", snippet)
+	}
+
+	snippet = Highlight("0123456789abcdefghij", []string{"F"}, 8)
+	if snippet != "...cde<em>f</em>ghij" {
+		t.Errorf("unexpected snippet: %v", snippet)
+	}
+}
@@ -0,0 +1,192 @@
+package downloader
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/httpcache"
+	"crawlers/pkg/metrics"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"crawlers/pkg/service"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/duke-git/lancet/v2/fileutil"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+)
+
+const (
+	sha256Prefix = "sha256:"
+	pHashPrefix  = "phash:"
+
+	defaultMaxPerceptualDistance = 5
+)
+
+// Result the result of downloading an image
+type Result struct {
+	Url   string
+	Path  string //where the image is stored, it could be an existing file while it's referenced
+	Size  int64
+	Hash  string
+	PHash string
+
+	Duplicated bool //an existing image is reused
+	Blocked    bool //skipped since the image is in the blocklist of the site
+}
+
+// DownloadImage downloads an image and saves it into destFile, see SaveImage
+func DownloadImage(ctx context.Context, siteName, picUrl, destFile string) (*Result, error) {
+	restyClient, err := httpcache.GetRestyClient(siteName, picUrl)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := restyClient.R().SetContext(ctx).Get(picUrl)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("failed to download image %v: %v", picUrl, resp.Status())
+	}
+	return SaveImage(ctx, siteName, picUrl, resp.Body(), destFile)
+}
+
+// SaveImage saves the image data into destFile.
+// The sha-256 of the data is checked against the blocklist of the site first, blocked images are skipped entirely.
+// If deduplication is enabled for the site and the same image has been downloaded before,
+// the existing file is hard linked(or only referenced) instead of rewriting it.
+func SaveImage(ctx context.Context, siteName, picUrl string, data []byte, destFile string) (*Result, error) {
+	var imgSettings entity.ImageSettings
+	if cfg := service.ConfigService.GetSiteConfig(siteName); cfg != nil && cfg.ImageSettings != nil {
+		imgSettings = *cfg.ImageSettings
+	}
+
+	sum := sha256.Sum256(data)
+	result := &Result{
+		Url:  picUrl,
+		Path: destFile,
+		Size: int64(len(data)),
+		Hash: hex.EncodeToString(sum[:]),
+	}
+
+	blockedHashes, blockedPHashes := parseBlockedHashes(imgSettings.BlockedHashes)
+	if imgSettings.PerceptualHash || len(blockedPHashes) > 0 {
+		if pHash, err := PerceptualHash(data); err != nil {
+			zap.L().Debug("unable to compute perceptual hash", zap.String("url", picUrl), zap.Error(err))
+		} else {
+			result.PHash = pHash
+		}
+	}
+
+	if isBlocked(result, blockedHashes, blockedPHashes, imgSettings.MaxPerceptualDistance) {
+		metrics.MetricsComicPicBlocked.Inc()
+		zap.L().Info("image skipped since it's blocked", zap.String("url", picUrl),
+			zap.String("hash", result.Hash), zap.String("siteName", siteName))
+		result.Blocked = true
+		result.Path = ""
+		return result, nil
+	}
+
+	if !imgSettings.Deduplicate {
+		return result, os.WriteFile(destFile, data, 0644)
+	}
+
+	existing, err := repository.ImageHashRepo.FindByHash(ctx, result.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Path != destFile && fileutil.IsExist(existing.Path) {
+		if result.Path, err = linkImage(imgSettings.LinkMode, existing.Path, destFile, data); err != nil {
+			return nil, err
+		}
+		result.Duplicated = true
+		metrics.MetricsComicPicDeduplicated.Inc()
+		zap.L().Info("duplicated image reused", zap.String("url", picUrl),
+			zap.String("existingFile", existing.Path), zap.String("path", result.Path))
+		return result, repository.ImageHashRepo.IncreaseRefCount(ctx, existing.Id)
+	}
+
+	if err = os.WriteFile(destFile, data, 0644); err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		//the indexed file no longer exists
+		return result, repository.ImageHashRepo.UpdatePath(ctx, existing.Id, destFile)
+	}
+
+	if _, err = repository.ImageHashRepo.Insert(ctx, &entity.ImageHash{
+		Hash:     result.Hash,
+		PHash:    result.PHash,
+		Path:     destFile,
+		Size:     result.Size,
+		SiteName: siteName,
+	}); err != nil {
+		//another chapter might index the same image concurrently
+		zap.L().Warn("failed to index image hash", zap.String("hash", result.Hash), zap.Error(err))
+	}
+	return result, nil
+}
+
+// linkImage links the existing image to destFile, the image is written if a hardlink can't be created
+func linkImage(linkMode, existingFile, destFile string, data []byte) (string, error) {
+	if linkMode == base.ImageLinkModeReference {
+		return existingFile, nil
+	}
+	if fileutil.IsExist(destFile) {
+		if err := os.Remove(destFile); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Link(existingFile, destFile); err != nil {
+		zap.L().Warn("unable to create a hardlink, the image is written instead",
+			zap.String("existingFile", existingFile), zap.String("destFile", destFile), zap.Error(err))
+		return destFile, os.WriteFile(destFile, data, 0644)
+	}
+	return destFile, nil
+}
+
+// parseBlockedHashes splits the blocklist into sha-256 hashes and perceptual hashes
+func parseBlockedHashes(blockedHashes []string) (map[string]bool, []uint64) {
+	hashes := map[string]bool{}
+	var pHashes []uint64
+	for _, item := range blockedHashes {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if strings.HasPrefix(item, pHashPrefix) {
+			if pHash, err := ParsePHash(strings.TrimPrefix(item, pHashPrefix)); err != nil {
+				zap.L().Warn("invalid perceptual hash in blocklist", zap.String("hash", item))
+			} else {
+				pHashes = append(pHashes, pHash)
+			}
+			continue
+		}
+		if item != "" {
+			hashes[strings.TrimPrefix(item, sha256Prefix)] = true
+		}
+	}
+	return hashes, pHashes
+}
+
+func isBlocked(result *Result, blockedHashes map[string]bool, blockedPHashes []uint64, maxDistance int) bool {
+	if blockedHashes[result.Hash] {
+		return true
+	}
+	if result.PHash == "" || len(blockedPHashes) == 0 {
+		return false
+	}
+	pHash, err := ParsePHash(result.PHash)
+	if err != nil {
+		return false
+	}
+	if maxDistance <= 0 {
+		maxDistance = defaultMaxPerceptualDistance
+	}
+	for _, blocked := range blockedPHashes {
+		if HammingDistance(pHash, blocked) <= maxDistance {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package downloader
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/metrics"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"github.com/duke-git/lancet/v2/fileutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Manifest records the assets(pages) downloaded for a chapter into the chapterAsset collection
+type Manifest struct {
+	siteName string
+	chapter  *entity.Chapter
+}
+
+// RepairResult the result of repairing the assets of a chapter
+type RepairResult struct {
+	ChapterId primitive.ObjectID `json:"chapterId"`
+	Total     int                `json:"total"`    //number of assets recorded for the chapter
+	Repaired  int                `json:"repaired"` //re-downloaded successfully
+	Failed    int                `json:"failed"`   //still failed after repairing
+}
+
+// NewManifest returns the manifest of a chapter, the chapter is created if it doesn't exist yet
+func NewManifest(ctx context.Context, siteName string, chapterTask *entity.ChapterTask) (*Manifest, error) {
+	chapter, err := repository.ChapterRepo.FindByNovelIdAndName(ctx, chapterTask.NovelId, chapterTask.Name)
+	if err != nil {
+		return nil, err
+	}
+	if chapter == nil {
+		createdTime := time.Now()
+		chapter = &entity.Chapter{
+			NovelId:     chapterTask.NovelId,
+			Name:        chapterTask.Name,
+			Order:       chapterTask.Order,
+			Attributes:  map[string]interface{}{base.ColumnUrl: chapterTask.Url},
+			CreatedTime: &createdTime,
+		}
+		id, err := repository.ChapterRepo.Insert(ctx, chapter)
+		if err != nil {
+			return nil, err
+		}
+		chapter.Id = *id
+	}
+	return &Manifest{siteName: siteName, chapter: chapter}, nil
+}
+
+// Download downloads the page of the chapter and records the result, see DownloadImage
+func (m *Manifest) Download(ctx context.Context, page int, picUrl, destFile string) (*Result, error) {
+	result, err := DownloadImage(ctx, m.siteName, picUrl, destFile)
+	if err != nil {
+		m.Fail(ctx, page, picUrl, destFile, err)
+		return nil, err
+	}
+	asset := m.newAsset(page, picUrl, result.Path)
+	asset.Size = result.Size
+	asset.Hash = result.Hash
+	asset.Status = base.AssetStatusDownloaded
+	if result.Blocked {
+		asset.Status = base.AssetStatusBlocked
+	}
+	m.save(ctx, asset)
+	return result, nil
+}
+
+// IsPresent reports whether the page has been downloaded by a previous crawl, that is destFile exists, or the page
+// is recorded as a blocked image or as a reference to an existing image(see base.ImageLinkModeReference) whose file
+// still exists. An existing destFile is recorded by Exists.
+func (m *Manifest) IsPresent(ctx context.Context, page int, picUrl, destFile string) bool {
+	if fileutil.IsExist(destFile) {
+		m.Exists(ctx, page, picUrl, destFile)
+		return true
+	}
+	asset, err := repository.ChapterAssetRepo.FindByChapterIdAndPage(ctx, m.chapter.Id, page)
+	if err != nil {
+		zap.L().Warn("failed to find chapter asset", zap.String("chapterId", m.chapter.Id.Hex()),
+			zap.Int("page", page), zap.Error(err))
+		return false
+	}
+	if asset == nil || asset.SourceUrl != picUrl {
+		return false
+	}
+	switch asset.Status {
+	case base.AssetStatusBlocked:
+		return true
+	case base.AssetStatusDownloaded:
+		return asset.LocalPath != "" && fileutil.IsExist(asset.LocalPath)
+	}
+	return false
+}
+
+// Exists records a page which has been downloaded before
+func (m *Manifest) Exists(ctx context.Context, page int, picUrl, destFile string) {
+	asset := m.newAsset(page, picUrl, destFile)
+	asset.Status = base.AssetStatusDownloaded
+	if info, err := os.Stat(destFile); err == nil {
+		asset.Size = info.Size()
+	}
+	m.save(ctx, asset)
+}
+
+// Fail records a page which fails to download, it could be downloaded again by RepairChapterAssets
+func (m *Manifest) Fail(ctx context.Context, page int, picUrl, destFile string, err error) {
+	asset := m.newAsset(page, picUrl, destFile)
+	asset.Status = base.AssetStatusFailed
+	asset.Error = err.Error()
+	m.save(ctx, asset)
+}
+
+func (m *Manifest) newAsset(page int, picUrl, destFile string) *entity.ChapterAsset {
+	return &entity.ChapterAsset{
+		ChapterId: m.chapter.Id,
+		NovelId:   m.chapter.NovelId,
+		SiteName:  m.siteName,
+		Page:      page,
+		SourceUrl: picUrl,
+		LocalPath: destFile,
+	}
+}
+
+// the manifest never breaks the download, errors are only logged
+func (m *Manifest) save(ctx context.Context, asset *entity.ChapterAsset) {
+	if err := repository.ChapterAssetRepo.Upsert(ctx, asset); err != nil {
+		zap.L().Warn("failed to save chapter asset", zap.String("chapterId", asset.ChapterId.Hex()),
+			zap.Int("page", asset.Page), zap.Error(err))
+	}
+}
+
+// RepairChapterAssets re-downloads the failed assets of a chapter and the downloaded ones whose local file is missing,
+// the other assets are left untouched
+func RepairChapterAssets(ctx context.Context, chapterId primitive.ObjectID) (*RepairResult, error) {
+	chapter, err := repository.ChapterRepo.FindById(ctx, chapterId)
+	if err != nil {
+		return nil, err
+	}
+	if chapter == nil {
+		return nil, base.ErrChapterNotFound
+	}
+
+	assets, err := repository.ChapterAssetRepo.FindByChapterId(ctx, chapterId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairResult{ChapterId: chapterId, Total: len(assets)}
+	for _, asset := range assets {
+		if !needRepair(asset) {
+			continue
+		}
+		//the chapter directory might be removed as well
+		if err = os.MkdirAll(filepath.Dir(asset.LocalPath), 0755); err != nil {
+			return nil, err
+		}
+		manifest := &Manifest{siteName: asset.SiteName, chapter: chapter}
+		if downloadResult, err := manifest.Download(ctx, asset.Page, asset.SourceUrl, asset.LocalPath); err != nil {
+			result.Failed++
+			metrics.MetricsFailedComicPicTaskGauge.Inc()
+			zap.L().Warn("failed to repair chapter asset", zap.String("chapterId", chapterId.Hex()),
+				zap.Int("page", asset.Page), zap.String("url", asset.SourceUrl), zap.Error(err))
+		} else {
+			result.Repaired++
+			if !downloadResult.Blocked {
+				metrics.MetricsComicPicDownloaded.Inc()
+			}
+		}
+	}
+	zap.L().Info("chapter assets repaired", zap.String("chapterId", chapterId.Hex()),
+		zap.Int("total", result.Total), zap.Int("repaired", result.Repaired), zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+func needRepair(asset *entity.ChapterAsset) bool {
+	switch asset.Status {
+	case base.AssetStatusFailed:
+		return asset.LocalPath != ""
+	case base.AssetStatusDownloaded:
+		return asset.LocalPath != "" && !fileutil.IsExist(asset.LocalPath)
+	}
+	return false
+}
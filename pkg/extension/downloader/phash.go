@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// DHash computes a 64 bits difference hash of an image: the image is shrunk to 9x8 grayscale pixels
+// and each bit records whether a pixel is brighter than its right neighbour.
+// Similar images(resized, re-encoded) share the same or a close hash.
+func DHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var pixels [dHashHeight][dHashWidth]float64
+	for y := 0; y < dHashHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dHashHeight
+		y1 := bounds.Min.Y + (y+1)*height/dHashHeight
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dHashWidth; x++ {
+			x0 := bounds.Min.X + x*width/dHashWidth
+			x1 := bounds.Min.X + (x+1)*width/dHashWidth
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			pixels[y][x] = averageGray(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if pixels[y][x] > pixels[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageGray returns the average luminance of the pixels in [x0,x1) x [y0,y1)
+func averageGray(img image.Image, x0, y0, x1, y1 int) float64 {
+	var sum float64
+	var count int
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// PerceptualHash decodes the image data and returns its difference hash in hex format,
+// unsupported formats(e.g. webp) return an error
+func PerceptualHash(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return FormatPHash(DHash(img)), nil
+}
+
+func FormatPHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func ParsePHash(hash string) (uint64, error) {
+	return strconv.ParseUint(hash, 16, 64)
+}
+
+// HammingDistance returns the number of different bits of two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
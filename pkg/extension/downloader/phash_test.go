@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// a horizontal gradient image
+func gradientImage(width, height int, reverse bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * 255 / width)
+			if reverse {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestDHashOfScaledImage(t *testing.T) {
+	small := DHash(gradientImage(90, 80, true))
+	large := DHash(gradientImage(900, 800, true))
+	if distance := HammingDistance(small, large); distance > defaultMaxPerceptualDistance {
+		t.Errorf("scaled images should be similar, distance=%v", distance)
+	}
+}
+
+func TestDHashOfDifferentImage(t *testing.T) {
+	hash := DHash(gradientImage(90, 80, true))
+	reversed := DHash(gradientImage(90, 80, false))
+	if distance := HammingDistance(hash, reversed); distance <= defaultMaxPerceptualDistance {
+		t.Errorf("different images should not be similar, distance=%v", distance)
+	}
+}
+
+func TestPHashFormat(t *testing.T) {
+	hash := DHash(gradientImage(90, 80, true))
+	parsed, err := ParsePHash(FormatPHash(hash))
+	if err != nil {
+		t.Error(err)
+	}
+	if parsed != hash {
+		t.Error("hash should be the same after formatting")
+	}
+}
+
+func TestBlockedHashes(t *testing.T) {
+	pHash := DHash(gradientImage(90, 80, true))
+	hashes, pHashes := parseBlockedHashes([]string{
+		"sha256:ABCDEF",
+		"123456",
+		"phash:" + FormatPHash(pHash),
+		"phash:invalid",
+	})
+	if len(hashes) != 2 || !hashes["abcdef"] || !hashes["123456"] {
+		t.Error("sha-256 hashes should be parsed")
+	}
+	if len(pHashes) != 1 {
+		t.Error("perceptual hashes should be parsed")
+	}
+
+	if !isBlocked(&Result{Hash: "abcdef"}, hashes, pHashes, 0) {
+		t.Error("image should be blocked by sha-256")
+	}
+
+	similar := FormatPHash(DHash(gradientImage(900, 800, true)))
+	if !isBlocked(&Result{Hash: "000000", PHash: similar}, hashes, pHashes, 0) {
+		t.Error("image should be blocked by perceptual hash")
+	}
+
+	different := FormatPHash(DHash(gradientImage(90, 80, false)))
+	if isBlocked(&Result{Hash: "000000", PHash: different}, hashes, pHashes, 0) {
+		t.Error("image shouldn't be blocked")
+	}
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ComicPage a downloaded picture of a comic chapter
+type ComicPage struct {
+	Chapter int //order of the chapter, starts from 1
+	Page    int
+	Path    string //local file of the picture
+}
+
+// WriteCbz writes the pictures into a CBZ archive in the given order, the entries are named by chapter
+// and page so that the readers sorting by name get the same order
+func WriteCbz(w io.Writer, pages []*ComicPage) error {
+	zw := zip.NewWriter(w)
+	for _, page := range pages {
+		ext := strings.ToLower(filepath.Ext(page.Path))
+		if ext == "" {
+			ext = ".jpg"
+		}
+		name := fmt.Sprintf("%04d/%04d%v", page.Chapter, page.Page, ext)
+		if err := copyFile(zw, name, page.Path); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// the pictures are compressed already, they are stored directly
+func copyFile(zw *zip.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
@@ -0,0 +1,272 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	MimeTypeEpub = "application/epub+zip"
+	MimeTypeCbz  = "application/vnd.comicbook+zip"
+)
+
+// Book the novel to be exported as an EPUB
+type Book struct {
+	Id          string //unique identifier of the book
+	Title       string
+	Author      string
+	Language    string //zh if it's empty
+	Description string
+	Modified    time.Time
+	Cover       []byte //jpeg cover picture, optional
+	Chapters    []*BookChapter
+}
+
+// BookChapter a chapter of the book, the content could be plain text or html
+type BookChapter struct {
+	Title   string
+	Content string
+}
+
+var (
+	lineBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+	tagRegex       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Paragraphs splits the content into paragraphs of plain text, the html tags are removed
+func Paragraphs(content string) []string {
+	content = lineBreakRegex.ReplaceAllString(content, "\n")
+	content = html.UnescapeString(tagRegex.ReplaceAllString(content, ""))
+
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// WriteEpub writes the book in EPUB 3 format, a ncx table of contents is included for the older readers
+func WriteEpub(w io.Writer, book *Book) error {
+	zw := zip.NewWriter(w)
+
+	//the mimetype must be the first entry and stored without compression
+	mimeWriter, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(mimeWriter, MimeTypeEpub); err != nil {
+		return err
+	}
+
+	data := newEpubData(book)
+	files := []struct {
+		name string
+		tpl  *template.Template
+	}{
+		{"META-INF/container.xml", containerTemplate},
+		{"OEBPS/content.opf", opfTemplate},
+		{"OEBPS/nav.xhtml", navTemplate},
+		{"OEBPS/toc.ncx", ncxTemplate},
+	}
+	for _, file := range files {
+		if err = writeTemplate(zw, file.name, file.tpl, data); err != nil {
+			return err
+		}
+	}
+	for _, chapter := range data.Chapters {
+		if err = writeTemplate(zw, "OEBPS/"+chapter.File, chapterTemplate, chapter); err != nil {
+			return err
+		}
+	}
+	if data.HasCover {
+		if err = writeFile(zw, "OEBPS/cover.jpg", bytes.NewReader(book.Cover)); err != nil {
+			return err
+		}
+		if err = writeTemplate(zw, "OEBPS/cover.xhtml", coverTemplate, data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type epubData struct {
+	Id          string
+	Title       string
+	Author      string
+	Language    string
+	Description string
+	Modified    string
+	HasCover    bool
+	Chapters    []*epubChapter
+}
+
+type epubChapter struct {
+	Id         string
+	File       string
+	Title      string
+	Order      int
+	Paragraphs []string
+	Language   string
+}
+
+func newEpubData(book *Book) *epubData {
+	data := &epubData{
+		Id:          book.Id,
+		Title:       book.Title,
+		Author:      book.Author,
+		Language:    book.Language,
+		Description: book.Description,
+		Modified:    book.Modified.UTC().Format(time.RFC3339),
+		HasCover:    len(book.Cover) > 0,
+	}
+	if data.Language == "" {
+		data.Language = "zh"
+	}
+	if book.Modified.IsZero() {
+		data.Modified = time.Now().UTC().Format(time.RFC3339)
+	}
+	for i, chapter := range book.Chapters {
+		data.Chapters = append(data.Chapters, &epubChapter{
+			Id:         fmt.Sprintf("chapter%04d", i+1),
+			File:       fmt.Sprintf("chapter%04d.xhtml", i+1),
+			Title:      chapter.Title,
+			Order:      i + 1,
+			Paragraphs: Paragraphs(chapter.Content),
+			Language:   data.Language,
+		})
+	}
+	return data
+}
+
+func writeTemplate(zw *zip.Writer, name string, tpl *template.Template, data any) error {
+	writer, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(writer, data)
+}
+
+func writeFile(zw *zip.Writer, name string, reader io.Reader) error {
+	writer, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// the values are escaped by the "x" function since text/template doesn't escape anything
+var templateFuncs = template.FuncMap{"x": html.EscapeString}
+
+var containerTemplate = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var opfTemplate = template.Must(template.New("opf").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{x .Id}}</dc:identifier>
+    <dc:title>{{x .Title}}</dc:title>
+    <dc:language>{{x .Language}}</dc:language>
+{{- if .Author}}
+    <dc:creator>{{x .Author}}</dc:creator>
+{{- end}}
+{{- if .Description}}
+    <dc:description>{{x .Description}}</dc:description>
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+{{- if .HasCover}}
+    <meta name="cover" content="cover-image"/>
+{{- end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+{{- if .HasCover}}
+    <item id="cover-image" href="cover.jpg" media-type="image/jpeg" properties="cover-image"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range .Chapters}}
+    <item id="{{.Id}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx">
+{{- if .HasCover}}
+    <itemref idref="cover"/>
+{{- end}}
+{{- range .Chapters}}
+    <itemref idref="{{.Id}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var navTemplate = template.Must(template.New("nav").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{x .Language}}">
+<head><title>{{x .Title}}</title></head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{x .Title}}</h1>
+    <ol>
+{{- range .Chapters}}
+      <li><a href="{{.File}}">{{x .Title}}</a></li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var ncxTemplate = template.Must(template.New("ncx").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{x .Id}}"/>
+  </head>
+  <docTitle><text>{{x .Title}}</text></docTitle>
+  <navMap>
+{{- range .Chapters}}
+    <navPoint id="nav-{{.Id}}" playOrder="{{.Order}}">
+      <navLabel><text>{{x .Title}}</text></navLabel>
+      <content src="{{.File}}"/>
+    </navPoint>
+{{- end}}
+  </navMap>
+</ncx>
+`))
+
+var chapterTemplate = template.Must(template.New("chapter").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{x .Language}}">
+<head><title>{{x .Title}}</title></head>
+<body>
+  <h2>{{x .Title}}</h2>
+{{- range .Paragraphs}}
+  <p>{{x .}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+var coverTemplate = template.Must(template.New("cover").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{x .Language}}">
+<head><title>{{x .Title}}</title></head>
+<body>
+  <img src="cover.jpg" alt="{{x .Title}}"/>
+</body>
+</html>
+`))
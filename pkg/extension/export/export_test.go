@@ -0,0 +1,102 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParagraphs(t *testing.T) {
+	paragraphs := Paragraphs("<p>第一段&amp;</p><p> </p>第二段<br/>第三段")
+	expected := []string{"第一段&", "第二段", "第三段"}
+	if strings.Join(paragraphs, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected paragraphs: %v", paragraphs)
+	}
+}
+
+func TestWriteEpub(t *testing.T) {
+	var buf bytes.Buffer
+	book := &Book{
+		Id:     "urn:crawlers:novel:1",
+		Title:  "斗破<苍穹>",
+		Author: "天蚕土豆",
+		Cover:  []byte{0xff, 0xd8},
+		Chapters: []*BookChapter{
+			{Title: "第一章", Content: "<p>a & b</p>"},
+			{Title: "第二章", Content: "line1\nline2"},
+		},
+	}
+	if err := WriteEpub(&buf, book); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := reader.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("mimetype should be the first entry without compression: %v", first.Name)
+	}
+
+	names := map[string]*zip.File{}
+	for _, file := range reader.File {
+		names[file.Name] = file
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml",
+		"OEBPS/toc.ncx", "OEBPS/chapter0001.xhtml", "OEBPS/chapter0002.xhtml", "OEBPS/cover.jpg"} {
+		file, ok := names[name]
+		if !ok {
+			t.Errorf("%v not found", name)
+			continue
+		}
+		if strings.HasSuffix(name, ".jpg") {
+			continue
+		}
+		//all the documents should be well-formed
+		rc, _ := file.Open()
+		decoder := xml.NewDecoder(rc)
+		for {
+			if _, err = decoder.Token(); err != nil {
+				break
+			}
+		}
+		if err != io.EOF {
+			t.Errorf("%v is not well-formed: %v", name, err)
+		}
+		rc.Close()
+	}
+}
+
+func TestWriteCbz(t *testing.T) {
+	dir := t.TempDir()
+	var pages []*ComicPage
+	for i, name := range []string{"1.jpg", "2.PNG"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, &ComicPage{Chapter: 1, Page: i, Path: path})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCbz(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.File) != 2 || reader.File[0].Name != "0001/0000.jpg" || reader.File[1].Name != "0001/0001.png" {
+		t.Errorf("unexpected entries: %v", reader.File)
+	}
+
+	if err = WriteCbz(&buf, []*ComicPage{{Chapter: 1, Path: filepath.Join(dir, "missing.jpg")}}); err == nil {
+		t.Error("an error is expected for a missing picture")
+	}
+}
@@ -0,0 +1,186 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crawlers/pkg/extension/proxy"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/jeven2016/mylibs/client"
+	"github.com/jeven2016/mylibs/utils"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotRecorded the response of the request isn't in the cache in the replay only mode
+var ErrNotRecorded = errors.New("response not recorded")
+
+// Transport caches the responses of the site on disk keyed by the method and the url, it's enabled by the
+// httpCache of the site settings. All the requests are served from the cache in the replay only mode.
+type Transport struct {
+	siteName string
+	next     http.RoundTripper
+}
+
+// NewTransport the next transport sends the requests not served from the cache, http.DefaultTransport is
+// used if it's nil
+func NewTransport(siteName string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{siteName: siteName, next: next}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the config isn't loaded in the tests of the crawlers
+	if service.ConfigService == nil || service.ConfigService.GetConfig() == nil {
+		return t.next.RoundTrip(req)
+	}
+	cacheSettings := service.ConfigService.GetConfig().HttpCache
+	var siteCache *entity.HttpCacheSettings
+	if settings := service.ConfigService.GetSiteConfig(t.siteName); settings != nil {
+		siteCache = settings.HttpCache
+	}
+	replayOnly := cacheSettings.IsReplayOnly()
+	if !replayOnly && !siteCache.IsEnabled() {
+		return t.next.RoundTrip(req)
+	}
+
+	file := cacheFile(cacheSettings.Dir(), t.siteName, req.Method, req.URL.String())
+	resp, err := readResponse(file, req, ttlOf(cacheSettings, siteCache), replayOnly)
+	if err != nil {
+		zap.L().Warn("failed to read the cached response", zap.String("file", file), zap.Error(err))
+	}
+	if resp != nil {
+		zap.L().Debug("response served from the cache", zap.String("url", req.URL.String()))
+		return resp, nil
+	}
+	if replayOnly {
+		return nil, fmt.Errorf("%w: %v %v", ErrNotRecorded, req.Method, req.URL)
+	}
+
+	if resp, err = t.next.RoundTrip(req); err != nil {
+		return nil, err
+	}
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	if err = writeResponse(file, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ttlOf the ttl of the site takes precedence, 0 means the responses never expire
+func ttlOf(cacheSettings *service.HttpCacheSettings, siteCache *entity.HttpCacheSettings) time.Duration {
+	if siteCache != nil && siteCache.TtlSeconds > 0 {
+		return time.Duration(siteCache.TtlSeconds) * time.Second
+	}
+	if cacheSettings != nil {
+		return time.Duration(cacheSettings.TtlSeconds) * time.Second
+	}
+	return 0
+}
+
+// cacheFile the files are spread into the sub directories by the first two characters of the key
+func cacheFile(dir, siteName, method, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(dir, siteName, key[:2], key)
+}
+
+// readResponse returns nil if the response isn't cached or it's expired, the expired ones are still used in
+// the replay only mode
+func readResponse(file string, req *http.Request, ttl time.Duration, replayOnly bool) (*http.Response, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	if !replayOnly && ttl > 0 && time.Since(info.ModTime()) > ttl {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+}
+
+// writeResponse records the response with the whole body, which is read again from the memory. It's written
+// into a temp file first in case the other goroutines read it partially, only the error reading the body
+// is returned since the response can't be used then.
+func writeResponse(file string, resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored := *resp
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		zap.L().Warn("failed to cache the response", zap.String("file", file), zap.Error(err))
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err == nil {
+		err = stored.Write(tmp)
+		_ = tmp.Close()
+		if err == nil {
+			err = os.Rename(tmp.Name(), file)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}
+	if err != nil {
+		zap.L().Warn("failed to cache the response", zap.String("file", file), zap.Error(err))
+	}
+	return nil
+}
+
+var restyClients sync.Map
+
+// GetRestyClient returns the resty client of the site for the domain of the url, it's configured like
+// client.GetRestyClient but the responses are cached and the requests are sent through the proxies of the site
+func GetRestyClient(siteName, url string) (*resty.Client, error) {
+	key := siteName + " " + utils.ParseBaseUri(url)
+	if c, ok := restyClients.Load(key); ok {
+		return c.(*resty.Client), nil
+	}
+	shared, err := client.GetRestyClient(url, true)
+	if err != nil {
+		return nil, err
+	}
+
+	c := resty.New().SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	transport, _ := c.GetClient().Transport.(*http.Transport)
+	c.SetTransport(NewTransport(siteName, proxy.NewTransport(siteName, transport)))
+	c.SetAllowGetMethodPayload(shared.AllowGetMethodPayload).
+		SetRetryCount(shared.RetryCount).
+		SetRetryWaitTime(shared.RetryWaitTime).
+		SetRetryMaxWaitTime(shared.RetryMaxWaitTime).
+		SetRetryAfter(shared.RetryAfter)
+	for _, condition := range shared.RetryConditions {
+		c.AddRetryCondition(condition)
+	}
+	actual, _ := restyClients.LoadOrStore(key, c)
+	return actual.(*resty.Client), nil
+}
@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"crawlers/pkg/service"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadConfig(t *testing.T, dir string, replayOnly bool) {
+	yamlConfig := fmt.Sprintf(`
+httpCache:
+  directory: %v
+  replayOnly: %v
+webSites:
+  - name: cached
+    httpCache:
+      enabled: true
+  - name: uncached
+`, dir, replayOnly)
+	service.ConfigService = service.NewConfigService()
+	if err := service.ConfigService.LoadInternalConfig(yamlConfig, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, siteName, url string) (string, error) {
+	resp, err := (&http.Client{Transport: NewTransport(siteName, nil)}).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), nil
+}
+
+func TestTransport(t *testing.T) {
+	defer func() {
+		service.ConfigService = nil
+	}()
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = fmt.Fprintf(w, "page %v", hits)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	loadConfig(t, dir, false)
+	for i := 0; i < 2; i++ {
+		if body, err := get(t, "cached", server.URL+"/a"); err != nil || body != "page 1" {
+			t.Errorf("the response should be cached, but it's %v, %v", body, err)
+		}
+	}
+	if body, _ := get(t, "uncached", server.URL+"/a"); body != "page 2" {
+		t.Errorf("the response of the site without cache should be fetched, but it's %v", body)
+	}
+
+	loadConfig(t, dir, true)
+	if body, err := get(t, "cached", server.URL+"/a"); err != nil || body != "page 1" {
+		t.Errorf("the recorded response should be replayed, but it's %v, %v", body, err)
+	}
+	if _, err := get(t, "cached", server.URL+"/b"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("the request not recorded should fail, but the error is %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("no request should be sent in the replay only mode, but %v are sent", hits)
+	}
+}
+
+func TestReadResponseExpired(t *testing.T) {
+	file := cacheFile(t.TempDir(), "cached", http.MethodGet, "https://example.com/a")
+	resp := &http.Response{StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{},
+		Body: io.NopCloser(strings.NewReader("page"))}
+	if err := writeResponse(file, resp); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(file, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	if cached, err := readResponse(file, req, time.Hour, false); err != nil || cached != nil {
+		t.Errorf("the expired response shouldn't be used, but it's %v, %v", cached, err)
+	}
+	if cached, err := readResponse(file, req, 0, false); err != nil || cached == nil {
+		t.Errorf("the response without ttl should be used, but it's %v, %v", cached, err)
+	}
+	if cached, err := readResponse(file, req, time.Hour, true); err != nil || cached == nil {
+		t.Errorf("the expired response should be replayed, but it's %v, %v", cached, err)
+	}
+}
@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/metrics"
+	"crawlers/pkg/service"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the timeout of a health check request
+const healthCheckTimeout = 15 * time.Second
+
+// Proxy a proxy of the pool
+type Proxy struct {
+	Url *url.URL
+	//the url with the password hidden, it's used in the logs and the metrics
+	label string
+
+	failures     int
+	ejectedUntil time.Time
+}
+
+func (p *Proxy) String() string {
+	return p.label
+}
+
+// Pool the proxies of a site or the global ones, the proxies failing repeatedly are ejected from rotation
+// for a while
+type Pool struct {
+	lock     sync.Mutex
+	key      string //the settings the pool is created with
+	strategy string
+	proxies  []*Proxy
+	next     int
+	sticky   map[string]*Proxy
+}
+
+func newPool(urls []string, strategy string) *Pool {
+	pool := &Pool{
+		key:      strategy + " " + strings.Join(urls, ","),
+		strategy: strategy,
+		sticky:   map[string]*Proxy{},
+	}
+	for _, rawUrl := range urls {
+		u, err := url.Parse(rawUrl)
+		if err != nil || u.Host == "" {
+			zap.L().Warn("invalid proxy ignored", zap.String("proxy", base.RedactUrl(rawUrl)))
+			continue
+		}
+		proxy := &Proxy{Url: u, label: base.RedactUrl(rawUrl)}
+		pool.proxies = append(pool.proxies, proxy)
+		metrics.MetricsProxyHealthy.WithLabelValues(proxy.label).Set(1)
+	}
+	return pool
+}
+
+// Pick returns a proxy for the request to the host, nil is returned if the pool is empty. The ejected
+// proxies are skipped, the one ejected the earliest is used if all of them are ejected.
+func (p *Pool) Pick(host string) *Proxy {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.proxies) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var available []*Proxy
+	for _, proxy := range p.proxies {
+		if !now.Before(proxy.ejectedUntil) {
+			available = append(available, proxy)
+		}
+	}
+	if len(available) == 0 {
+		available = append(available, p.proxies...)
+		sort.Slice(available, func(i, j int) bool {
+			return available[i].ejectedUntil.Before(available[j].ejectedUntil)
+		})
+		return available[0]
+	}
+
+	switch p.strategy {
+	case base.ProxyStrategyRandom:
+		return available[rand.Intn(len(available))]
+	case base.ProxyStrategySticky:
+		if proxy, ok := p.sticky[host]; ok && !now.Before(proxy.ejectedUntil) {
+			return proxy
+		}
+		proxy := p.roundRobin(available)
+		p.sticky[host] = proxy
+		return proxy
+	default:
+		return p.roundRobin(available)
+	}
+}
+
+func (p *Pool) roundRobin(available []*Proxy) *Proxy {
+	proxy := available[p.next%len(available)]
+	p.next++
+	return proxy
+}
+
+// Report records the result of a request sent through the proxy, the proxy is ejected after the consecutive
+// timeouts, network errors and the responses of 403, 407 and 429
+func (p *Pool) Report(proxy *Proxy, err error, statusCode int) {
+	if isFailure(err, statusCode) {
+		p.Fail(proxy, fmt.Errorf("status: %v, error: %v", statusCode, err))
+	} else {
+		p.Succeed(proxy)
+	}
+}
+
+// Succeed records a request succeeded through the proxy
+func (p *Pool) Succeed(proxy *Proxy) {
+	if p == nil || proxy == nil {
+		return
+	}
+	metrics.MetricsProxyRequests.WithLabelValues(proxy.label, "success").Inc()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	proxy.failures = 0
+}
+
+// Fail records a request failed due to the proxy, it's ejected after the consecutive failures
+func (p *Pool) Fail(proxy *Proxy, reason error) {
+	if p == nil || proxy == nil {
+		return
+	}
+	metrics.MetricsProxyRequests.WithLabelValues(proxy.label, "failure").Inc()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	proxy.failures++
+	settings := poolSettings()
+	if proxy.failures >= settings.FailureThreshold() && !time.Now().Before(proxy.ejectedUntil) {
+		p.eject(proxy, settings.EjectDuration(), reason)
+	}
+}
+
+func (p *Pool) eject(proxy *Proxy, duration time.Duration, reason error) {
+	proxy.ejectedUntil = time.Now().Add(duration)
+	metrics.MetricsProxyHealthy.WithLabelValues(proxy.label).Set(0)
+	metrics.MetricsProxyEjections.WithLabelValues(proxy.label).Inc()
+	zap.L().Warn("proxy ejected", zap.String("proxy", proxy.label), zap.Duration("duration", duration),
+		zap.Error(reason))
+}
+
+// setHealth applies the result of a health check, a healthy proxy is put back into rotation immediately
+func (p *Pool) setHealth(proxy *Proxy, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err != nil {
+		if !time.Now().Before(proxy.ejectedUntil) {
+			p.eject(proxy, poolSettings().EjectDuration(), err)
+		}
+		return
+	}
+	if !proxy.ejectedUntil.IsZero() {
+		zap.L().Info("proxy is back into rotation", zap.String("proxy", proxy.label))
+	}
+	proxy.failures = 0
+	proxy.ejectedUntil = time.Time{}
+	metrics.MetricsProxyHealthy.WithLabelValues(proxy.label).Set(1)
+}
+
+func isFailure(err error, statusCode int) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+	}
+	return statusCode == http.StatusForbidden || statusCode == http.StatusProxyAuthRequired ||
+		statusCode == http.StatusTooManyRequests
+}
+
+// the pools keyed by the site name, the global one is keyed by an empty name
+var pools = struct {
+	sync.Mutex
+	m map[string]*Pool
+}{m: map[string]*Pool{}}
+
+// PoolOf returns the pool of the site, the global one is returned if the site has no proxies. The pools are
+// created again once their settings are changed.
+func PoolOf(siteName string) *Pool {
+	if service.ConfigService == nil || service.ConfigService.GetConfig() == nil {
+		return nil
+	}
+	cfg := service.ConfigService.GetConfig()
+	var urls []string
+	var strategy string
+	if cfg.ProxyPool != nil {
+		urls, strategy = cfg.ProxyPool.Proxies, cfg.ProxyPool.Strategy
+	}
+	if len(urls) == 0 && cfg.Http != nil && cfg.Http.Proxy != "" {
+		urls = []string{cfg.Http.Proxy}
+	}
+	name := ""
+	if settings := service.ConfigService.GetSiteConfig(siteName); settings != nil && settings.ProxySettings != nil {
+		if len(settings.ProxySettings.Proxies) > 0 {
+			name, urls = siteName, settings.ProxySettings.Proxies
+		}
+		if settings.ProxySettings.Strategy != "" {
+			strategy = settings.ProxySettings.Strategy
+		}
+	}
+	if strategy == "" {
+		strategy = base.ProxyStrategyRoundRobin
+	}
+	if name == "" && strategy != base.ProxyStrategyRoundRobin {
+		// the site uses the global proxies with its own strategy
+		name = siteName
+	}
+
+	pools.Lock()
+	defer pools.Unlock()
+	pool := pools.m[name]
+	if key := strategy + " " + strings.Join(urls, ","); pool == nil || pool.key != key {
+		pool = newPool(urls, strategy)
+		pools.m[name] = pool
+	}
+	return pool
+}
+
+func poolSettings() *service.ProxyPoolSettings {
+	if service.ConfigService == nil || service.ConfigService.GetConfig() == nil {
+		return nil
+	}
+	return service.ConfigService.GetConfig().ProxyPool
+}
+
+// HealthCheck requests the health check url through the proxies of the pools periodically, the proxies
+// failing are ejected and the ejected ones passing are put back. It blocks until the context is done.
+func HealthCheck(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(poolSettings().HealthCheckInterval()):
+		}
+		settings := poolSettings()
+		if settings == nil || settings.HealthCheckUrl == "" {
+			continue
+		}
+
+		pools.Lock()
+		snapshot := make([]*Pool, 0, len(pools.m))
+		for _, pool := range pools.m {
+			snapshot = append(snapshot, pool)
+		}
+		pools.Unlock()
+
+		for _, pool := range snapshot {
+			pool.lock.Lock()
+			proxies := append([]*Proxy{}, pool.proxies...)
+			pool.lock.Unlock()
+			for _, proxy := range proxies {
+				pool.setHealth(proxy, check(ctx, settings.HealthCheckUrl, proxy))
+			}
+		}
+	}
+}
+
+func check(ctx context.Context, checkUrl string, proxy *Proxy) error {
+	client := &http.Client{
+		Timeout:   healthCheckTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxy.Url), DisableKeepAlives: true},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkUrl, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health check responds %v", resp.Status)
+	}
+	return nil
+}
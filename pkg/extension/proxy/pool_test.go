@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/sites/sitetest"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// loadConfig the pools created with the previous config are dropped
+func loadConfig(t *testing.T, yamlConfig string) {
+	sitetest.LoadConfig(t, yamlConfig)
+	pools.m = map[string]*Pool{}
+	t.Cleanup(func() {
+		pools.m = map[string]*Pool{}
+	})
+}
+
+func TestPick(t *testing.T) {
+	loadConfig(t, "proxyPool:\n  maxFailures: 2\n")
+	urls := []string{"http://a:1", "http://b:1", "http://c:1"}
+
+	pool := newPool(urls, base.ProxyStrategyRoundRobin)
+	for i := 0; i < 6; i++ {
+		if proxy := pool.Pick("x"); proxy.Url.String() != urls[i%3] {
+			t.Errorf("the proxies should be picked in turn, but %v is picked at %v", proxy, i)
+		}
+	}
+
+	pool = newPool(urls, base.ProxyStrategySticky)
+	first, second := pool.Pick("x"), pool.Pick("y")
+	if first == second || pool.Pick("x") != first || pool.Pick("y") != second {
+		t.Error("the same proxy should be picked for a host")
+	}
+
+	// the proxy failing twice is ejected and another one is stuck to the host
+	pool.Fail(first, errors.New("timeout"))
+	pool.Succeed(first)
+	pool.Fail(first, errors.New("timeout"))
+	if pool.Pick("x") != first {
+		t.Error("the proxy shouldn't be ejected since the failures aren't consecutive")
+	}
+	pool.Fail(first, errors.New("timeout"))
+	if picked := pool.Pick("x"); picked == first {
+		t.Error("the ejected proxy shouldn't be picked")
+	}
+
+	for _, proxy := range pool.proxies {
+		pool.Fail(proxy, errors.New("timeout"))
+		pool.Fail(proxy, errors.New("timeout"))
+	}
+	if picked := pool.Pick("z"); picked != first {
+		t.Errorf("the proxy ejected the earliest should be picked if all are ejected, but it's %v", picked)
+	}
+
+	pool.setHealth(first, nil)
+	if pool.Pick("x") != first || pool.Pick("y") != first {
+		t.Error("the proxy passing the health check should be put back")
+	}
+
+	if (*Pool)(nil).Pick("x") != nil || newPool(nil, base.ProxyStrategyRandom).Pick("x") != nil {
+		t.Error("no proxy should be picked from an empty pool")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	// the proxy servers respond the requests themselves, the first one is rate limited
+	var hits [2]int
+	var servers [2]*httptest.Server
+	for i := range servers {
+		i := i
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i]++
+			if i == 0 {
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+			_, _ = fmt.Fprintf(w, "%v %v", i, r.URL.Host)
+		}))
+		defer servers[i].Close()
+	}
+	loadConfig(t, fmt.Sprintf(`
+proxyPool:
+  proxies: [%v, %v]
+  maxFailures: 1
+webSites:
+  - name: direct
+    proxySettings:
+      proxies: [http://127.0.0.1:1]
+`, servers[0].URL, servers[1].URL))
+
+	client := &http.Client{Transport: NewTransport("site", nil)}
+	var bodies []string
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("http://site.example/page")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		bodies = append(bodies, string(body))
+	}
+	if hits[0] != 1 || hits[1] != 3 || bodies[0] != "0 site.example" || bodies[3] != "1 site.example" {
+		t.Errorf("the rate limited proxy should be ejected, hits: %v, bodies: %v", hits, bodies)
+	}
+
+	if PoolOf("direct") == PoolOf("site") || PoolOf("site") != PoolOf("other") {
+		t.Error("the site with its own proxies should have its own pool")
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type contextKey struct{}
+
+// Transport sends the requests of the site through the proxies of its pool
+type Transport struct {
+	siteName string
+	next     *http.Transport
+}
+
+// NewTransport the next transport is cloned with the proxy of each request taken from the request context,
+// the one like colly's default is used if it's nil. The requests are sent with the proxy of the next
+// transport if the pool is empty, e.g. the one of the environment variables.
+func NewTransport(siteName string, next *http.Transport) *Transport {
+	if next == nil {
+		next = &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: (&net.Dialer{
+				Timeout:   90 * time.Second,
+				KeepAlive: 90 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   90 * time.Second,
+			ExpectContinueTimeout: 90 * time.Second,
+			Proxy:                 http.ProxyFromEnvironment,
+		}
+	} else {
+		next = next.Clone()
+	}
+	fallback := next.Proxy
+	next.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(contextKey{}).(*Proxy); ok {
+			return proxy.Url, nil
+		}
+		if fallback != nil {
+			return fallback(req)
+		}
+		return nil, nil
+	}
+	return &Transport{siteName: siteName, next: next}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pool := PoolOf(t.siteName)
+	proxy := pool.Pick(req.URL.Hostname())
+	if proxy == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(context.WithValue(req.Context(), contextKey{}, proxy)))
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	pool.Report(proxy, err, statusCode)
+	return resp, err
+}
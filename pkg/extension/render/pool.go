@@ -0,0 +1,418 @@
+package render
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/httpcache"
+	"crawlers/pkg/extension/proxy"
+	"crawlers/pkg/metrics"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"errors"
+	"fmt"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"go.uber.org/zap"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the resource types can be blocked, see base.RenderResourceTypes
+var resourceTypes = []network.ResourceType{
+	network.ResourceTypeImage, network.ResourceTypeMedia, network.ResourceTypeFont, network.ResourceTypeStylesheet,
+	network.ResourceTypeScript, network.ResourceTypeXHR, network.ResourceTypeFetch, network.ResourceTypeWebSocket,
+	network.ResourceTypeOther,
+}
+
+// Options how a page is rendered
+type Options struct {
+	//the page is rendered once the element matched by it is visible
+	WaitSelector         string
+	Timeout              time.Duration
+	BlockedResourceTypes []network.ResourceType
+
+	proxies *proxy.Pool
+}
+
+// OptionsOf returns the options of the site, the global render settings are used for the ones not set
+func OptionsOf(settings *entity.SiteSettings) *Options {
+	opts := mergeOptions(service.ConfigService.GetConfig().Render, settings)
+	if settings != nil {
+		opts.proxies = proxy.PoolOf(settings.Name)
+	}
+	return opts
+}
+
+func mergeOptions(renderSettings *service.RenderSettings, settings *entity.SiteSettings) *Options {
+	opts := &Options{Timeout: renderSettings.PageTimeout()}
+	var blocked []string
+	if renderSettings != nil {
+		blocked = renderSettings.BlockedResourceTypes
+	}
+	if settings != nil && settings.BrowserSettings != nil {
+		browser := settings.BrowserSettings
+		opts.WaitSelector = browser.WaitSelector
+		if browser.TimeoutSeconds > 0 {
+			opts.Timeout = time.Duration(browser.TimeoutSeconds) * time.Second
+		}
+		if browser.BlockedResourceTypes != nil {
+			blocked = browser.BlockedResourceTypes
+		}
+	}
+	opts.BlockedResourceTypes = toResourceTypes(blocked)
+	return opts
+}
+
+func toResourceTypes(names []string) []network.ResourceType {
+	var types []network.ResourceType
+	for _, name := range names {
+		for _, resourceType := range resourceTypes {
+			if strings.EqualFold(name, string(resourceType)) {
+				types = append(types, resourceType)
+			}
+		}
+	}
+	return types
+}
+
+// Tab a tab of the browser borrowed from the pool, it's reused after being returned unless an error occurs.
+// The tab is opened in a browser context of its own if it's bound to a proxy.
+type Tab struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	browser context.Context //the browser the tab belongs to
+	caller  context.Context
+	proxy   *proxy.Proxy
+	//the resource types being blocked, they're read by the listener of the tab
+	blocked    atomic.Pointer[map[network.ResourceType]bool]
+	blockedKey string
+	configured bool
+	err        error
+}
+
+// Navigate loads the page and waits for the selector of the options if it's set
+func (t *Tab) Navigate(url string, opts *Options) error {
+	var actions []chromedp.Action
+	blockedKey := joinResourceTypes(opts.BlockedResourceTypes)
+	if !t.configured || blockedKey != t.blockedKey {
+		blocked := map[network.ResourceType]bool{}
+		for _, resourceType := range opts.BlockedResourceTypes {
+			blocked[resourceType] = true
+		}
+		t.blocked.Store(&blocked)
+		actions = append(actions, blockResources(opts.BlockedResourceTypes, t.hasCredentials()))
+	}
+	actions = append(actions, chromedp.Navigate(url))
+	if opts.WaitSelector != "" {
+		actions = append(actions, chromedp.WaitVisible(opts.WaitSelector, chromedp.ByQuery))
+	}
+	if err := t.Run(opts, actions...); err != nil {
+		metrics.MetricsBrowserRenderFailures.Inc()
+		return err
+	}
+	t.blockedKey, t.configured = blockedKey, true
+	metrics.MetricsBrowserPagesRendered.Inc()
+	return nil
+}
+
+// Run runs the actions in the tab, they're canceled once the page timeout is reached
+func (t *Tab) Run(opts *Options, actions ...chromedp.Action) error {
+	ctx, cancel := context.WithTimeout(t.ctx, opts.Timeout)
+	defer cancel()
+	stop := context.AfterFunc(t.caller, cancel)
+	defer stop()
+
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		t.err = err
+		return err
+	}
+	return nil
+}
+
+func joinResourceTypes(types []network.ResourceType) string {
+	names := make([]string, len(types))
+	for i, resourceType := range types {
+		names[i] = string(resourceType)
+	}
+	return strings.Join(names, ",")
+}
+
+// proxyKey the tabs bound to the same proxy are interchangeable, even if the pool of the proxy is recreated
+func proxyKey(p *proxy.Proxy) string {
+	if p == nil {
+		return ""
+	}
+	return p.Url.String()
+}
+
+func (t *Tab) hasCredentials() bool {
+	return t.proxy != nil && t.proxy.Url.User != nil
+}
+
+// blockResources intercepts the requests of the resource types, they're failed by the listener of the tab.
+// All the requests are intercepted if the proxy requires the credentials, which are provided by the listener.
+func blockResources(types []network.ResourceType, auth bool) chromedp.Action {
+	if auth {
+		return fetch.Enable().WithHandleAuthRequests(true).WithPatterns([]*fetch.RequestPattern{
+			{URLPattern: "*", RequestStage: fetch.RequestStageRequest},
+		})
+	}
+	if len(types) == 0 {
+		return fetch.Disable()
+	}
+	patterns := make([]*fetch.RequestPattern, len(types))
+	for i, resourceType := range types {
+		patterns[i] = &fetch.RequestPattern{URLPattern: "*", ResourceType: resourceType,
+			RequestStage: fetch.RequestStageRequest}
+	}
+	return fetch.Enable().WithPatterns(patterns)
+}
+
+// pool the tabs of a shared browser, the browser is started on demand and restarted once it exits
+type pool struct {
+	lock    sync.Mutex
+	browser context.Context
+	cancel  context.CancelFunc
+	idle    []*Tab
+	tokens  chan struct{}
+}
+
+var defaultPool = &pool{}
+
+// Do runs the function with a tab of the pool, it waits if all the tabs are in use. The tab goes through
+// the proxy picked for the host of the url, the proxy is reported as failed if the page can't be loaded
+// through it, and a socks5 proxy requiring the credentials is rejected since chromium can't authenticate
+// to it. The pages driven by a tab can't be replayed from the http cache, so httpcache.ErrNotRecorded is
+// returned in the replay only mode.
+func Do(ctx context.Context, pageUrl string, opts *Options, fn func(tab *Tab) error) error {
+	if cfg := service.ConfigService; cfg != nil && cfg.GetConfig() != nil && cfg.GetConfig().HttpCache.IsReplayOnly() {
+		return fmt.Errorf("%w: the page rendered by the browser %v", httpcache.ErrNotRecorded, pageUrl)
+	}
+	var host string
+	if u, err := url.Parse(pageUrl); err == nil {
+		host = u.Hostname()
+	}
+	picked := opts.proxies.Pick(host)
+	if picked != nil && base.IsSocks5WithCredentials(picked.Url) {
+		//the settings saved by the api may combine the browser with the proxies of the config
+		return fmt.Errorf("the browser can't authenticate to the socks5 proxy %v", picked)
+	}
+	tab, err := defaultPool.acquire(ctx, picked)
+	if err != nil {
+		return err
+	}
+	defer defaultPool.release(tab)
+	tab.caller = ctx
+
+	err = fn(tab)
+	if isProxyError(err) {
+		opts.proxies.Fail(picked, err)
+	} else if err == nil {
+		opts.proxies.Succeed(picked)
+	}
+	return err
+}
+
+// isProxyError whether the page fails to load due to the proxy, see the net errors of chromium
+func isProxyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	for _, code := range []string{"net::ERR_PROXY", "net::ERR_TUNNEL", "net::ERR_SOCKS", "net::ERR_TIMED_OUT",
+		"net::ERR_CONNECTION"} {
+		if strings.Contains(err.Error(), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the html of the page rendered by the browser
+func Render(ctx context.Context, url string, opts *Options) (html string, err error) {
+	err = Do(ctx, url, opts, func(tab *Tab) error {
+		if err := tab.Navigate(url, opts); err != nil {
+			return err
+		}
+		return tab.Run(opts, chromedp.OuterHTML("html", &html, chromedp.ByQuery))
+	})
+	return
+}
+
+// Close closes the browser, it's started again on demand
+func Close() {
+	defaultPool.close()
+}
+
+func (p *pool) acquire(ctx context.Context, picked *proxy.Proxy) (*Tab, error) {
+	p.lock.Lock()
+	if p.tokens == nil {
+		// the size takes effect after restarting
+		p.tokens = make(chan struct{}, service.ConfigService.GetConfig().Render.Size())
+	}
+	tokens := p.tokens
+	p.lock.Unlock()
+
+	metrics.MetricsBrowserWaitingRequests.Inc()
+	select {
+	case tokens <- struct{}{}:
+		metrics.MetricsBrowserWaitingRequests.Dec()
+	case <-ctx.Done():
+		metrics.MetricsBrowserWaitingRequests.Dec()
+		return nil, ctx.Err()
+	}
+
+	tab, err := p.takeTab(picked)
+	if err != nil {
+		<-tokens
+		return nil, err
+	}
+	metrics.MetricsBrowserTabsInUse.Inc()
+	return tab, nil
+}
+
+func (p *pool) takeTab(picked *proxy.Proxy) (*Tab, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.browser == nil || p.browser.Err() != nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		if tab := p.idle[i]; proxyKey(tab.proxy) == proxyKey(picked) {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			metrics.MetricsBrowserTabsIdle.Dec()
+			return tab, nil
+		}
+	}
+
+	var opts []chromedp.ContextOption
+	if picked != nil {
+		server := (&url.URL{Scheme: picked.Url.Scheme, Host: picked.Url.Host}).String()
+		opts = append(opts, chromedp.WithNewBrowserContext(
+			func(params *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+				return params.WithProxyServer(server)
+			}))
+	}
+	ctx, cancel := chromedp.NewContext(p.browser, opts...)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	tab := &Tab{ctx: ctx, cancel: cancel, browser: p.browser, proxy: picked}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go tab.handleRequest(e)
+		case *fetch.EventAuthRequired:
+			go tab.handleAuth(e)
+		}
+	})
+	return tab, nil
+}
+
+// handleRequest fails the intercepted requests of the blocked resource types and continues the others
+func (t *Tab) handleRequest(e *fetch.EventRequestPaused) {
+	executor := cdp.WithExecutor(t.ctx, chromedp.FromContext(t.ctx).Target)
+	var err error
+	if blocked := t.blocked.Load(); blocked != nil && (*blocked)[e.ResourceType] {
+		err = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+	} else {
+		err = fetch.ContinueRequest(e.RequestID).Do(executor)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		zap.L().Debug("failed to handle the intercepted request", zap.String("url", e.Request.URL), zap.Error(err))
+	}
+}
+
+// handleAuth provides the credentials of the proxy, the challenges of the sites are left to the browser
+func (t *Tab) handleAuth(e *fetch.EventAuthRequired) {
+	executor := cdp.WithExecutor(t.ctx, chromedp.FromContext(t.ctx).Target)
+	response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+	if e.AuthChallenge.Source == fetch.AuthChallengeSourceProxy && t.hasCredentials() {
+		password, _ := t.proxy.Url.User.Password()
+		response = &fetch.AuthChallengeResponse{
+			Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+			Username: t.proxy.Url.User.Username(),
+			Password: password,
+		}
+	}
+	if err := fetch.ContinueWithAuth(e.RequestID, response).Do(executor); err != nil &&
+		!errors.Is(err, context.Canceled) {
+		zap.L().Debug("failed to authenticate the proxy", zap.String("url", e.Request.URL), zap.Error(err))
+	}
+}
+
+// start starts the browser, the tabs of the previous one are dropped
+func (p *pool) start() error {
+	p.closeBrowser()
+	// the proxies are set per tab, see takeTab
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.Flag("headless", true))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	browser, cancelBrowser := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browser); err != nil {
+		cancelBrowser()
+		cancelAlloc()
+		return err
+	}
+	p.browser = browser
+	p.cancel = func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+	metrics.MetricsBrowserStarts.Inc()
+	zap.L().Info("browser started for rendering pages")
+	return nil
+}
+
+func (p *pool) release(tab *Tab) {
+	p.lock.Lock()
+	if tab.err != nil || tab.ctx.Err() != nil || tab.browser != p.browser {
+		// the tab might be stuck in loading
+		tab.cancel()
+	} else {
+		if len(p.idle) >= service.ConfigService.GetConfig().Render.Size() {
+			// the oldest one is dropped, it might be bound to a proxy not used anymore
+			p.idle[0].cancel()
+			p.idle = p.idle[1:]
+			metrics.MetricsBrowserTabsIdle.Dec()
+		}
+		tab.caller = nil
+		p.idle = append(p.idle, tab)
+		metrics.MetricsBrowserTabsIdle.Inc()
+	}
+	tokens := p.tokens
+	p.lock.Unlock()
+
+	metrics.MetricsBrowserTabsInUse.Dec()
+	<-tokens
+}
+
+func (p *pool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closeBrowser()
+}
+
+func (p *pool) closeBrowser() {
+	for _, tab := range p.idle {
+		tab.cancel()
+	}
+	metrics.MetricsBrowserTabsIdle.Sub(float64(len(p.idle)))
+	p.idle = nil
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+		p.browser = nil
+	}
+}
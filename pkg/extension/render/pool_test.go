@@ -0,0 +1,40 @@
+package render
+
+import (
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/service"
+	"github.com/chromedp/cdproto/network"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeOptions(t *testing.T) {
+	renderSettings := &service.RenderSettings{
+		PageTimeoutSeconds:   20,
+		BlockedResourceTypes: []string{"image", "Font"},
+	}
+
+	opts := mergeOptions(renderSettings, &entity.SiteSettings{})
+	if opts.Timeout != 20*time.Second || opts.WaitSelector != "" {
+		t.Errorf("the global settings should be used, but they're %+v", opts)
+	}
+	expected := []network.ResourceType{network.ResourceTypeImage, network.ResourceTypeFont}
+	if !reflect.DeepEqual(opts.BlockedResourceTypes, expected) {
+		t.Errorf("the resource types should be %v, but they're %v", expected, opts.BlockedResourceTypes)
+	}
+
+	opts = mergeOptions(renderSettings, &entity.SiteSettings{BrowserSettings: &entity.BrowserSettings{
+		WaitSelector:         "#content",
+		TimeoutSeconds:       5,
+		BlockedResourceTypes: []string{},
+	}})
+	if opts.Timeout != 5*time.Second || opts.WaitSelector != "#content" || len(opts.BlockedResourceTypes) != 0 {
+		t.Errorf("the site settings should take precedence, but they're %+v", opts)
+	}
+
+	opts = mergeOptions(nil, nil)
+	if opts.Timeout != 60*time.Second || len(opts.BlockedResourceTypes) != 0 {
+		t.Errorf("the defaults should be used, but they're %+v", opts)
+	}
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/httpcache"
+	"crawlers/pkg/extension/proxy"
+	"crawlers/pkg/service"
+	"github.com/gocolly/colly/v2"
+	"github.com/jeven2016/mylibs/client"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport renders the pages with the browser pool if the site is set with "render: browser", or else
+// the requests are sent by the next transport
+type Transport struct {
+	siteName string
+	next     http.RoundTripper
+}
+
+// NewTransport the transport of the site, the one sending the requests through the proxies of the site is
+// used if next is nil
+func NewTransport(siteName string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = proxy.NewTransport(siteName, nil)
+	}
+	return &Transport{siteName: siteName, next: next}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the config isn't loaded in the tests of the crawlers
+	if req.Method != http.MethodGet || service.ConfigService == nil || service.ConfigService.GetConfig() == nil {
+		return t.next.RoundTrip(req)
+	}
+	settings := service.ConfigService.GetSiteConfig(t.siteName)
+	if settings == nil || settings.Render != base.RenderBrowser {
+		return t.next.RoundTrip(req)
+	}
+
+	html, err := Render(req.Context(), req.URL.String(), OptionsOf(settings))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(html)),
+		ContentLength: int64(len(html)),
+		Request:       req,
+	}, nil
+}
+
+// NewCollector creates the collector of the site, whose pages are rendered by the browser if it's required,
+// the rendered pages are cached as well if the http cache is enabled
+func NewCollector(siteName string, maxRetries int) (*colly.Collector, error) {
+	c, err := client.NewCollector("", maxRetries)
+	if err != nil {
+		return c, err
+	}
+	c.WithTransport(httpcache.NewTransport(siteName, NewTransport(siteName, nil)))
+	return c, nil
+}
+
+// HasElements visits the url with a clone of the collector and returns whether any element matches the selector.
+// The url isn't recorded as visited, so the page can still be crawled after it's probed.
+func HasElements(c *colly.Collector, url, selector string) (bool, error) {
+	cly := c.Clone()
+	cly.AllowURLRevisit = true
+	found := false
+	cly.OnHTML(selector, func(*colly.HTMLElement) {
+		found = true
+	})
+	if err := cly.Visit(url); err != nil {
+		return false, err
+	}
+	return found, nil
+}
@@ -3,6 +3,8 @@ package aipic
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/downloader"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/metrics"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
@@ -10,7 +12,6 @@ import (
 	"errors"
 	"github.com/go-creed/sat"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"os"
@@ -19,13 +20,16 @@ import (
 	"time"
 )
 
+// the subjects of the posts on a catalog page
+const novelSelector = ".t_subject a"
+
 type Aipic struct {
 	colly       *colly.Collector
 	zhConvertor sat.Dicter
 }
 
 func NewCartoonCrawler() *Aipic {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.Aipic, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -45,7 +49,7 @@ func (c Aipic) CrawlCatalogPage(ctx context.Context, catalogPageTask *entity.Cat
 	zap.L().Info("Got CatalogPageTask message", zap.String("url", catalogPageTask.Url))
 	var novelTasks []entity.NovelTask
 	cly := c.colly.Clone()
-	cly.OnHTML(".t_subject a", func(element *colly.HTMLElement) {
+	cly.OnHTML(novelSelector, func(element *colly.HTMLElement) {
 		href := "https://www.cool18.com/bbs7/" + element.Attr("href")
 		novelTasks = append(novelTasks, entity.NovelTask{
 			Url:      href,
@@ -61,6 +65,11 @@ func (c Aipic) CrawlCatalogPage(ctx context.Context, catalogPageTask *entity.Cat
 	return novelTasks, nil
 }
 
+// HasItems returns true if the catalog page has any post
+func (c Aipic) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(c.colly, url, novelSelector)
+}
+
 func (c Aipic) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("Got novel message", zap.String("url", novelTask.Url))
 
@@ -71,7 +80,8 @@ func (c Aipic) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask,
 
 	var imageUrls []string
 	var createdTime = time.Now()
-	var novel = entity.Novel{Attributes: make(map[string]interface{}), CreatedTime: &createdTime}
+	var novel = entity.Novel{CatalogId: novelTask.CatalogId, Attributes: make(map[string]interface{}),
+		CreatedTime: &createdTime}
 	cly := c.colly.Clone()
 
 	//获取名称
@@ -101,19 +111,14 @@ func (c Aipic) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask,
 	}
 
 	for _, url := range imageUrls {
-		restyClient, err := client.GetRestyClient(url, true)
-		if err != nil {
-			return nil, err
-		}
-
 		index := strings.LastIndex(url, "/") + 1
 		filename := url[index:]
 
 		destFile := filepath.Join(dir, filename)
-		if _, err = restyClient.R().SetOutput(destFile).Get(url); err != nil {
+		if result, err := downloader.DownloadImage(ctx, base.Aipic, url, destFile); err != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
 			zap.L().Error("failed to download picture", zap.String("url", url), zap.Error(err))
-		} else {
+		} else if !result.Blocked {
 			metrics.MetricsComicPicDownloaded.Inc()
 			zap.L().Info("picture downloaded", zap.String("url", url), zap.String("localFile", destFile))
 		}
@@ -3,17 +3,16 @@ package cartoon18
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/downloader"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/metrics"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
 	"crawlers/pkg/service"
 	"errors"
 	"fmt"
-	"github.com/duke-git/lancet/v2/fileutil"
 	"github.com/go-creed/sat"
-	"github.com/go-resty/resty/v2"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
@@ -23,13 +22,16 @@ import (
 	"time"
 )
 
+// the links to the comics on a catalog page
+const novelSelector = ".card .lines.lines-2 a.visited"
+
 type CartoonCrawler struct {
 	colly       *colly.Collector
 	zhConvertor sat.Dicter
 }
 
 func NewCartoonCrawler() *CartoonCrawler {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.Cartoon18, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -49,7 +51,7 @@ func (c CartoonCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *e
 	zap.L().Info("Got CatalogPageTask message", zap.String("url", catalogPageTask.Url))
 	var novelTasks []entity.NovelTask
 	cly := c.colly.Clone()
-	cly.OnHTML(".card .lines.lines-2 a.visited", func(element *colly.HTMLElement) {
+	cly.OnHTML(novelSelector, func(element *colly.HTMLElement) {
 		href := element.Attr("href")
 		novelUrl := utils.BuildUrl(catalogPageTask.Url, href)
 		novelTasks = append(novelTasks, entity.NovelTask{
@@ -65,6 +67,11 @@ func (c CartoonCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *e
 	return novelTasks, nil
 }
 
+// HasItems returns true if any comic is listed on the catalog page
+func (c CartoonCrawler) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(c.colly, url, novelSelector)
+}
+
 func (c CartoonCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("Got novel message", zap.String("url", novelTask.Url))
 
@@ -74,7 +81,8 @@ func (c CartoonCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.No
 	}
 
 	var createdTime = time.Now()
-	var novel = entity.Novel{Attributes: make(map[string]interface{}), CreatedTime: &createdTime}
+	var novel = entity.Novel{CatalogId: novelTask.CatalogId, Attributes: make(map[string]interface{}),
+		CreatedTime: &createdTime}
 	var chpTasks []entity.ChapterTask
 	cly := c.colly.Clone()
 	//获取名称
@@ -160,7 +168,6 @@ func (c CartoonCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.No
 
 func (c CartoonCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.ChapterTask, skipSaveIfPresent bool) error {
 	var err error
-	var restyClient *resty.Client
 	var novel *entity.Novel
 
 	siteCfg := service.ConfigService.GetSiteConfig(base.Cartoon18)
@@ -188,42 +195,48 @@ func (c CartoonCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entit
 		return fmt.Errorf("no chapter directory specified %v", siteCfg.Attributes["directory"])
 	}
 
+	//记录每一页图片的下载结果
+	manifest, err := downloader.NewManifest(ctx, base.Cartoon18, chapterTask)
+	if err != nil {
+		return err
+	}
+
 	var i = 1
 	cly.OnHTML(".cartoon-image", func(img *colly.HTMLElement) {
-		if err != nil {
-			metrics.MetricsFailedComicPicTaskGauge.Inc()
-			return
-		}
-
-		if i%100 == 0 {
-			time.Sleep(4 * time.Second)
-		}
-
 		picUrl := img.Attr("data-src")
-		restyClient, err = client.GetRestyClient(picUrl, true)
-		if err != nil {
-			return
-		}
 
 		var fileFormat = ".webp"
 		if !strings.Contains(picUrl, ".webp") {
 			fileFormat = ".jpg"
 		}
 
-		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", i)+fileFormat)
+		page := i
+		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", page)+fileFormat)
 		i++
 
-		if fileutil.IsExist(destFile) {
+		//the rest pages are recorded as failed for repairing later
+		if err != nil {
+			metrics.MetricsFailedComicPicTaskGauge.Inc()
+			manifest.Fail(ctx, page, picUrl, destFile, err)
+			return
+		}
+
+		if page%100 == 0 {
+			time.Sleep(4 * time.Second)
+		}
+
+		if manifest.IsPresent(ctx, page, picUrl, destFile) {
 			metrics.MetricsComicPicDownloaded.Inc()
-			zap.L().Info("pic skipped since it exists in directory", zap.String("destFile", destFile))
+			zap.L().Info("pic skipped since it has been downloaded", zap.String("destFile", destFile))
 			return
 		}
 
-		if _, err = restyClient.R().SetOutput(destFile).Get(picUrl); err != nil {
+		var result *downloader.Result
+		if result, err = manifest.Download(ctx, page, picUrl, destFile); err != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
 			zap.L().Error("failed to download picture", zap.String("url", picUrl), zap.Error(err))
 			return
-		} else {
+		} else if !result.Blocked {
 			metrics.MetricsComicPicDownloaded.Inc()
 			zap.L().Info("picture downloaded", zap.String("url", picUrl), zap.String("localFile", destFile))
 		}
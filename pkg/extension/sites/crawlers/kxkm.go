@@ -3,6 +3,8 @@ package crawlers
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/downloader"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/metrics"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
@@ -11,9 +13,7 @@ import (
 	"fmt"
 	"github.com/duke-git/lancet/v2/fileutil"
 	"github.com/go-creed/sat"
-	"github.com/go-resty/resty/v2"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
@@ -23,13 +23,16 @@ import (
 	"time"
 )
 
+// the novels listed on a catalog page
+const kxkmNovelSelector = ".product__item__text > h6 > a"
+
 type kxkmCrawler struct {
 	colly       *colly.Collector
 	zhConvertor sat.Dicter
 }
 
 func NewKxkmCrawler() *kxkmCrawler {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.Kxkm, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -49,7 +52,7 @@ func (c kxkmCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *enti
 	zap.L().Info("[kxkm] Got CatalogPageTask message", zap.String("url", catalogPageTask.Url))
 	var novelTasks []entity.NovelTask
 	cly := c.colly.Clone()
-	cly.OnHTML(".product__item__text > h6 > a", func(element *colly.HTMLElement) {
+	cly.OnHTML(kxkmNovelSelector, func(element *colly.HTMLElement) {
 		href := element.Attr("href")
 		novelUrl := utils.BuildUrl(catalogPageTask.Url, href)
 		novelTasks = append(novelTasks, entity.NovelTask{
@@ -65,10 +68,16 @@ func (c kxkmCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *enti
 	return novelTasks, nil
 }
 
+// HasItems probes whether the catalog page lists any novel
+func (c kxkmCrawler) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(c.colly, url, kxkmNovelSelector)
+}
+
 func (c kxkmCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("[kxkm] Got novel message", zap.String("url", novelTask.Url))
 	var createdTime = time.Now()
-	var novel = entity.Novel{Attributes: make(map[string]interface{}), CreatedTime: &createdTime}
+	var novel = entity.Novel{CatalogId: novelTask.CatalogId, Attributes: make(map[string]interface{}),
+		CreatedTime: &createdTime}
 	var chpTasks []entity.ChapterTask
 	var novelFolder string
 
@@ -150,11 +159,7 @@ func (c kxkmCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.Novel
 		if err == nil && coverImageUrl != "" {
 			destFile := filepath.Join(novelFolder, "cover.jpg")
 			if exist := fileutil.IsExist(destFile); !exist {
-				client, err := client.GetRestyClient(novelTask.Url, true)
-				if err != nil {
-					return chpTasks, err
-				}
-				if _, err = client.R().SetOutput(destFile).Get(coverImageUrl); err != nil {
+				if _, err = downloader.DownloadImage(ctx, base.Kxkm, coverImageUrl, destFile); err != nil {
 					metrics.MetricsFailedComicPicTaskGauge.Inc()
 					zap.L().Error("[kxkm] failed to download cover picture", zap.String("url", coverImageUrl), zap.Error(err))
 					return chpTasks, err
@@ -171,7 +176,6 @@ func (c kxkmCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.Novel
 
 func (c kxkmCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.ChapterTask, skipSaveIfPresent bool) error {
 	var err error
-	var restyClient *resty.Client
 	var novel *entity.Novel
 
 	siteCfg := service.ConfigService.GetSiteConfig(base.Kxkm)
@@ -199,42 +203,55 @@ func (c kxkmCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.C
 		return fmt.Errorf("no chapter directory specified %v", siteCfg.Attributes["directory"])
 	}
 
-	var fileFormat string
+	//记录每一页图片的下载结果
+	manifest, err := downloader.NewManifest(ctx, base.Kxkm, chapterTask)
+	if err != nil {
+		return err
+	}
+
 	var i = 0
 	cly.OnHTML(".blog__details__content>img", func(img *colly.HTMLElement) {
 		i++
-		if err != nil {
+		picUrl := img.Attr("src")
+
+		fileFormat, extErr := utils.GetFileExtFromUrl(picUrl)
+		if extErr != nil || fileFormat == "" {
+			//the failed page is still recorded with a path so that it can be repaired
+			fileFormat = ".jpg"
+		}
+		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", i)+fileFormat)
+		if extErr != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
+			manifest.Fail(ctx, i, picUrl, destFile, extErr)
+			if err == nil {
+				err = extErr
+			}
 			return
 		}
 
-		if i%100 == 0 {
-			time.Sleep(4 * time.Second)
-		}
-
-		picUrl := img.Attr("src")
-		restyClient, err = client.GetRestyClient(picUrl, true)
+		//the rest pages are recorded as failed for repairing later
 		if err != nil {
+			metrics.MetricsFailedComicPicTaskGauge.Inc()
+			manifest.Fail(ctx, i, picUrl, destFile, err)
 			return
 		}
 
-		fileFormat, err = utils.GetFileExtFromUrl(picUrl)
-		if err != nil {
-			return
+		if i%100 == 0 {
+			time.Sleep(4 * time.Second)
 		}
-		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", i)+fileFormat)
 
-		if fileutil.IsExist(destFile) {
+		if manifest.IsPresent(ctx, i, picUrl, destFile) {
 			metrics.MetricsComicPicDownloaded.Inc()
-			zap.L().Info("[kxkm] pic skipped since it exists in directory", zap.String("destFile", destFile))
+			zap.L().Info("[kxkm] pic skipped since it has been downloaded", zap.String("destFile", destFile))
 			return
 		}
 
-		if _, err = restyClient.R().SetOutput(destFile).Get(picUrl); err != nil {
+		var result *downloader.Result
+		if result, err = manifest.Download(ctx, i, picUrl, destFile); err != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
 			zap.L().Error("[kxkm] failed to download picture", zap.String("url", picUrl), zap.Error(err))
 			return
-		} else {
+		} else if !result.Blocked {
 			metrics.MetricsComicPicDownloaded.Inc()
 			zap.L().Info("[kxkm] picture downloaded", zap.String("url", picUrl), zap.String("localFile", destFile))
 		}
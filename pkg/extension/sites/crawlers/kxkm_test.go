@@ -0,0 +1,37 @@
+package crawlers
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/sites/sitetest"
+	"crawlers/pkg/model/entity"
+	"testing"
+)
+
+func TestKxkmCrawler(t *testing.T) {
+	h := sitetest.New(t, base.Kxkm, "")
+	crawler := NewKxkmCrawler()
+	ctx := context.Background()
+
+	novelTasks, err := crawler.CrawlCatalogPage(ctx, &entity.CatalogPageTask{SiteName: base.Kxkm,
+		Url: h.Url("/kxkm/catalog.html")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AssertGolden("kxkm_novel_tasks", novelTasks)
+
+	chapterTasks, err := crawler.CrawlNovelPage(ctx, &novelTasks[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AssertGolden("kxkm_chapter_tasks", chapterTasks)
+	if len(h.Repos.Novels) != 1 || h.Repos.Novels[0].Name != "恋爱漫画" || !h.Repos.Novels[0].HasChapters {
+		t.Errorf("the novel should be saved, but they're %+v", h.Repos.Novels)
+	}
+
+	if err = crawler.CrawlChapterPage(ctx, &chapterTasks[0], false); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertGolden("kxkm_files", h.Files())
+	h.AssertGolden("kxkm_assets", h.Repos.Assets)
+}
@@ -3,6 +3,8 @@ package crawlers
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/downloader"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/metrics"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
@@ -11,9 +13,7 @@ import (
 	"fmt"
 	"github.com/duke-git/lancet/v2/fileutil"
 	"github.com/go-creed/sat"
-	"github.com/go-resty/resty/v2"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
@@ -23,6 +23,9 @@ import (
 	"time"
 )
 
+// the covers linking to the comics of a catalog page
+const wucomicNovelSelector = ".cartoon-cover"
+
 type wucomicCrawler struct {
 	//redis       *cache.Redis
 	//mongoClient *db.Mongo
@@ -33,7 +36,7 @@ type wucomicCrawler struct {
 }
 
 func NewWucomicCrawler() *wucomicCrawler {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.Wucomic, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -53,7 +56,7 @@ func (c wucomicCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *e
 	zap.L().Info("[wucomic] Got CatalogPageTask message", zap.String("url", catalogPageTask.Url))
 	var novelTasks []entity.NovelTask
 	cly := c.colly.Clone()
-	cly.OnHTML(".cartoon-cover", func(element *colly.HTMLElement) {
+	cly.OnHTML(wucomicNovelSelector, func(element *colly.HTMLElement) {
 		href := element.Attr("href")
 		novelUrl := utils.BuildUrl(catalogPageTask.Url, href)
 		novelTasks = append(novelTasks, entity.NovelTask{
@@ -69,10 +72,16 @@ func (c wucomicCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *e
 	return novelTasks, nil
 }
 
+// HasItems returns true if the catalog page has any comic
+func (c wucomicCrawler) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(c.colly, url, wucomicNovelSelector)
+}
+
 func (c wucomicCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("[wucomic] Got novel message", zap.String("url", novelTask.Url))
 	var createdTime = time.Now()
-	var novel = entity.Novel{Attributes: make(map[string]interface{}), CreatedTime: &createdTime}
+	var novel = entity.Novel{CatalogId: novelTask.CatalogId, Attributes: make(map[string]interface{}),
+		CreatedTime: &createdTime}
 	var chpTasks []entity.ChapterTask
 
 	siteCfg := service.ConfigService.GetSiteConfig(base.Wucomic)
@@ -151,7 +160,6 @@ func (c wucomicCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.No
 
 func (c wucomicCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.ChapterTask, skipSaveIfPresent bool) error {
 	var err error
-	var restyClient *resty.Client
 	var novel *entity.Novel
 
 	siteCfg := service.ConfigService.GetSiteConfig(base.Wucomic)
@@ -179,38 +187,44 @@ func (c wucomicCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entit
 		return fmt.Errorf("no chapter directory specified %v", siteCfg.Attributes["directory"])
 	}
 
+	//记录每一页图片的下载结果
+	manifest, err := downloader.NewManifest(ctx, base.Wucomic, chapterTask)
+	if err != nil {
+		return err
+	}
+
 	var i = 1
 	cly.OnHTML(".cropped", func(img *colly.HTMLElement) {
+		picUrl := img.Attr("src")
+
+		var fileFormat = ".jpg"
+		page := i
+		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", page)+fileFormat)
+		i++
+
+		//the rest pages are recorded as failed for repairing later
 		if err != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
+			manifest.Fail(ctx, page, picUrl, destFile, err)
 			return
 		}
 
-		if i%100 == 0 {
+		if page%100 == 0 {
 			time.Sleep(4 * time.Second)
 		}
 
-		picUrl := img.Attr("src")
-		restyClient, err = client.GetRestyClient(picUrl, true)
-		if err != nil {
-			return
-		}
-
-		var fileFormat = ".jpg"
-		destFile := filepath.Join(chapterDir, fmt.Sprintf("%04d", i)+fileFormat)
-		i++
-
-		if fileutil.IsExist(destFile) {
+		if manifest.IsPresent(ctx, page, picUrl, destFile) {
 			metrics.MetricsComicPicDownloaded.Inc()
-			zap.L().Info("[wucomic] pic skipped since it exists in directory", zap.String("destFile", destFile))
+			zap.L().Info("[wucomic] pic skipped since it has been downloaded", zap.String("destFile", destFile))
 			return
 		}
 
-		if _, err = restyClient.R().SetOutput(destFile).Get(picUrl); err != nil {
+		var result *downloader.Result
+		if result, err = manifest.Download(ctx, page, picUrl, destFile); err != nil {
 			metrics.MetricsFailedComicPicTaskGauge.Inc()
 			zap.L().Error("[wucomic] failed to download picture", zap.String("url", picUrl), zap.Error(err))
 			return
-		} else {
+		} else if !result.Blocked {
 			metrics.MetricsComicPicDownloaded.Inc()
 			zap.L().Info("[wucomic] picture downloaded", zap.String("url", picUrl), zap.String("localFile", destFile))
 		}
@@ -1,75 +1,77 @@
 package crawlers
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"github.com/chromedp/chromedp"
-	"github.com/jeven2016/mylibs/client"
-	"image/jpeg"
-	"os"
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/sites/sitetest"
+	"crawlers/pkg/model/entity"
 	"testing"
 )
 
-func TestDownloadPic(t *testing.T) {
-	url := "https://www.wuwucomic.xyz/chapter/22906"
+func TestWucomicCrawler(t *testing.T) {
+	h := sitetest.New(t, base.Wucomic, "    imageSettings:\n      deduplicate: true\n")
+	crawler := NewWucomicCrawler()
+	ctx := context.Background()
 
-	chromeCtx, cleanFunc := client.OpenChrome(context.Background())
-	defer cleanFunc()
-
-	var data string
-	err := chromedp.Run(chromeCtx,
-		chromedp.Navigate(url),
-		chromedp.ScrollIntoView(`body`, chromedp.ByQuery),
-		//chromedp.WaitNotPresent("//p[contains(text(),'内容未加载完成')]", chromedp.BySearch),
-		chromedp.Evaluate(`
-				$('#canvas_689512')[0].toDataURL("image/jpeg")
-			`,
-			&data,
-		),
-	)
-
-	// 滚动到页面的最底部
-	err = chromedp.Run(chromeCtx, chromedp.ScrollIntoView(`body`, chromedp.ByQuery))
+	novelTasks, err := crawler.CrawlCatalogPage(ctx, &entity.CatalogPageTask{SiteName: base.Wucomic,
+		Url: h.Url("/wucomic/catalog.html")})
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
+	h.AssertGolden("wucomic_novel_tasks", novelTasks)
 
-	err = chromedp.Run(chromeCtx,
-		chromedp.Navigate(url),
-		chromedp.Evaluate(`
-				$('#canvas_689512')[0].toDataURL("image/jpeg")
-			`,
-			&data,
-		),
-	)
+	chapterTasks, err := crawler.CrawlNovelPage(ctx, &novelTasks[0], false)
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
+	h.AssertGolden("wucomic_chapter_tasks", chapterTasks)
 
-	// 解码 base64 字符串
-	byteData, err := base64.StdEncoding.DecodeString(data[:22])
-	if err != nil {
-		panic(err)
+	// the duplicated page is linked to the first one
+	if err = crawler.CrawlChapterPage(ctx, &chapterTasks[0], false); err != nil {
+		t.Fatal(err)
 	}
+	h.AssertGolden("wucomic_files", h.Files())
+	h.AssertGolden("wucomic_image_hashes", h.Repos.ImageHashes)
+}
 
-	// 创建 io.Reader 对象
-	reader := bytes.NewReader([]byte(byteData))
-
-	// 创建 Image 对象
-	img, err := jpeg.Decode(reader)
-	if err != nil {
-		panic(err)
+// the pages referring to an existing image or blocked aren't downloaded again while the chapter is crawled again
+func TestWucomicCrawlerRecrawl(t *testing.T) {
+	cases := map[string]string{
+		"reference": "    imageSettings:\n      deduplicate: true\n      linkMode: reference\n",
+		"blocked": "    imageSettings:\n      blockedHashes:\n" +
+			"        - sha256:845bb60fe5c91b77a0b634e351b296a9222c94d686371b0ad741dff73c95edbb\n",
 	}
+	for name, settings := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := sitetest.New(t, base.Wucomic, settings)
+			crawler := NewWucomicCrawler()
+			ctx := context.Background()
 
-	file, err := os.Create("/home/cloud/Desktop/wang.jpg")
-	if err != nil {
-		panic(err)
-	}
+			novelTasks, err := crawler.CrawlCatalogPage(ctx, &entity.CatalogPageTask{SiteName: base.Wucomic,
+				Url: h.Url("/wucomic/catalog.html")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			chapterTasks, err := crawler.CrawlNovelPage(ctx, &novelTasks[0], false)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	// 保存图片
-	err = jpeg.Encode(file, img, nil)
-	if err != nil {
-		panic(err)
+			if err = crawler.CrawlChapterPage(ctx, &chapterTasks[0], false); err != nil {
+				t.Fatal(err)
+			}
+			downloaded := h.Requests("/wucomic/images/page-1.jpg")
+			if err = crawler.CrawlChapterPage(ctx, &chapterTasks[0], false); err != nil {
+				t.Fatal(err)
+			}
+			if requests := h.Requests("/wucomic/images/page-1.jpg"); requests != downloaded {
+				t.Errorf("the image shouldn't be downloaded again, it's requested %v times", requests)
+			}
+			for _, imageHash := range h.Repos.ImageHashes {
+				if imageHash.RefCount > 2 {
+					t.Errorf("the image is referenced by two pages only, but the count is %v", imageHash.RefCount)
+				}
+			}
+		})
 	}
 }
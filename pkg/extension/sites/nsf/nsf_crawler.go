@@ -3,12 +3,14 @@ package nfs
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
+	"crawlers/pkg/service"
+	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
 	"github.com/go-creed/sat"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
@@ -16,6 +18,9 @@ import (
 	"time"
 )
 
+// the titles of the novels on a catalog page
+const novelSelector = ".CGsectionTwo-right-content-unit .title"
+
 type NsfCrawler struct {
 	//redis       *cache.Redis
 	//mongoClient *db.Mongo
@@ -26,7 +31,7 @@ type NsfCrawler struct {
 }
 
 func NewNsfCrawler() *NsfCrawler {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.SiteNsf, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -37,6 +42,9 @@ func NewNsfCrawler() *NsfCrawler {
 	}
 }
 
+// the max number of pages of a chapter, in case the next page links form a loop
+const maxChapterPages = 50
+
 var removeTexts = []string{
 	"<p>更*多`精;彩'小*说'尽|在'ｗ'ｗ'ｗ．''Ｂ'．'Ｅ'第&amp;#*站</p><p>\");</p>",
 	"<p>ThisfilewassavedusingUNREGISTEREDversionofChmDecompiler.</p><p>DownloadChmDecompilerat:（结尾英文忽略即可）</p>",
@@ -45,10 +53,23 @@ var removeTexts = []string{
 
 // CrawlCatalogPage 解析每一页
 func (n *NsfCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *entity.CatalogPageTask) ([]entity.NovelTask, error) {
+	novelTasks, _, err := n.CrawlCatalogPageWithNext(ctx, catalogPageTask)
+	return novelTasks, err
+}
+
+// HasItems returns true if the catalog page has any novel, the next page link is ignored
+func (n *NsfCrawler) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(n.colly, url, novelSelector)
+}
+
+// CrawlCatalogPageWithNext 解析每一页，并返回下一页的地址，最后一页返回空字符串
+func (n *NsfCrawler) CrawlCatalogPageWithNext(ctx context.Context,
+	catalogPageTask *entity.CatalogPageTask) ([]entity.NovelTask, string, error) {
 	zap.L().Info("Got CatalogPageTask message", zap.String("url", catalogPageTask.Url))
 	var novelTasks []entity.NovelTask
+	var nextPageUrl string
 	cly := n.colly.Clone()
-	cly.OnHTML(".CGsectionTwo-right-content-unit .title", func(element *colly.HTMLElement) {
+	cly.OnHTML(novelSelector, func(element *colly.HTMLElement) {
 		href := element.Attr("href")
 		novelUrl := utils.BuildUrl(catalogPageTask.Url, href)
 		novelTasks = append(novelTasks, entity.NovelTask{
@@ -56,18 +77,24 @@ func (n *NsfCrawler) CrawlCatalogPage(ctx context.Context, catalogPageTask *enti
 			SiteName: catalogPageTask.SiteName,
 		})
 	})
+	cly.OnHTML(".CGsectionTwo-right-bottom-btn #next", func(nextBtn *colly.HTMLElement) {
+		if href := nextBtn.Attr("href"); href != "" && !strings.HasPrefix(href, "javascript") {
+			nextPageUrl = utils.BuildUrl(catalogPageTask.Url, href)
+		}
+	})
 
 	if err := cly.Visit(catalogPageTask.Url); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return novelTasks, nil
+	return novelTasks, nextPageUrl, nil
 }
 
 // CrawlNovelPage 解析具体的Novel
 func (n *NsfCrawler) CrawlNovelPage(ctx context.Context, novelTask *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("Got novel message", zap.String("url", novelTask.Url))
 	var createdTime = time.Now()
-	var novel = entity.Novel{Attributes: make(map[string]interface{}), CreatedTime: &createdTime}
+	var novel = entity.Novel{CatalogId: novelTask.CatalogId, Attributes: make(map[string]interface{}),
+		CreatedTime: &createdTime}
 	var chpTasks []entity.ChapterTask
 	cly := n.colly.Clone()
 	//获取名称
@@ -170,23 +197,20 @@ func (n *NsfCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.C
 	zap.L().Info("Got chapter message", zap.String("url", chapterTask.Url))
 	var createdTime = time.Now()
 
-	chromeCtx, cleanFunc := client.OpenChrome(context.Background())
-	defer cleanFunc()
-
-	var text string //content of the chapter
-	err = chromedp.Run(chromeCtx,
-		chromedp.Navigate(chapterTask.Url),
-		//chromedp.WaitNotPresent("//p[contains(text(),'内容未加载完成')]", chromedp.BySearch),
-		chromedp.InnerHTML("//div[@class='RBGsectionThree-content']", &text, chromedp.BySearch),
-	)
-	if err != nil {
+	//content of each page of the chapter, the pages are rendered by a tab of the browser pool
+	var pages []string
+	opts := render.OptionsOf(service.ConfigService.GetSiteConfig(base.SiteNsf))
+	if err = render.Do(ctx, chapterTask.Url, opts, func(tab *render.Tab) (tabErr error) {
+		pages, tabErr = n.crawlChapterContents(tab, opts, chapterTask.Url)
+		return
+	}); err != nil {
 		return
 	}
 
 	var chapterId *primitive.ObjectID
 
 	// for chapter
-	existingChapter, err := repository.ChapterRepo.FindByName(ctx, chapterTask.Name)
+	existingChapter, err := repository.ChapterRepo.FindByNovelIdAndName(ctx, chapterTask.NovelId, chapterTask.Name)
 	if err != nil {
 		return
 	}
@@ -212,30 +236,47 @@ func (n *NsfCrawler) CrawlChapterPage(ctx context.Context, chapterTask *entity.C
 	}
 
 	//for content
-	//page for chapters, need an enhancement
-	existingContent, err := repository.ContentRepo.FindByParentIdAndPage(ctx, chapterId, 0)
-	if err != nil {
-		return
+	for i := range pages {
+		for _, txt := range removeTexts {
+			pages[i] = strings.ReplaceAll(pages[i], txt, "")
+		}
 	}
+	err = repository.ContentRepo.SavePages(ctx, *chapterId, base.ParentTypeChapter, pages, skipSaveIfPresent)
+	return
+}
 
-	for _, txt := range removeTexts {
-		text = strings.ReplaceAll(text, txt, "")
-	}
+// crawlChapterContents 获取章节每一页的内容，章节被拆分为多页时沿着"下一页"链接继续抓取
+func (n *NsfCrawler) crawlChapterContents(tab *render.Tab, opts *render.Options, chapterUrl string) ([]string, error) {
+	var pages []string
+	visited := map[string]bool{}
+	pageUrl := chapterUrl
+	for pageUrl != "" && !visited[pageUrl] && len(pages) < maxChapterPages {
+		visited[pageUrl] = true
 
-	if existingContent != nil {
-		existingContent.Content = text
-	} else {
-		//create one
-		existingContent = &entity.Content{
-			ParentId:    *chapterId,
-			ParentType:  base.ParentTypeChapter,
-			Content:     text,
-			CreatedTime: &createdTime,
+		var text string
+		var nextLinks []*cdp.Node
+		if err := tab.Navigate(pageUrl, opts); err != nil {
+			return nil, err
 		}
-	}
+		if err := tab.Run(opts,
+			//chromedp.WaitNotPresent("//p[contains(text(),'内容未加载完成')]", chromedp.BySearch),
+			chromedp.InnerHTML("//div[@class='RBGsectionThree-content']", &text, chromedp.BySearch),
+			chromedp.Nodes("//a[contains(text(),'下一页')]", &nextLinks, chromedp.BySearch, chromedp.AtLeast(0)),
+		); err != nil {
+			return nil, err
+		}
+		pages = append(pages, text)
 
-	if !skipSaveIfPresent || existingContent == nil || existingContent.Id.IsZero() {
-		_, err = repository.ContentRepo.Save(ctx, existingContent)
+		pageUrl = ""
+		if len(nextLinks) > 0 {
+			if href := nextLinks[0].AttributeValue("href"); href != "" && !strings.HasPrefix(href, "javascript") {
+				pageUrl = utils.BuildUrl(chapterUrl, href)
+			}
+		}
 	}
-	return
+	if len(pages) > 1 {
+		zap.L().Info("chapter split across multiple pages", zap.String("url", chapterUrl),
+			zap.Int("pages", len(pages)))
+	}
+	return pages, nil
 }
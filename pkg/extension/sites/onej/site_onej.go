@@ -3,12 +3,13 @@ package onej
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/httpcache"
+	"crawlers/pkg/extension/render"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/service"
 	"encoding/base64"
 	"errors"
 	"github.com/gocolly/colly/v2"
-	"github.com/jeven2016/mylibs/client"
 	"github.com/jeven2016/mylibs/system"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson"
@@ -16,12 +17,15 @@ import (
 	"strings"
 )
 
+// the panels of the items on a catalog page
+const novelSelector = ".columns"
+
 type SiteOnej struct {
 	colly *colly.Collector
 }
 
 func NewSiteOnej() *SiteOnej {
-	collyClient, err := client.NewCollector("", 3)
+	collyClient, err := render.NewCollector(base.SiteOneJ, 3)
 	if err != nil {
 		zap.L().Warn("Could not create collector", zap.Error(err))
 	}
@@ -53,7 +57,7 @@ func (s *SiteOnej) CrawlCatalogPage(ctx context.Context, catalogPageMsg *entity.
 	var novelMsgs []entity.NovelTask
 
 	//遍历每一个面板进行解析
-	s.colly.OnHTML(".columns", func(element *colly.HTMLElement) {
+	s.colly.OnHTML(novelSelector, func(element *colly.HTMLElement) {
 		name := element.ChildText(".title.is-4.is-spaced>a")
 
 		//只关心所允许的人员总数
@@ -81,6 +85,7 @@ func (s *SiteOnej) CrawlCatalogPage(ctx context.Context, catalogPageMsg *entity.
 			Attributes: map[string]interface{}{
 				imgSrcKey:        imgSrc,
 				attachmentUriKey: attachmentUri,
+				base.AttrCover:   imgSrc,
 			},
 		})
 	})
@@ -97,6 +102,11 @@ func (s *SiteOnej) CrawlCatalogPage(ctx context.Context, catalogPageMsg *entity.
 	return novelMsgs, nil
 }
 
+// HasItems returns true if the catalog page has any panel, the page isn't skipped even if it's handled already
+func (s *SiteOnej) HasItems(ctx context.Context, url string) (bool, error) {
+	return render.HasElements(s.colly, url, novelSelector)
+}
+
 // CrawlNovelPage 解析具体的Novel
 func (s *SiteOnej) CrawlNovelPage(ctx context.Context, novelPageMsg *entity.NovelTask, skipSaveIfPresent bool) ([]entity.ChapterTask, error) {
 	zap.L().Info("Got novel message", zap.String("name", novelPageMsg.Name))
@@ -133,7 +143,7 @@ func (s *SiteOnej) CrawlNovelPage(ctx context.Context, novelPageMsg *entity.Nove
 		//下载附件
 		if attachmentUrl, ok := novelPageMsg.Attributes[attachmentUriKey]; ok {
 			attachUrlString := attachmentUrl.(string)
-			restyAttClient, err := client.GetRestyClient(attachUrlString, true)
+			restyAttClient, err := httpcache.GetRestyClient(base.SiteOneJ, attachUrlString)
 			if err != nil {
 				return nil, err
 			}
@@ -163,7 +173,7 @@ func (s *SiteOnej) CrawlNovelPage(ctx context.Context, novelPageMsg *entity.Nove
 
 			imgUrlString := imgUrl.(string)
 			localFile := strings.TrimRight(destDir, "/") + "/" + imageName + ".jpg"
-			restyClient, err := client.GetRestyClient(imgUrlString, true)
+			restyClient, err := httpcache.GetRestyClient(base.SiteOneJ, imgUrlString)
 			if err != nil {
 				return nil, err
 			}
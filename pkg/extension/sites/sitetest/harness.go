@@ -0,0 +1,185 @@
+// Package sitetest the offline harness of the site crawlers: the recorded pages under testdata/fixtures are
+// served by a local http server, in which {{server}} stands for its address, redis is replaced with miniredis and the repositories used by the crawlers
+// with the in-memory ones, so that the tasks extracted and the files written can be checked with the golden
+// files under testdata/golden. Run the tests with -update to rewrite the golden files.
+package sitetest
+
+import (
+	"crawlers/pkg/repository"
+	"crawlers/pkg/service"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jeven2016/mylibs/cache"
+	"github.com/jeven2016/mylibs/system"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	fixturesDir = "testdata/fixtures"
+	goldenDir   = "testdata/golden"
+
+	// the placeholders of the address of the fixture server and the directory of the site in the golden files
+	serverPlaceholder = "{{server}}"
+	dirPlaceholder    = "{{dir}}"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the actual results")
+
+// Harness the environment of a site crawler in a test, everything is restored once the test finishes
+type Harness struct {
+	t      *testing.T
+	Server *httptest.Server
+	Redis  *miniredis.Miniredis
+	Repos  *MemoryRepos
+	//the directory attribute of the site, where the files are written into
+	Dir string
+
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+// New starts the harness for the site, the extra yaml is appended to the settings of the site, e.g.
+// "    imageSettings:\n      deduplicate: true"
+func New(t *testing.T, siteName string, extraSettings string) *Harness {
+	h := &Harness{
+		t:     t,
+		Redis: miniredis.RunT(t),
+		Repos: NewMemoryRepos(),
+		Dir:   t.TempDir(),
+
+		requests: make(map[string]int),
+	}
+	h.Server = httptest.NewServer(http.HandlerFunc(h.serveFixture))
+	t.Cleanup(h.Server.Close)
+
+	previousSystem := system.GetSystem()
+	system.SetSystem(&system.System{
+		RedisClient: &cache.Redis{Client: redis.NewClient(&redis.Options{Addr: h.Redis.Addr()})},
+	})
+	t.Cleanup(func() {
+		system.SetSystem(previousSystem)
+	})
+
+	LoadConfig(t, fmt.Sprintf("webSites:\n  - name: %v\n    attributes:\n      directory: %v\n%v", siteName, h.Dir,
+		extraSettings))
+	h.useMemoryRepos()
+	return h
+}
+
+// LoadConfig replaces service.ConfigService with the one loaded from the yaml, the previous one is restored once
+// the test finishes
+func LoadConfig(t testing.TB, yamlConfig string) {
+	t.Helper()
+	previous := service.ConfigService
+	t.Cleanup(func() {
+		service.ConfigService = previous
+	})
+	service.ConfigService = service.NewConfigService()
+	if err := service.ConfigService.LoadInternalConfig(yamlConfig, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (h *Harness) useMemoryRepos() {
+	novelRepo, chapterRepo := repository.NovelRepo, repository.ChapterRepo
+	assetRepo, imageHashRepo := repository.ChapterAssetRepo, repository.ImageHashRepo
+	repository.NovelRepo = &memoryNovelRepo{h.Repos}
+	repository.ChapterRepo = &memoryChapterRepo{h.Repos}
+	repository.ChapterAssetRepo = &memoryChapterAssetRepo{h.Repos}
+	repository.ImageHashRepo = &memoryImageHashRepo{h.Repos}
+	h.t.Cleanup(func() {
+		repository.NovelRepo, repository.ChapterRepo = novelRepo, chapterRepo
+		repository.ChapterAssetRepo, repository.ImageHashRepo = assetRepo, imageHashRepo
+	})
+}
+
+// serveFixture serves the files under testdata/fixtures, the placeholder of the server in the html files is
+// replaced with the actual address since the pages usually refer to the absolute urls of the images
+func (h *Harness) serveFixture(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	h.requests[r.URL.Path]++
+	h.lock.Unlock()
+
+	file := filepath.Join(fixturesDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(file, ".html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data = []byte(strings.ReplaceAll(string(data), serverPlaceholder, h.Server.URL))
+	}
+	_, _ = w.Write(data)
+}
+
+// Url the url of the fixture, e.g. /catalog/1.html for testdata/fixtures/catalog/1.html
+func (h *Harness) Url(path string) string {
+	return h.Server.URL + path
+}
+
+// Requests the number of the requests of the fixture, e.g. /catalog/1.html
+func (h *Harness) Requests(path string) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.requests[path]
+}
+
+// Files the files written into the directory of the site with their sizes, e.g. "novel/cover.jpg 12"
+func (h *Harness) Files() []string {
+	var files []string
+	err := filepath.Walk(h.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(h.Dir, path)
+		files = append(files, fmt.Sprintf("%v %v", filepath.ToSlash(rel), info.Size()))
+		return err
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// AssertGolden compares the json of the value with testdata/golden/<name>.json, the address of the fixture
+// server and the directory of the site are replaced with the placeholders
+func (h *Harness) AssertGolden(name string, value any) {
+	h.t.Helper()
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	actual := strings.NewReplacer(h.Server.URL, serverPlaceholder, h.Dir, dirPlaceholder).Replace(string(data)) + "\n"
+
+	file := filepath.Join(goldenDir, name+".json")
+	if *update {
+		if err = os.MkdirAll(goldenDir, os.ModePerm); err == nil {
+			err = os.WriteFile(file, []byte(actual), 0644)
+		}
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		h.t.Fatalf("%v, run the test with -update to create it", err)
+	}
+	if actual != string(expected) {
+		h.t.Errorf("the result differs from %v, run the test with -update if it's expected\nexpected:\n%v\nactual:\n%v",
+			file, string(expected), actual)
+	}
+}
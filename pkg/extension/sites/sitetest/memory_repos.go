@@ -0,0 +1,278 @@
+package sitetest
+
+import (
+	"context"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"encoding/binary"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+)
+
+var errNotSupported = errors.New("not supported by the in-memory repository")
+
+// MemoryRepos the documents saved by the crawlers, the ids are generated in sequence so that they're stable
+// in the golden files
+type MemoryRepos struct {
+	lock        sync.Mutex
+	sequence    uint32
+	Novels      []*entity.Novel
+	Chapters    []*entity.Chapter
+	Assets      []*entity.ChapterAsset
+	ImageHashes []*entity.ImageHash
+}
+
+func NewMemoryRepos() *MemoryRepos {
+	return &MemoryRepos{}
+}
+
+func (r *MemoryRepos) nextId() primitive.ObjectID {
+	r.sequence++
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[8:], r.sequence)
+	return id
+}
+
+type memoryNovelRepo struct {
+	*MemoryRepos
+}
+
+func (r *memoryNovelRepo) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Novel, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, novel := range r.Novels {
+		if novel.Id == id {
+			return novel, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryNovelRepo) FindIdByName(ctx context.Context, name string) (*primitive.ObjectID, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, novel := range r.Novels {
+		if novel.Name == name {
+			return &novel.Id, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryNovelRepo) FindByCatalogId(ctx context.Context, catalogId primitive.ObjectID, skip,
+	limit int64) ([]*entity.Novel, int64, error) {
+	return nil, 0, errNotSupported
+}
+
+func (r *memoryNovelRepo) FindPageByCatalogId(ctx context.Context, catalogId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return nil, errNotSupported
+}
+
+func (r *memoryNovelRepo) ExistsByName(ctx context.Context, name string) (bool, error) {
+	id, err := r.FindIdByName(ctx, name)
+	return id != nil, err
+}
+
+func (r *memoryNovelRepo) Insert(ctx context.Context, novel *entity.Novel) (*primitive.ObjectID, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	novel.Id = r.nextId()
+	r.Novels = append(r.Novels, novel)
+	return &novel.Id, nil
+}
+
+func (r *memoryNovelRepo) Save(ctx context.Context, novel *entity.Novel) (*primitive.ObjectID, error) {
+	if novel.Id.IsZero() {
+		return r.Insert(ctx, novel)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.Novels {
+		if r.Novels[i].Id == novel.Id {
+			r.Novels[i] = novel
+		}
+	}
+	return &novel.Id, nil
+}
+
+func (r *memoryNovelRepo) DeleteByIds(ctx context.Context, ids []*primitive.ObjectID) error {
+	return errNotSupported
+}
+
+type memoryChapterRepo struct {
+	*MemoryRepos
+}
+
+func (r *memoryChapterRepo) find(match func(chapter *entity.Chapter) bool) *entity.Chapter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, chapter := range r.Chapters {
+		if match(chapter) {
+			return chapter
+		}
+	}
+	return nil
+}
+
+func (r *memoryChapterRepo) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Chapter, error) {
+	return r.find(func(chapter *entity.Chapter) bool { return chapter.Id == id }), nil
+}
+
+func (r *memoryChapterRepo) FindByName(ctx context.Context, name string) (*entity.Chapter, error) {
+	return r.find(func(chapter *entity.Chapter) bool { return chapter.Name == name }), nil
+}
+
+func (r *memoryChapterRepo) FindByNovelIdAndName(ctx context.Context, novelId primitive.ObjectID,
+	name string) (*entity.Chapter, error) {
+	return r.find(func(chapter *entity.Chapter) bool {
+		return chapter.NovelId == novelId && chapter.Name == name
+	}), nil
+}
+
+func (r *memoryChapterRepo) FindByNovelId(ctx context.Context, novelId primitive.ObjectID, skip,
+	limit int64) ([]*entity.Chapter, int64, error) {
+	return nil, 0, errNotSupported
+}
+
+func (r *memoryChapterRepo) FindPageByNovelId(ctx context.Context, novelId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return nil, errNotSupported
+}
+
+func (r *memoryChapterRepo) FindSibling(ctx context.Context, chapter *entity.Chapter,
+	next bool) (*entity.Chapter, error) {
+	return nil, errNotSupported
+}
+
+func (r *memoryChapterRepo) ExistsByName(ctx context.Context, name string) (bool, error) {
+	chapter, err := r.FindByName(ctx, name)
+	return chapter != nil, err
+}
+
+func (r *memoryChapterRepo) Insert(ctx context.Context, chapter *entity.Chapter) (*primitive.ObjectID, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	chapter.Id = r.nextId()
+	r.Chapters = append(r.Chapters, chapter)
+	return &chapter.Id, nil
+}
+
+func (r *memoryChapterRepo) BulkInsert(ctx context.Context, chapters []*entity.Chapter,
+	novelId *primitive.ObjectID) error {
+	for _, chapter := range chapters {
+		if novelId != nil {
+			chapter.NovelId = *novelId
+		}
+		if _, err := r.Insert(ctx, chapter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryChapterRepo) Save(ctx context.Context, chapter *entity.Chapter) (*primitive.ObjectID, error) {
+	if chapter.Id.IsZero() {
+		return r.Insert(ctx, chapter)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i := range r.Chapters {
+		if r.Chapters[i].Id == chapter.Id {
+			r.Chapters[i] = chapter
+		}
+	}
+	return &chapter.Id, nil
+}
+
+type memoryChapterAssetRepo struct {
+	*MemoryRepos
+}
+
+func (r *memoryChapterAssetRepo) FindByChapterId(ctx context.Context,
+	chapterId primitive.ObjectID) ([]*entity.ChapterAsset, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var assets []*entity.ChapterAsset
+	for _, asset := range r.Assets {
+		if asset.ChapterId == chapterId {
+			assets = append(assets, asset)
+		}
+	}
+	return assets, nil
+}
+
+func (r *memoryChapterAssetRepo) FindByChapterIdAndPage(ctx context.Context, chapterId primitive.ObjectID,
+	page int) (*entity.ChapterAsset, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, asset := range r.Assets {
+		if asset.ChapterId == chapterId && asset.Page == page {
+			return asset, nil
+		}
+	}
+	return nil, nil
+}
+
+// Upsert the asset is replaced by the chapter and the page
+func (r *memoryChapterAssetRepo) Upsert(ctx context.Context, asset *entity.ChapterAsset) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, existing := range r.Assets {
+		if existing.ChapterId == asset.ChapterId && existing.Page == asset.Page {
+			asset.Id = existing.Id
+			r.Assets[i] = asset
+			return nil
+		}
+	}
+	asset.Id = r.nextId()
+	r.Assets = append(r.Assets, asset)
+	return nil
+}
+
+type memoryImageHashRepo struct {
+	*MemoryRepos
+}
+
+func (r *memoryImageHashRepo) FindByHash(ctx context.Context, hash string) (*entity.ImageHash, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, imageHash := range r.ImageHashes {
+		if imageHash.Hash == hash {
+			return imageHash, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryImageHashRepo) Insert(ctx context.Context, imageHash *entity.ImageHash) (*primitive.ObjectID,
+	error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	imageHash.Id = r.nextId()
+	if imageHash.RefCount == 0 {
+		imageHash.RefCount = 1
+	}
+	r.ImageHashes = append(r.ImageHashes, imageHash)
+	return &imageHash.Id, nil
+}
+
+func (r *memoryImageHashRepo) IncreaseRefCount(ctx context.Context, id primitive.ObjectID) error {
+	return r.update(id, func(imageHash *entity.ImageHash) { imageHash.RefCount++ })
+}
+
+func (r *memoryImageHashRepo) UpdatePath(ctx context.Context, id primitive.ObjectID, path string) error {
+	return r.update(id, func(imageHash *entity.ImageHash) { imageHash.Path = path })
+}
+
+func (r *memoryImageHashRepo) update(id primitive.ObjectID, fn func(imageHash *entity.ImageHash)) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, imageHash := range r.ImageHashes {
+		if imageHash.Id == id {
+			fn(imageHash)
+		}
+	}
+	return nil
+}
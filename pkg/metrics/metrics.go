@@ -18,6 +18,8 @@ var MetricsTotalCatalogPageTasks prometheus.Counter
 var MetricsTotalNovelTasks prometheus.Counter
 var MetricsTotalChapterTasks prometheus.Counter
 var MetricsComicPicDownloaded prometheus.Counter
+var MetricsComicPicDeduplicated prometheus.Counter
+var MetricsComicPicBlocked prometheus.Counter
 
 var MetricsFailedCatalogPageTasksGauge prometheus.Gauge
 var MetricsFailedNovelTasksGauge prometheus.Gauge
@@ -28,6 +30,19 @@ var MetricsSucceedCatalogPageTasksGauge prometheus.Gauge
 var MetricsSucceedNovelTasksGauge prometheus.Gauge
 var MetricsSucceedChapterTasksGauge prometheus.Gauge
 
+// the pool of the headless browser
+var MetricsBrowserTabsInUse prometheus.Gauge
+var MetricsBrowserTabsIdle prometheus.Gauge
+var MetricsBrowserWaitingRequests prometheus.Gauge
+var MetricsBrowserPagesRendered prometheus.Counter
+var MetricsBrowserRenderFailures prometheus.Counter
+var MetricsBrowserStarts prometheus.Counter
+
+// the proxies of the pool, they're labeled by the redacted urls
+var MetricsProxyRequests *prometheus.CounterVec
+var MetricsProxyHealthy *prometheus.GaugeVec
+var MetricsProxyEjections *prometheus.CounterVec
+
 func init() {
 	//SiteRuningTasksGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
 	//	Name: "running_tasks_gauge",
@@ -74,6 +89,16 @@ func init() {
 		Help: "The total number of comic pictures downloaded",
 	})
 
+	MetricsComicPicDeduplicated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_total_comic_picture_deduplicated",
+		Help: "The total number of comic pictures reused from already downloaded files",
+	})
+
+	MetricsComicPicBlocked = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_total_comic_picture_blocked",
+		Help: "The total number of comic pictures skipped by the blocklist",
+	})
+
 	MetricsFailedCatalogPageTasksGauge = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "crawler_failed_catalog_page_tasks_count",
 		Help: "The total number of failed catalog page tasks",
@@ -108,4 +133,49 @@ func init() {
 		Name: "crawler_succeed_chapter_tasks_count",
 		Help: "The total number of succeed chapter tasks",
 	})
+
+	MetricsBrowserTabsInUse = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "crawler_browser_tabs_in_use",
+		Help: "The number of browser tabs rendering pages",
+	})
+
+	MetricsBrowserTabsIdle = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "crawler_browser_tabs_idle",
+		Help: "The number of idle browser tabs kept for reuse",
+	})
+
+	MetricsBrowserWaitingRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "crawler_browser_waiting_requests",
+		Help: "The number of requests waiting for a browser tab",
+	})
+
+	MetricsBrowserPagesRendered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_total_browser_pages_rendered",
+		Help: "The total number of pages rendered by the browser",
+	})
+
+	MetricsBrowserRenderFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_total_browser_render_failures",
+		Help: "The total number of pages failed to render",
+	})
+
+	MetricsBrowserStarts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_total_browser_starts",
+		Help: "The total number of times the browser is started",
+	})
+
+	MetricsProxyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_total_proxy_requests",
+		Help: "The total number of requests sent through the proxy by the result",
+	}, []string{"proxy", "result"})
+
+	MetricsProxyHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crawler_proxy_healthy",
+		Help: "Whether the proxy is in rotation, 0 means it's ejected",
+	}, []string{"proxy"})
+
+	MetricsProxyEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_total_proxy_ejections",
+		Help: "The total number of times the proxy is ejected",
+	}, []string{"proxy"})
 }
@@ -1,5 +1,13 @@
 package dto
 
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
 type CatalogPageRequest struct {
 	SiteKey string `json:"siteKey"`
 	Catalog string `json:"catalog"`
@@ -12,8 +20,349 @@ type CreateRequest struct {
 	Entity        any    `json:"entity"`
 	Collection    string `json:"collection"`
 	RedisCacheKey string `json:"redisCacheKey"`
+
+	ExistsFunc func() (bool, error) `json:"-"` //checks if the name exists in mongo
+}
+
+// SitePatch the fields of a site to be updated, the nil ones are left untouched
+type SitePatch struct {
+	Name        *string                `json:"name"`
+	DisplayName *string                `json:"displayName"`
+	Description *string                `json:"description"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	CrawlerType *base.CrawlerType      `json:"crawlerType"`
+}
+
+// CatalogPatch the fields of a catalog to be updated, the nil ones are left untouched
+type CatalogPatch struct {
+	SiteId      *primitive.ObjectID    `json:"siteId"`
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	CrawlerType *base.CrawlerType      `json:"crawlerType"`
+}
+
+// DeleteOptions the query parameters of deleting a site or catalog
+type DeleteOptions struct {
+	Cascade     bool `form:"cascade"`     //delete the tasks, novels, chapters and contents as well
+	DeleteFiles bool `form:"deleteFiles"` //remove the downloaded files of the novels, only works with cascade
+}
+
+// DeleteResult the number of documents deleted in each collection
+type DeleteResult struct {
+	Deleted     map[string]int64 `json:"deleted"`
+	Directories []string         `json:"directories,omitempty"` //the directories removed
 }
 
 type DeleteTasksRequest struct {
 	IdArray []string `json:"idArray"`
 }
+
+// TaskFilter the conditions to query the tasks, siteName and status are bound from the ListQuery while listing
+type TaskFilter struct {
+	SiteName   string          `form:"-" json:"siteName"`
+	CatalogId  string          `form:"catalogId" json:"catalogId"` //for the catalog page tasks and novel tasks
+	NovelId    string          `form:"novelId" json:"novelId"`     //for the chapter tasks
+	Status     base.TaskStatus `form:"-" json:"status"`
+	MinRetries int             `form:"minRetries" json:"minRetries" binding:"min=0"`
+}
+
+// ApiKeyRequest the request body for creating an api key
+type ApiKeyRequest struct {
+	Name      string            `json:"name" binding:"required"`
+	Scopes    []string          `json:"scopes" binding:"dive,oneof=read submit-tasks admin"`
+	SiteRoles map[string]string `json:"siteRoles" binding:"dive,keys,required,endkeys,oneof=viewer operator admin"`
+}
+
+// ApiKeyPatch the fields of an api key to be updated, the nil ones are left untouched
+type ApiKeyPatch struct {
+	Scopes    *[]string         `json:"scopes" binding:"omitempty,dive,oneof=read submit-tasks admin"`
+	SiteRoles map[string]string `json:"siteRoles" binding:"dive,keys,required,endkeys,oneof=viewer operator admin"`
+	Disabled  *bool             `json:"disabled"`
+}
+
+// AuditFilter the filters of the audit logs besides the common list query, status filters the http status
+type AuditFilter struct {
+	Caller     string `form:"caller"`
+	Method     string `form:"method"`
+	Route      string `form:"route"` //the route pattern, e.g. /api/v1/sites/:siteId
+	AffectedId string `form:"affectedId"`
+}
+
+// EffectiveSiteSettings the merged settings of a site, the sources are keyed by the json path of each value,
+// e.g. regexSettings.pagePrefix: yaml
+type EffectiveSiteSettings struct {
+	SiteName string               `json:"siteName"`
+	Settings *entity.SiteSettings `json:"settings"`
+	Sources  map[string]string    `json:"sources"`
+}
+
+// SiteSettingsDiff a value of the site settings differing between the config files and mongo, a nil value
+// means it isn't set in that layer
+type SiteSettingsDiff struct {
+	SiteName string `json:"siteName"`
+	Path     string `json:"path"`
+	File     any    `json:"file"`
+	Stored   any    `json:"stored"`
+}
+
+// CreatedApiKey the created api key, the key is only returned once
+type CreatedApiKey struct {
+	*entity.ApiKey
+	Key string `json:"key"`
+}
+
+// BulkTaskRequest the request body of an action on the tasks matching the filter
+type BulkTaskRequest struct {
+	Type   string     `json:"type" binding:"required,oneof=catalogPage novel chapter"`
+	Action string     `json:"action" binding:"required,oneof=requeue reset delete finish"`
+	DryRun bool       `json:"dryRun"` //only count the affected tasks
+	Filter TaskFilter `json:"filter"`
+}
+
+// StartTasksRequest the deferred novel tasks to start, either all of a catalog or the selected ones
+type StartTasksRequest struct {
+	CatalogId string   `json:"catalogId"`
+	Ids       []string `json:"ids"`
+}
+
+// StartTasksResult the number of deferred novel tasks started
+type StartTasksResult struct {
+	Started int64 `json:"started"`
+}
+
+// DeferredNovel a novel task created with DownloadNow=false, which waits to be started manually
+type DeferredNovel struct {
+	Id          primitive.ObjectID `json:"id"`
+	CatalogId   primitive.ObjectID `json:"catalogId"`
+	SiteName    string             `json:"siteName"`
+	Name        string             `json:"name"`
+	Url         string             `json:"url"`
+	Cover       string             `json:"cover,omitempty"`
+	CreatedDate *time.Time         `json:"createdDate"`
+}
+
+// BulkTaskResult the number of tasks matched and affected by a bulk action
+type BulkTaskResult struct {
+	Type     string `json:"type"`
+	Action   string `json:"action"`
+	DryRun   bool   `json:"dryRun"`
+	Matched  int64  `json:"matched"`
+	Affected int64  `json:"affected"`
+}
+
+// ContentDiff the diff between two revisions of a content
+type ContentDiff struct {
+	ParentId string          `json:"parentId"`
+	Page     int             `json:"page"`
+	From     int             `json:"from"`
+	To       int             `json:"to"`
+	Lines    []base.DiffLine `json:"lines"`
+}
+
+// ChapterContent the content of a chapter, the pages are joined in order
+type ChapterContent struct {
+	ChapterId string       `json:"chapterId"`
+	NovelId   string       `json:"novelId"`
+	Name      string       `json:"name"`
+	Order     int          `json:"order"`
+	Pages     int          `json:"pages"`
+	Content   string       `json:"content"`
+	Images    []string     `json:"images,omitempty"` //urls of the downloaded pictures of a comic chapter
+	Prev      *ChapterLink `json:"prev,omitempty"`
+	Next      *ChapterLink `json:"next,omitempty"`
+}
+
+// ChapterLink link to the previous or next chapter
+type ChapterLink struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Order int    `json:"order"`
+	Url   string `json:"url"`
+}
+
+// PageRequest the pagination parameters, page starts from 1
+type PageRequest struct {
+	Page int `form:"page"`
+	Size int `form:"size"`
+}
+
+// ListQuery the common query parameters of the list endpoints, the filters not supported by an endpoint
+// are rejected
+type ListQuery struct {
+	PageRequest
+	Cursor   string    `form:"cursor"` //id of the last item of the previous page, works with sorting by id
+	Sort     string    `form:"sort"`   //field to sort by, e.g. name, created
+	Order    string    `form:"order"`  //asc or desc
+	Status   int       `form:"status"`
+	SiteName string    `form:"siteName"`
+	Name     string    `form:"name"` //name contains, case-insensitive
+	From     time.Time `form:"from"` //created after, in RFC3339 format
+	To       time.Time `form:"to"`   //created before, in RFC3339 format
+}
+
+// PageResult a page of items
+type PageResult struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	Items      any    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"` //set when sorting by id and more items might exist
+}
+
+// SearchRequest the query parameters of full-text search
+type SearchRequest struct {
+	Query       string `form:"q" binding:"required"`
+	Type        string `form:"type"` //novel or chapter, novel by default
+	SiteId      string `form:"siteId"`
+	CatalogId   string `form:"catalogId"`
+	CrawlerType int    `form:"crawlerType"`
+	Page        int    `form:"page"` //starts from 1
+	Size        int    `form:"size"`
+}
+
+// SearchHit a ranked search result, the matched words in Name and Snippet are wrapped with <em></em>
+type SearchHit struct {
+	Type      string  `json:"type"`
+	Id        string  `json:"id"` //novel id or chapter id
+	NovelId   string  `json:"novelId"`
+	NovelName string  `json:"novelName"`
+	CatalogId string  `json:"catalogId,omitempty"`
+	Name      string  `json:"name"`
+	Page      int     `json:"page"` //the page of chapter content
+	Score     float64 `json:"score"`
+	Snippet   string  `json:"snippet"`
+}
+
+type SearchResult struct {
+	Total int64        `json:"total"`
+	Page  int          `json:"page"`
+	Size  int          `json:"size"`
+	Hits  []*SearchHit `json:"hits"`
+}
+
+// ProgressRequest the request body for updating the reading progress of a novel
+type ProgressRequest struct {
+	ChapterId string `json:"chapterId" binding:"required"`
+	Position  int    `json:"position"`
+}
+
+// BookmarkRequest the request body for creating a bookmark
+type BookmarkRequest struct {
+	ChapterId string `json:"chapterId" binding:"required"`
+	Position  int    `json:"position"`
+	Note      string `json:"note"`
+}
+
+// ContinueReading an item of the continue reading feed
+type ContinueReading struct {
+	NovelId     string       `json:"novelId"`
+	NovelName   string       `json:"novelName"`
+	ChapterId   string       `json:"chapterId"`
+	ChapterName string       `json:"chapterName"`
+	Position    int          `json:"position"`
+	UpdatedTime *time.Time   `json:"updatedTime"`
+	Current     *ChapterLink `json:"current"`
+	Next        *ChapterLink `json:"next,omitempty"` //nil if the last chapter is being read
+}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Normalize applies the default page and size
+func (p *PageRequest) Normalize() {
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 {
+		p.Size = DefaultPageSize
+	}
+	p.Size = min(p.Size, MaxPageSize)
+}
+
+// Descending returns true if the order is desc, otherwise the default order is used
+func (q *ListQuery) Descending(defaultDesc bool) bool {
+	switch strings.ToLower(q.Order) {
+	case "asc":
+		return false
+	case "desc":
+		return true
+	}
+	return defaultDesc
+}
+
+func (p *PageRequest) Skip() int64 {
+	return int64((p.Page - 1) * p.Size)
+}
+
+func (p *PageRequest) Limit() int64 {
+	return int64(p.Size)
+}
+
+// IsEmpty returns true if no condition is specified
+func (f *TaskFilter) IsEmpty() bool {
+	return f.SiteName == "" && f.CatalogId == "" && f.NovelId == "" && f.Status == 0 && f.MinRetries == 0
+}
+
+// Apply sets the fields of the patch into the api key
+func (p *ApiKeyPatch) Apply(key *entity.ApiKey) {
+	if p.Scopes != nil {
+		key.Scopes = *p.Scopes
+	}
+	if p.SiteRoles != nil {
+		key.SiteRoles = p.SiteRoles
+	}
+	if p.Disabled != nil {
+		key.Disabled = *p.Disabled
+	}
+}
+
+// Apply sets the fields of the patch into the site
+func (p *SitePatch) Apply(site *entity.Site) {
+	if p.Name != nil {
+		site.Name = *p.Name
+	}
+	if p.DisplayName != nil {
+		site.DisplayName = *p.DisplayName
+	}
+	if p.Description != nil {
+		site.Description = *p.Description
+	}
+	if p.Attributes != nil {
+		site.Attributes = p.Attributes
+	}
+	if p.CrawlerType != nil {
+		site.CrawlerType = *p.CrawlerType
+	}
+}
+
+// Apply sets the fields of the patch into the catalog
+func (p *CatalogPatch) Apply(catalog *entity.Catalog) {
+	if p.SiteId != nil {
+		catalog.SiteId = *p.SiteId
+	}
+	if p.Name != nil {
+		catalog.Name = *p.Name
+	}
+	if p.Description != nil {
+		catalog.Description = *p.Description
+	}
+	if p.Attributes != nil {
+		catalog.Attributes = p.Attributes
+	}
+	if p.CrawlerType != nil {
+		catalog.CrawlerType = *p.CrawlerType
+	}
+}
+
+// Add sums up the number of deleted documents
+func (r *DeleteResult) Add(deleted map[string]int64) {
+	if r.Deleted == nil {
+		r.Deleted = map[string]int64{}
+	}
+	for collection, count := range deleted {
+		r.Deleted[collection] += count
+	}
+}
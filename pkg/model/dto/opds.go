@@ -0,0 +1,83 @@
+package dto
+
+import "encoding/xml"
+
+const (
+	OpdsNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	OpdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType      = "application/opensearchdescription+xml"
+
+	OpdsRelAcquisition = "http://opds-spec.org/acquisition"
+	OpdsRelImage       = "http://opds-spec.org/image"
+	OpdsRelThumbnail   = "http://opds-spec.org/image/thumbnail"
+)
+
+// OpdsFeed an OPDS 1.2 catalog feed in Atom format
+type OpdsFeed struct {
+	XMLName         xml.Name    `xml:"feed"`
+	Xmlns           string      `xml:"xmlns,attr"`
+	XmlnsDc         string      `xml:"xmlns:dc,attr"`
+	XmlnsOpds       string      `xml:"xmlns:opds,attr"`
+	XmlnsOpenSearch string      `xml:"xmlns:opensearch,attr"`
+	Id              string      `xml:"id"`
+	Title           string      `xml:"title"`
+	Updated         string      `xml:"updated"`
+	Author          *OpdsAuthor `xml:"author,omitempty"`
+	Links           []*OpdsLink `xml:"link"`
+
+	//pagination of the acquisition feeds, see OpenSearch 1.1
+	TotalResults int64 `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int   `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int64 `xml:"opensearch:startIndex,omitempty"`
+
+	Entries []*OpdsEntry `xml:"entry"`
+}
+
+type OpdsAuthor struct {
+	Name string `xml:"name"`
+}
+
+type OpdsLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type OpdsEntry struct {
+	Id       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Author   *OpdsAuthor  `xml:"author,omitempty"`
+	Language string       `xml:"dc:language,omitempty"`
+	Summary  *OpdsContent `xml:"summary,omitempty"`
+	Content  *OpdsContent `xml:"content,omitempty"`
+	Links    []*OpdsLink  `xml:"link"`
+}
+
+type OpdsContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// OpenSearchDescription tells the e-reader apps how to search the catalog
+type OpenSearchDescription struct {
+	XMLName        xml.Name         `xml:"OpenSearchDescription"`
+	Xmlns          string           `xml:"xmlns,attr"`
+	ShortName      string           `xml:"ShortName"`
+	Description    string           `xml:"Description"`
+	InputEncoding  string           `xml:"InputEncoding"`
+	OutputEncoding string           `xml:"OutputEncoding"`
+	Urls           []*OpenSearchUrl `xml:"Url"`
+}
+
+type OpenSearchUrl struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// OpdsRequest the query parameters of the paginated OPDS feeds
+type OpdsRequest struct {
+	Query string `form:"q"`
+	PageRequest
+}
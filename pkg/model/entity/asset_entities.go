@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"crawlers/pkg/base"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ImageHash 图片内容索引(hash -> 本地路径)，用于跨novel、chapter的图片去重
+type ImageHash struct {
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Hash     string             `bson:"hash" json:"hash"`                       //sha-256
+	PHash    string             `bson:"pHash,omitempty" json:"pHash,omitempty"` //perceptual hash
+	Path     string             `bson:"path" json:"path"`
+	Size     int64              `bson:"size" json:"size"`
+	SiteName string             `bson:"siteName" json:"siteName"`
+	RefCount int                `bson:"refCount" json:"refCount"`
+
+	CreatedTime *time.Time `bson:"created" json:"createdTime"`
+	UpdatedTime *time.Time `bson:"updated" json:"updatedTime"`
+}
+
+// ChapterAsset 章节下载清单中的一项(如漫画的一页图片)
+type ChapterAsset struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChapterId primitive.ObjectID `bson:"chapterId" json:"chapterId"`
+	NovelId   primitive.ObjectID `bson:"novelId,omitempty" json:"novelId"`
+	SiteName  string             `bson:"siteName" json:"siteName"`
+	Page      int                `bson:"page" json:"page"` //page index in the chapter
+	SourceUrl string             `bson:"sourceUrl" json:"sourceUrl"`
+	LocalPath string             `bson:"localPath" json:"localPath"` //storage key of the asset
+	Size      int64              `bson:"size" json:"size"`
+	Hash      string             `bson:"hash,omitempty" json:"hash,omitempty"`
+	Status    base.AssetStatus   `bson:"status" json:"status"`
+	Error     string             `bson:"error,omitempty" json:"error,omitempty"`
+
+	CreatedTime *time.Time `bson:"created" json:"createdTime"`
+	UpdatedTime *time.Time `bson:"updated" json:"updatedTime"`
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"crawlers/pkg/base"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ApiKey 访问api的key，只保存key的sha-256
+type ApiKey struct {
+	Id     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name   string             `bson:"name" json:"name"`     //the caller identity of the key
+	Prefix string             `bson:"prefix" json:"prefix"` //first characters of the key to recognize it
+	Hash   string             `bson:"hash" json:"-"`
+	Scopes []string           `bson:"scopes" json:"scopes"`
+	//site name -> role, the role on a site is the higher one of this and the role implied by the scopes
+	SiteRoles map[string]string `bson:"siteRoles,omitempty" json:"siteRoles,omitempty"`
+	Disabled  bool              `bson:"disabled" json:"disabled"`
+
+	CreatedTime  *time.Time `bson:"created" json:"createdTime"`
+	LastUsedTime *time.Time `bson:"lastUsed,omitempty" json:"lastUsedTime"`
+}
+
+// the roles implied by the scopes for all the sites
+var scopeRoles = map[string]string{
+	base.ScopeRead:        base.RoleViewer,
+	base.ScopeSubmitTasks: base.RoleOperator,
+	base.ScopeAdmin:       base.RoleAdmin,
+}
+
+var roleLevels = map[string]int{
+	base.RoleViewer:   1,
+	base.RoleOperator: 2,
+	base.RoleAdmin:    3,
+}
+
+// HasSiteRole returns true if the key is granted the role or a higher one on the site, an empty site name
+// only matches the roles implied by the scopes
+func (k *ApiKey) HasSiteRole(siteName, role string) bool {
+	required := roleLevels[role]
+	for _, scope := range k.Scopes {
+		if roleLevels[scopeRoles[scope]] >= required {
+			return true
+		}
+	}
+	return siteName != "" && roleLevels[k.SiteRoles[siteName]] >= required
+}
+
+// HasScope returns true if the key is granted the scope, the admin scope implies all the others
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == base.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditLog 修改操作的审计记录，过期后由mongo的TTL索引删除
+type AuditLog struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Caller      string             `bson:"caller" json:"caller"` //name of the api key, or the X-Caller-Id header without authentication
+	Method      string             `bson:"method" json:"method"`
+	Route       string             `bson:"route" json:"route"` //the route pattern, e.g. /api/v1/sites/:siteId
+	Uri         string             `bson:"uri" json:"uri"`
+	Params      map[string]string  `bson:"params,omitempty" json:"params,omitempty"` //path and query parameters
+	Body        string             `bson:"body,omitempty" json:"body,omitempty"`     //the json body, truncated if too long
+	AffectedIds []string           `bson:"affectedIds,omitempty" json:"affectedIds,omitempty"`
+	Status      int                `bson:"status" json:"status"` //http status of the response
+	ClientIp    string             `bson:"clientIp" json:"clientIp"`
+
+	Time *time.Time `bson:"time" json:"time"`
+}
@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"crawlers/pkg/base"
+	"testing"
+)
+
+func TestHasScope(t *testing.T) {
+	cases := []struct {
+		scopes   []string
+		scope    string
+		expected bool
+	}{
+		{[]string{base.ScopeRead}, base.ScopeRead, true},
+		{[]string{base.ScopeRead}, base.ScopeSubmitTasks, false},
+		{[]string{base.ScopeSubmitTasks}, base.ScopeRead, false},
+		{[]string{base.ScopeRead, base.ScopeSubmitTasks}, base.ScopeSubmitTasks, true},
+		{[]string{base.ScopeAdmin}, base.ScopeRead, true},
+		{[]string{base.ScopeAdmin}, base.ScopeSubmitTasks, true},
+		{nil, base.ScopeRead, false},
+	}
+	for _, c := range cases {
+		key := &ApiKey{Scopes: c.scopes}
+		if key.HasScope(c.scope) != c.expected {
+			t.Errorf("the key with %v should be granted %v: %v", c.scopes, c.scope, c.expected)
+		}
+	}
+}
+
+func TestHasSiteRole(t *testing.T) {
+	roles := []string{base.RoleViewer, base.RoleOperator, base.RoleAdmin}
+	// the key is granted the role on kxkm only
+	for granted, role := range roles {
+		key := &ApiKey{SiteRoles: map[string]string{base.Kxkm: role}}
+		for required, requiredRole := range roles {
+			if key.HasSiteRole(base.Kxkm, requiredRole) != (granted >= required) {
+				t.Errorf("the %v of kxkm should be granted %v on it: %v", role, requiredRole, granted >= required)
+			}
+			if key.HasSiteRole(base.SiteNsf, requiredRole) {
+				t.Errorf("the %v of kxkm shouldn't be granted %v on nsf", role, requiredRole)
+			}
+			if key.HasSiteRole("", requiredRole) {
+				t.Errorf("the %v of kxkm shouldn't be granted %v on all the sites", role, requiredRole)
+			}
+		}
+	}
+
+	// the roles implied by the scopes apply to all the sites
+	cases := []struct {
+		scope    string
+		role     string
+		expected bool
+	}{
+		{base.ScopeRead, base.RoleViewer, true},
+		{base.ScopeRead, base.RoleOperator, false},
+		{base.ScopeSubmitTasks, base.RoleOperator, true},
+		{base.ScopeSubmitTasks, base.RoleAdmin, false},
+		{base.ScopeAdmin, base.RoleAdmin, true},
+	}
+	for _, c := range cases {
+		key := &ApiKey{Scopes: []string{c.scope}, SiteRoles: map[string]string{base.Kxkm: base.RoleViewer}}
+		for _, siteName := range []string{base.Kxkm, base.SiteNsf, ""} {
+			if key.HasSiteRole(siteName, c.role) != c.expected {
+				t.Errorf("the key with %v should be granted %v on %q: %v", c.scope, c.role, siteName, c.expected)
+			}
+		}
+	}
+}
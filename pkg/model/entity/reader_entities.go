@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ReadingProgress 调用者在某个novel上的阅读进度，每个调用者每个novel只有一条
+type ReadingProgress struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Caller    string             `bson:"caller" json:"caller"`
+	NovelId   primitive.ObjectID `bson:"novelId" json:"novelId"`
+	ChapterId primitive.ObjectID `bson:"chapterId" json:"chapterId"`
+	Position  int                `bson:"position" json:"position"` //scroll offset or picture index in the chapter
+
+	CreatedTime *time.Time `bson:"created" json:"createdTime"`
+	UpdatedTime *time.Time `bson:"updated" json:"updatedTime"`
+}
+
+// Bookmark 调用者在章节中的书签
+type Bookmark struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Caller    string             `bson:"caller" json:"caller"`
+	NovelId   primitive.ObjectID `bson:"novelId" json:"novelId"`
+	ChapterId primitive.ObjectID `bson:"chapterId" json:"chapterId"`
+	Position  int                `bson:"position" json:"position"`
+	Note      string             `bson:"note,omitempty" json:"note"`
+
+	CreatedTime *time.Time `bson:"created" json:"createdTime"`
+}
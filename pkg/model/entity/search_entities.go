@@ -0,0 +1,15 @@
+package entity
+
+// NovelHit a novel matched by full-text search
+type NovelHit struct {
+	Novel `bson:",inline"`
+	Score float64 `bson:"score"`
+}
+
+// ChapterHit a chapter content matched by full-text search, with its chapter and novel
+type ChapterHit struct {
+	Content `bson:",inline"`
+	Score   float64 `bson:"score"`
+	Chapter Chapter `bson:"chapter"`
+	Novel   Novel   `bson:"novel"`
+}
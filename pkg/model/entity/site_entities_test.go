@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"testing"
+)
+
+// the stages not configured keep the defaults the processor used before the typed settings
+func TestStageSettingsDefaults(t *testing.T) {
+	for _, stage := range []*StageSettings{nil, {}} {
+		if !stage.ShouldSkipIfPresent() {
+			t.Errorf("the finished tasks should be skipped by default: %+v", stage)
+		}
+		if !stage.ShouldSkipSaveIfPresent() {
+			t.Errorf("the existing data shouldn't be saved again by default: %+v", stage)
+		}
+		if !stage.IsEnabled() {
+			t.Errorf("the stage should be enabled by default: %+v", stage)
+		}
+	}
+
+	no := false
+	stage := &StageSettings{SkipIfPresent: &no, SkipSaveIfPresent: &no, Enabled: &no}
+	if stage.ShouldSkipIfPresent() || stage.ShouldSkipSaveIfPresent() || stage.IsEnabled() {
+		t.Errorf("the configured values should override the defaults: %+v", stage)
+	}
+}
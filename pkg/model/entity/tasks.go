@@ -77,6 +77,10 @@ type CatalogPageTask struct {
 	SiteName    string                 `bson:"siteName" json:"siteName"`
 	Retries     uint32                 `bson:"retries" json:"retries"`
 	DownloadNow bool                   `bson:"downloadNow" json:"downloadNow"`
+	//the next page is crawled if this one has items, it's set for an open-ended page range, e.g. page=5-
+	Continuation *base.PageContinuation `bson:"continuation,omitempty" json:"continuation,omitempty"`
+	//the next page link returned by the crawler is followed if it's set, see base.PageModeNext
+	NextPages *base.NextPageChain `bson:"nextPages,omitempty" json:"nextPages,omitempty"`
 	OperationDate
 }
 
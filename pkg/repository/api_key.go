@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type apiKeyRepo interface {
+	FindAll(ctx context.Context) ([]*entity.ApiKey, error)
+	FindById(ctx context.Context, id primitive.ObjectID) (*entity.ApiKey, error)
+	FindByHash(ctx context.Context, hash string) (*entity.ApiKey, error)
+	ExistsByName(ctx context.Context, name string) (bool, error)
+	Insert(ctx context.Context, key *entity.ApiKey) (*primitive.ObjectID, error)
+	Update(ctx context.Context, key *entity.ApiKey) error
+	DeleteById(ctx context.Context, id primitive.ObjectID) (bool, error)
+	UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsed time.Time) error
+}
+
+type apiKeyRepoImpl struct{}
+
+func (a *apiKeyRepoImpl) FindAll(ctx context.Context) ([]*entity.ApiKey, error) {
+	var keys []*entity.ApiKey
+	err := FindAll(ctx, &keys, base.CollectionApiKey, bson.M{}, options.Find().SetSort(bson.M{"created": 1}))
+	return keys, err
+}
+
+func (a *apiKeyRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.ApiKey, error) {
+	return FindById(ctx, id, base.CollectionApiKey, &entity.ApiKey{})
+}
+
+func (a *apiKeyRepoImpl) FindByHash(ctx context.Context, hash string) (*entity.ApiKey, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnHash: hash}, base.CollectionApiKey, &entity.ApiKey{})
+}
+
+func (a *apiKeyRepoImpl) ExistsByName(ctx context.Context, name string) (bool, error) {
+	key, err := FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionApiKey, &entity.ApiKey{},
+		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
+	return key != nil, err
+}
+
+func (a *apiKeyRepoImpl) Insert(ctx context.Context, key *entity.ApiKey) (*primitive.ObjectID, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionApiKey)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionApiKey)
+		return nil, errors.New("collection not found: " + base.CollectionApiKey)
+	}
+	//for creating
+	if !key.Id.IsZero() {
+		return nil, base.ErrDocumentIdExists
+	}
+	curTime := time.Now()
+	key.CreatedTime = &curTime
+
+	if result, err := collection.InsertOne(ctx, key, &options.InsertOneOptions{}); err != nil {
+		return nil, err
+	} else {
+		insertedId := result.InsertedID.(primitive.ObjectID)
+		return &insertedId, nil
+	}
+}
+
+func (a *apiKeyRepoImpl) Update(ctx context.Context, key *entity.ApiKey) error {
+	return UpdateById(ctx, key.Id, base.CollectionApiKey, key)
+}
+
+// DeleteById false is returned if nothing deleted
+func (a *apiKeyRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	deleted, err := DeleteMany(ctx, base.CollectionApiKey, bson.M{base.ColumId: id})
+	return deleted > 0, err
+}
+
+func (a *apiKeyRepoImpl) UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsed time.Time) error {
+	collection := system.GetSystem().GetCollection(base.CollectionApiKey)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionApiKey)
+		return errors.New("collection not found: " + base.CollectionApiKey)
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{base.ColumId: id}, bson.M{"$set": bson.M{"lastUsed": lastUsed}})
+	return err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+// name of the ttl index of the audit logs
+const auditTtlIndex = "time_ttl"
+
+type auditLogRepo interface {
+	Insert(ctx context.Context, log *entity.AuditLog) error
+	FindPage(ctx context.Context, filter *dto.AuditFilter, query *dto.ListQuery) (*dto.PageResult, error)
+}
+
+type auditLogRepoImpl struct{}
+
+// auditListSpec the list query of the audit logs, the name filter matches the uri and the latest comes first
+var auditListSpec = &ListSpec{
+	SortFields:   map[string]string{"time": base.ColumnTime, "status": base.ColumnStatus, "caller": base.ColumnCaller},
+	DefaultSort:  "time",
+	DefaultDesc:  true,
+	NameColumn:   "uri",
+	DateColumn:   base.ColumnTime,
+	StatusColumn: base.ColumnStatus,
+}
+
+func (a *auditLogRepoImpl) Insert(ctx context.Context, log *entity.AuditLog) error {
+	collection := system.GetSystem().GetCollection(base.CollectionAuditLog)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionAuditLog)
+		return errors.New("collection not found: " + base.CollectionAuditLog)
+	}
+	_, err := collection.InsertOne(ctx, log)
+	return err
+}
+
+func (a *auditLogRepoImpl) FindPage(ctx context.Context, filter *dto.AuditFilter,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	mongoFilter := bson.M{}
+	if filter.Caller != "" {
+		mongoFilter[base.ColumnCaller] = filter.Caller
+	}
+	if filter.Method != "" {
+		mongoFilter["method"] = filter.Method
+	}
+	if filter.Route != "" {
+		mongoFilter["route"] = filter.Route
+	}
+	if filter.AffectedId != "" {
+		mongoFilter["affectedIds"] = filter.AffectedId
+	}
+	return FindList[entity.AuditLog](ctx, base.CollectionAuditLog, mongoFilter, query, auditListSpec)
+}
+
+// EnsureAuditIndexes creates the ttl index of the audit logs, the expiration of an existing index is changed
+// if the retention is reconfigured
+func EnsureAuditIndexes(ctx context.Context, retention time.Duration) {
+	expireSeconds := int32(retention.Seconds())
+	col := system.GetSystem().GetCollection(base.CollectionAuditLog)
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{base.ColumnTime: 1},
+		Options: options.Index().SetName(auditTtlIndex).SetExpireAfterSeconds(expireSeconds),
+	})
+	var cmdErr mongo.CommandError
+	if err != nil && errors.As(err, &cmdErr) && cmdErr.Name == "IndexOptionsConflict" {
+		err = col.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: base.CollectionAuditLog},
+			{Key: "index", Value: bson.M{"name": auditTtlIndex, "expireAfterSeconds": expireSeconds}},
+		}).Err()
+	}
+	if err != nil {
+		zap.L().Warn("Failed to ensure the ttl index of audit logs", zap.Error(err))
+	}
+	ensureIndex(ctx, base.CollectionAuditLog, bson.D{{Key: base.ColumnCaller, Value: 1}, {Key: base.ColumnTime, Value: -1}}, nil)
+}
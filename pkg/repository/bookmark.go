@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type bookmarkRepo interface {
+	FindByCaller(ctx context.Context, caller string, novelId *primitive.ObjectID,
+		skip, limit int64) ([]*entity.Bookmark, int64, error)
+	Insert(ctx context.Context, bookmark *entity.Bookmark) (*primitive.ObjectID, error)
+	DeleteByCallerAndId(ctx context.Context, caller string, id primitive.ObjectID) (bool, error)
+}
+
+type bookmarkRepoImpl struct{}
+
+// FindByCaller returns the bookmarks of the caller(in a novel if novelId isn't nil), the latest comes first
+func (b *bookmarkRepoImpl) FindByCaller(ctx context.Context, caller string, novelId *primitive.ObjectID,
+	skip, limit int64) ([]*entity.Bookmark, int64, error) {
+	filter := bson.M{base.ColumnCaller: caller}
+	if novelId != nil {
+		filter[base.ColumnNovelId] = *novelId
+	}
+	var bookmarks []*entity.Bookmark
+	total, err := FindPage(ctx, &bookmarks, base.CollectionBookmark, filter,
+		options.Find().SetSort(bson.M{"created": -1}).SetSkip(skip).SetLimit(limit))
+	return bookmarks, total, err
+}
+
+func (b *bookmarkRepoImpl) Insert(ctx context.Context, bookmark *entity.Bookmark) (*primitive.ObjectID, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionBookmark)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionBookmark)
+		return nil, errors.New("collection not found: " + base.CollectionBookmark)
+	}
+	//for creating
+	if !bookmark.Id.IsZero() {
+		return nil, base.ErrDocumentIdExists
+	}
+	curTime := time.Now()
+	bookmark.CreatedTime = &curTime
+
+	if result, err := collection.InsertOne(ctx, bookmark, &options.InsertOneOptions{}); err != nil {
+		return nil, err
+	} else {
+		insertedId := result.InsertedID.(primitive.ObjectID)
+		return &insertedId, nil
+	}
+}
+
+// DeleteByCallerAndId the bookmarks of others can't be deleted, false is returned if nothing deleted
+func (b *bookmarkRepoImpl) DeleteByCallerAndId(ctx context.Context, caller string, id primitive.ObjectID) (bool, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionBookmark)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionBookmark)
+		return false, errors.New("collection not found: " + base.CollectionBookmark)
+	}
+	result, err := collection.DeleteOne(ctx, bson.M{base.ColumId: id, base.ColumnCaller: caller})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"path/filepath"
+	"regexp"
+)
+
+// cascadeRepo deletes the data belonging to the novels and catalogs being deleted
+type cascadeRepo interface {
+	DeleteNovels(ctx context.Context, novelIds []primitive.ObjectID) (map[string]int64, error)
+	DeleteCatalogTasks(ctx context.Context, catalogId primitive.ObjectID) (map[string]int64, error)
+	DeleteImageHashes(ctx context.Context, dir string) (int64, error)
+}
+
+type cascadeRepoImpl struct{}
+
+// DeleteNovels deletes the novels with their chapters, contents, revisions, assets, chapter tasks,
+// reading progresses and bookmarks, the number of documents deleted in each collection is returned
+func (c *cascadeRepoImpl) DeleteNovels(ctx context.Context, novelIds []primitive.ObjectID) (map[string]int64, error) {
+	deleted := map[string]int64{}
+	if len(novelIds) == 0 {
+		return deleted, nil
+	}
+
+	var chapters []*entity.Chapter
+	byNovel := bson.M{base.ColumnNovelId: bson.M{"$in": novelIds}}
+	if err := FindAll(ctx, &chapters, base.CollectionChapter, byNovel,
+		options.Find().SetProjection(bson.M{base.ColumId: 1})); err != nil {
+		return deleted, err
+	}
+	//the content of a novel without chapters belongs to the novel directly
+	parentIds := append([]primitive.ObjectID{}, novelIds...)
+	for _, chapter := range chapters {
+		parentIds = append(parentIds, chapter.Id)
+	}
+	byParent := bson.M{base.ColumnParentId: bson.M{"$in": parentIds}}
+
+	steps := []struct {
+		collection string
+		filter     any
+	}{
+		{base.CollectionContentRevision, byParent},
+		{base.CollectionContent, byParent},
+		{base.CollectionChapterAsset, byNovel},
+		{base.CollectionChapterTask, byNovel},
+		{base.CollectionReadingProgress, byNovel},
+		{base.CollectionBookmark, byNovel},
+		{base.CollectionChapter, byNovel},
+		{base.CollectionNovel, bson.M{base.ColumId: bson.M{"$in": novelIds}}},
+	}
+	for _, step := range steps {
+		count, err := DeleteMany(ctx, step.collection, step.filter)
+		if err != nil {
+			return deleted, err
+		}
+		deleted[step.collection] += count
+	}
+	return deleted, nil
+}
+
+// DeleteCatalogTasks deletes the catalog page tasks and novel tasks of the catalog
+func (c *cascadeRepoImpl) DeleteCatalogTasks(ctx context.Context, catalogId primitive.ObjectID) (map[string]int64, error) {
+	deleted := map[string]int64{}
+	for _, collection := range []string{base.CollectionCatalogPageTask, base.CollectionNovelTask} {
+		count, err := DeleteMany(ctx, collection, bson.M{base.ColumnCatalogId: catalogId})
+		if err != nil {
+			return deleted, err
+		}
+		deleted[collection] += count
+	}
+	return deleted, nil
+}
+
+// DeleteImageHashes deletes the image hashes of the files in the directory, they can't be reused once
+// the files are removed
+func (c *cascadeRepoImpl) DeleteImageHashes(ctx context.Context, dir string) (int64, error) {
+	return DeleteMany(ctx, base.CollectionImageHash,
+		bson.M{"path": bson.M{"$regex": "^" + regexp.QuoteMeta(filepath.Clean(dir)+string(filepath.Separator))}})
+}
@@ -3,21 +3,33 @@ package repository
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 type catalogRepo interface {
 	FindCatalogsBySiteId(ctx context.Context, siteId primitive.ObjectID) ([]entity.Catalog, error)
+	FindPageBySiteId(ctx context.Context, siteId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
 	FindById(ctx context.Context, id primitive.ObjectID) (*entity.Catalog, error)
 	ExistsById(ctx context.Context, id primitive.ObjectID) (bool, error)
 	ExistsByName(ctx context.Context, name string) (bool, error)
+	Update(ctx context.Context, catalog *entity.Catalog) error
+	DeleteById(ctx context.Context, id primitive.ObjectID) error
 }
 
 type catalogRepoImpl struct{}
 
+var catalogListSpec = &ListSpec{
+	SortFields:  map[string]string{"name": base.ColumnName, "created": "created"},
+	DefaultSort: "name",
+	NameColumn:  base.ColumnName,
+	DateColumn:  "created",
+}
+
 func (c *catalogRepoImpl) FindCatalogsBySiteId(ctx context.Context, siteId primitive.ObjectID) ([]entity.Catalog, error) {
 	findOpts := options.Find()
 	//findOpts.SetProjection(bson.M{base.ColumId: 1, base.ColumnName: 1, base.ColumnDisplayName: 1})
@@ -32,6 +44,12 @@ func (c *catalogRepoImpl) FindCatalogsBySiteId(ctx context.Context, siteId primi
 	return catalogs, err
 }
 
+func (c *catalogRepoImpl) FindPageBySiteId(ctx context.Context, siteId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return FindList[entity.Catalog](ctx, base.CollectionCatalog, bson.M{base.ColumnsiteId: siteId}, query,
+		catalogListSpec)
+}
+
 func (c *catalogRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Catalog, error) {
 	return FindById(ctx, id, base.CollectionCatalog, &entity.Catalog{})
 }
@@ -47,3 +65,13 @@ func (s *catalogRepoImpl) ExistsByName(ctx context.Context, name string) (bool,
 		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
 	return site != nil, err
 }
+
+func (c *catalogRepoImpl) Update(ctx context.Context, catalog *entity.Catalog) error {
+	curTime := time.Now()
+	catalog.UpdatedTime = &curTime
+	return UpdateById(ctx, catalog.Id, base.CollectionCatalog, catalog)
+}
+
+func (c *catalogRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID) error {
+	return DeleteById(ctx, id, base.CollectionCatalog)
+}
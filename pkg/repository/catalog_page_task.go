@@ -14,7 +14,6 @@ import (
 )
 
 type catalogPageTaskRepo interface {
-	FindTasksByCatalogId(ctx context.Context, catalogId primitive.ObjectID) ([]entity.CatalogPageTask, error)
 	FindById(ctx context.Context, id primitive.ObjectID) (*entity.CatalogPageTask, error)
 	FindByUrl(ctx context.Context, url string) (*entity.CatalogPageTask, error)
 	ExistsById(ctx context.Context, id primitive.ObjectID) (bool, error)
@@ -24,20 +23,6 @@ type catalogPageTaskRepo interface {
 
 type catalogPageTaskRepoImpl struct{}
 
-func (c *catalogPageTaskRepoImpl) FindTasksByCatalogId(ctx context.Context, catalogId primitive.ObjectID) ([]entity.CatalogPageTask, error) {
-	findOpts := options.Find()
-	//findOpts.SetProjection(bson.M{base.ColumId: 1, base.ColumnName: 1, base.ColumnDisplayName: 1})
-	findOpts.SetLimit(1000)
-
-	var tasks []entity.CatalogPageTask
-	err := FindAll(ctx, &tasks, base.CollectionCatalogPageTask, bson.M{base.ColumnCatalogId: catalogId}, findOpts)
-
-	if tasks == nil {
-		tasks = []entity.CatalogPageTask{}
-	}
-	return tasks, err
-}
-
 func (c *catalogPageTaskRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.CatalogPageTask, error) {
 	return FindById(ctx, id, base.CollectionCatalogPageTask, &entity.CatalogPageTask{})
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"errors"
 	"github.com/jeven2016/mylibs/system"
@@ -14,7 +15,12 @@ import (
 )
 
 type chapterRepo interface {
+	FindById(ctx context.Context, id primitive.ObjectID) (*entity.Chapter, error)
 	FindByName(ctx context.Context, name string) (*entity.Chapter, error)
+	FindByNovelIdAndName(ctx context.Context, novelId primitive.ObjectID, name string) (*entity.Chapter, error)
+	FindByNovelId(ctx context.Context, novelId primitive.ObjectID, skip, limit int64) ([]*entity.Chapter, int64, error)
+	FindPageByNovelId(ctx context.Context, novelId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
+	FindSibling(ctx context.Context, chapter *entity.Chapter, next bool) (*entity.Chapter, error)
 	ExistsByName(ctx context.Context, name string) (bool, error)
 	Insert(ctx context.Context, novel *entity.Chapter) (*primitive.ObjectID, error)
 	BulkInsert(ctx context.Context, chapters []*entity.Chapter, novelId *primitive.ObjectID) error
@@ -23,6 +29,17 @@ type chapterRepo interface {
 
 type chapterRepoImpl struct{}
 
+var chapterListSpec = &ListSpec{
+	SortFields:  map[string]string{"order": base.ColumnOrder, "name": base.ColumnName, "created": "created"},
+	DefaultSort: "order",
+	NameColumn:  base.ColumnName,
+	DateColumn:  "created",
+}
+
+func (n *chapterRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Chapter, error) {
+	return FindById(ctx, id, base.CollectionChapter, &entity.Chapter{})
+}
+
 func (n *chapterRepoImpl) FindByName(ctx context.Context, name string) (*entity.Chapter, error) {
 	chapter, err := FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionChapter, &entity.Chapter{},
 		&options.FindOneOptions{})
@@ -32,6 +49,47 @@ func (n *chapterRepoImpl) FindByName(ctx context.Context, name string) (*entity.
 	return chapter, err
 }
 
+// FindByNovelIdAndName 章节名称只在同一个novel下唯一
+func (n *chapterRepoImpl) FindByNovelIdAndName(ctx context.Context, novelId primitive.ObjectID,
+	name string) (*entity.Chapter, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnNovelId: novelId, base.ColumnName: name},
+		base.CollectionChapter, &entity.Chapter{})
+}
+
+// FindByNovelId returns a page of chapters of the novel ordered by chapter order
+func (n *chapterRepoImpl) FindByNovelId(ctx context.Context, novelId primitive.ObjectID,
+	skip, limit int64) ([]*entity.Chapter, int64, error) {
+	var chapters []*entity.Chapter
+	total, err := FindPage(ctx, &chapters, base.CollectionChapter, bson.M{base.ColumnNovelId: novelId},
+		options.Find().SetSort(chapterSort(1)).SetSkip(skip).SetLimit(limit))
+	return chapters, total, err
+}
+
+func (n *chapterRepoImpl) FindPageByNovelId(ctx context.Context, novelId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return FindList[entity.Chapter](ctx, base.CollectionChapter, bson.M{base.ColumnNovelId: novelId}, query,
+		chapterListSpec)
+}
+
+// FindSibling returns the next(or previous) chapter of the same novel, nil if it's the last(or first) one
+func (n *chapterRepoImpl) FindSibling(ctx context.Context, chapter *entity.Chapter, next bool) (*entity.Chapter, error) {
+	operator, direction := "$gt", 1
+	if !next {
+		operator, direction = "$lt", -1
+	}
+	//the chapters with the same order are ordered by id
+	filter := bson.M{base.ColumnNovelId: chapter.NovelId, "$or": bson.A{
+		bson.M{base.ColumnOrder: bson.M{operator: chapter.Order}},
+		bson.M{base.ColumnOrder: chapter.Order, base.ColumId: bson.M{operator: chapter.Id}},
+	}}
+	return FindOneByFilter(ctx, filter, base.CollectionChapter, &entity.Chapter{},
+		options.FindOne().SetSort(chapterSort(direction)).SetProjection(bson.M{"attributes": 0}))
+}
+
+func chapterSort(direction int) bson.D {
+	return bson.D{{Key: base.ColumnOrder, Value: direction}, {Key: base.ColumId, Value: direction}}
+}
+
 func (n *chapterRepoImpl) ExistsByName(ctx context.Context, name string) (bool, error) {
 	task, err := FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionChapter, &entity.Chapter{},
 		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
@@ -40,16 +98,20 @@ func (n *chapterRepoImpl) ExistsByName(ctx context.Context, name string) (bool,
 
 func (n *chapterRepoImpl) Insert(ctx context.Context, novel *entity.Chapter) (*primitive.ObjectID, error) {
 	collection := system.GetSystem().GetCollection(base.CollectionChapter)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionChapter)
+		return nil, errors.New("collection not found: " + base.CollectionChapter)
+	}
 	//for creating
 	if !novel.Id.IsZero() {
 		return nil, base.ErrDocumentIdExists
 	}
-	//check if name conflicts
-	exists, err := n.ExistsByName(ctx, novel.Name)
+	//check if name conflicts within the novel
+	existing, err := n.FindByNovelIdAndName(ctx, novel.NovelId, novel.Name)
 	if err != nil {
 		return nil, err
 	}
-	if exists {
+	if existing != nil {
 		return nil, base.ErrDuplicatedDocument
 	}
 	//insert
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type chapterAssetRepo interface {
+	FindByChapterId(ctx context.Context, chapterId primitive.ObjectID) ([]*entity.ChapterAsset, error)
+	FindByChapterIdAndPage(ctx context.Context, chapterId primitive.ObjectID, page int) (*entity.ChapterAsset, error)
+	Upsert(ctx context.Context, asset *entity.ChapterAsset) error
+}
+
+type chapterAssetRepoImpl struct{}
+
+// FindByChapterId returns the assets of a chapter ordered by page
+func (c *chapterAssetRepoImpl) FindByChapterId(ctx context.Context,
+	chapterId primitive.ObjectID) ([]*entity.ChapterAsset, error) {
+	var assets []*entity.ChapterAsset
+	if err := FindAll(ctx, &assets, base.CollectionChapterAsset, bson.M{base.ColumnChapterId: chapterId},
+		options.Find().SetSort(bson.M{base.ColumnPageNo: 1})); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func (c *chapterAssetRepoImpl) FindByChapterIdAndPage(ctx context.Context, chapterId primitive.ObjectID,
+	page int) (*entity.ChapterAsset, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnChapterId: chapterId, base.ColumnPageNo: page},
+		base.CollectionChapterAsset, &entity.ChapterAsset{})
+}
+
+// Upsert 按chapterId和page保存资源记录，已存在则更新
+func (c *chapterAssetRepoImpl) Upsert(ctx context.Context, asset *entity.ChapterAsset) error {
+	collection := system.GetSystem().GetCollection(base.CollectionChapterAsset)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionChapterAsset)
+		return errors.New("collection not found: " + base.CollectionChapterAsset)
+	}
+	curTime := time.Now()
+	asset.UpdatedTime = &curTime
+
+	assetBytes, err := bson.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	var doc bson.D
+	if err = bson.Unmarshal(assetBytes, &doc); err != nil {
+		return err
+	}
+	//the id and created time are kept while updating
+	var fields bson.D
+	for _, elem := range doc {
+		if elem.Key != base.ColumId && elem.Key != "created" {
+			fields = append(fields, elem)
+		}
+	}
+
+	update := bson.M{
+		"$set":         fields,
+		"$setOnInsert": bson.M{"created": curTime},
+	}
+	//the error of a failed download is cleared once it succeeds
+	if asset.Error == "" {
+		update["$unset"] = bson.M{"error": ""}
+	}
+	_, err = collection.UpdateOne(ctx,
+		bson.M{base.ColumnChapterId: asset.ChapterId, base.ColumnPageNo: asset.Page},
+		update, options.Update().SetUpsert(true))
+	return err
+}
@@ -15,6 +15,9 @@ import (
 
 type contentRepo interface {
 	FindByParentIdAndPage(ctx context.Context, parentId *primitive.ObjectID, pageNo int) (*entity.Content, error)
+	FindByParentId(ctx context.Context, parentId primitive.ObjectID) ([]*entity.Content, error)
+	SavePages(ctx context.Context, parentId primitive.ObjectID, parentType string, pages []string,
+		skipSaveIfPresent bool) error
 	Insert(ctx context.Context, content *entity.Content) (*primitive.ObjectID, error)
 	Save(ctx context.Context, novel *entity.Content) (*primitive.ObjectID, error)
 }
@@ -23,10 +26,17 @@ type contentRepoImpl struct{}
 
 func (c *contentRepoImpl) Insert(ctx context.Context, content *entity.Content) (*primitive.ObjectID, error) {
 	collection := system.GetSystem().GetCollection(base.CollectionContent)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionContent)
+		return nil, errors.New("collection not found: " + base.CollectionContent)
+	}
 	//for creating
 	if !content.Id.IsZero() {
 		return nil, base.ErrDocumentIdExists
 	}
+	content.Hash = base.HashText(content.Content)
+	content.Revision = 1
+	content.SearchTokens = contentSearchTokens(content)
 	//check if name conflicts
 	existingContent, err := c.FindByParentIdAndPage(ctx, &content.ParentId, content.Page)
 	if err != nil {
@@ -45,12 +55,76 @@ func (c *contentRepoImpl) Insert(ctx context.Context, content *entity.Content) (
 }
 
 func (c *contentRepoImpl) FindByParentIdAndPage(ctx context.Context, parentId *primitive.ObjectID, pageNo int) (*entity.Content, error) {
-	task, err := FindOneByFilter(ctx, bson.M{base.ColumnParentId: parentId}, //TODO: common.ColumnPageNo: pageNo
+	task, err := FindOneByFilter(ctx, bson.M{base.ColumnParentId: parentId, "$or": pageFilter(pageNo)},
 		base.CollectionContent, &entity.Content{},
 		&options.FindOneOptions{})
 	return task, err
 }
 
+// FindByParentId returns all pages of the content ordered by page
+func (c *contentRepoImpl) FindByParentId(ctx context.Context, parentId primitive.ObjectID) ([]*entity.Content, error) {
+	var contents []*entity.Content
+	if err := FindAll(ctx, &contents, base.CollectionContent, bson.M{base.ColumnParentId: parentId},
+		options.Find().SetSort(bson.M{base.ColumnPageNo: 1})); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// SavePages 按页保存内容，页码从0开始；源站上页数减少时多余的页会被删除
+func (c *contentRepoImpl) SavePages(ctx context.Context, parentId primitive.ObjectID, parentType string,
+	pages []string, skipSaveIfPresent bool) error {
+	for pageNo, text := range pages {
+		existing, err := c.FindByParentIdAndPage(ctx, &parentId, pageNo)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if skipSaveIfPresent {
+				continue
+			}
+			existing.Page = pageNo
+			existing.Content = text
+		} else {
+			createdTime := time.Now()
+			existing = &entity.Content{
+				ParentId:    parentId,
+				ParentType:  parentType,
+				Page:        pageNo,
+				Content:     text,
+				CreatedTime: &createdTime,
+			}
+		}
+		if _, err = c.Save(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	if skipSaveIfPresent || len(pages) == 0 {
+		return nil
+	}
+	collection := system.GetSystem().GetCollection(base.CollectionContent)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionContent)
+		return errors.New("collection not found: " + base.CollectionContent)
+	}
+	result, err := collection.DeleteMany(ctx, bson.M{base.ColumnParentId: parentId,
+		base.ColumnPageNo: bson.M{"$gte": len(pages)}})
+	if err == nil && result.DeletedCount > 0 {
+		zap.L().Info("redundant content pages deleted", zap.String("parentId", parentId.Hex()),
+			zap.Int64("count", result.DeletedCount))
+	}
+	return err
+}
+
+// the contents saved before pagination have no page field, they are treated as the first page
+func pageFilter(pageNo int) bson.A {
+	if pageNo == 0 {
+		return bson.A{bson.M{base.ColumnPageNo: 0}, bson.M{base.ColumnPageNo: bson.M{"$exists": false}}}
+	}
+	return bson.A{bson.M{base.ColumnPageNo: pageNo}}
+}
+
 func (c *contentRepoImpl) Save(ctx context.Context, content *entity.Content) (*primitive.ObjectID, error) {
 	if content.Id.IsZero() {
 		//insert
@@ -63,7 +137,12 @@ func (c *contentRepoImpl) Save(ctx context.Context, content *entity.Content) (*p
 		}
 		//update
 		curTime := time.Now()
+		changed, err := c.archive(ctx, content, curTime)
+		if err != nil || !changed {
+			return &content.Id, err
+		}
 		content.UpdatedTime = &curTime
+		content.SearchTokens = contentSearchTokens(content)
 
 		taskBytes, err := bson.Marshal(content)
 		if err != nil {
@@ -77,3 +156,51 @@ func (c *contentRepoImpl) Save(ctx context.Context, content *entity.Content) (*p
 		return &content.Id, err
 	}
 }
+
+// archive 内容变化时将当前版本保存为历史版本，并递增content的版本号；内容未变化时返回false
+func (c *contentRepoImpl) archive(ctx context.Context, content *entity.Content, curTime time.Time) (bool, error) {
+	content.Hash = base.HashText(content.Content)
+
+	existing, err := FindById(ctx, content.Id, base.CollectionContent, &entity.Content{})
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		content.Revision = 1
+		return true, nil
+	}
+
+	//the contents saved before versioning have no hash and revision
+	existingHash := existing.Hash
+	if existingHash == "" {
+		existingHash = base.HashText(existing.Content)
+	}
+	existingRevision := max(existing.Revision, 1)
+
+	if existingHash == content.Hash {
+		content.Revision = existingRevision
+		//the hash is filled for the old contents
+		return existing.Hash == "", nil
+	}
+
+	revisionCreatedTime := existing.UpdatedTime
+	if revisionCreatedTime == nil {
+		revisionCreatedTime = existing.CreatedTime
+	}
+	if _, err = ContentRevisionRepo.Insert(ctx, &entity.ContentRevision{
+		ContentId:    existing.Id,
+		ParentId:     existing.ParentId,
+		Page:         existing.Page,
+		Revision:     existingRevision,
+		Hash:         existingHash,
+		Content:      existing.Content,
+		CreatedTime:  revisionCreatedTime,
+		ReplacedTime: &curTime,
+	}); err != nil {
+		return false, err
+	}
+	content.Revision = existingRevision + 1
+	zap.L().Info("content changed, a new revision is created", zap.String("contentId", content.Id.Hex()),
+		zap.Int("revision", content.Revision))
+	return true, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type contentRevisionRepo interface {
+	FindByContentId(ctx context.Context, contentId primitive.ObjectID) ([]*entity.ContentRevision, error)
+	FindByContentIdAndRevision(ctx context.Context, contentId primitive.ObjectID,
+		revision int) (*entity.ContentRevision, error)
+	Insert(ctx context.Context, revision *entity.ContentRevision) (*primitive.ObjectID, error)
+}
+
+type contentRevisionRepoImpl struct{}
+
+// FindByContentId returns the history revisions of a content without the text, the latest comes first
+func (c *contentRevisionRepoImpl) FindByContentId(ctx context.Context,
+	contentId primitive.ObjectID) ([]*entity.ContentRevision, error) {
+	var revisions []*entity.ContentRevision
+	if err := FindAll(ctx, &revisions, base.CollectionContentRevision, bson.M{base.ColumnContentId: contentId},
+		options.Find().SetSort(bson.M{base.ColumnRevision: -1}).SetProjection(bson.M{"content": 0})); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (c *contentRevisionRepoImpl) FindByContentIdAndRevision(ctx context.Context, contentId primitive.ObjectID,
+	revision int) (*entity.ContentRevision, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnContentId: contentId, base.ColumnRevision: revision},
+		base.CollectionContentRevision, &entity.ContentRevision{})
+}
+
+func (c *contentRevisionRepoImpl) Insert(ctx context.Context,
+	revision *entity.ContentRevision) (*primitive.ObjectID, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionContentRevision)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionContentRevision)
+		return nil, errors.New("collection not found: " + base.CollectionContentRevision)
+	}
+	//for creating
+	if !revision.Id.IsZero() {
+		return nil, base.ErrDocumentIdExists
+	}
+	if result, err := collection.InsertOne(ctx, revision, &options.InsertOneOptions{}); err != nil {
+		return nil, err
+	} else {
+		insertedId := result.InsertedID.(primitive.ObjectID)
+		return &insertedId, nil
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"github.com/jeven2016/mylibs/system"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
@@ -38,13 +37,56 @@ func EnsureMongoIndexes(ctx context.Context) {
 		ensureIndex(ctx, collection, bson.M{base.ColumnName: -1}, nil)
 	}
 
-	//for content
+	//for content, one document per page
 	ensureIndex(ctx, base.CollectionContent,
-		bson.M{base.ColumnParentId: 0, base.ColumnPageNo: -1}, nil)
+		bson.D{{Key: base.ColumnParentId, Value: 1}, {Key: base.ColumnPageNo, Value: 1}}, options.Index().SetUnique(true))
+
+	//for finding a chapter by its name within a novel, not unique since the chapters crawled before might share names
+	ensureIndex(ctx, base.CollectionChapter, bson.D{{Key: base.ColumnNovelId, Value: 1}, {Key: base.ColumnName, Value: 1}}, nil)
+
+	//for listing novels of a catalog and chapters of a novel
+	ensureIndex(ctx, base.CollectionNovel,
+		bson.D{{Key: base.ColumnCatalogId, Value: 1}, {Key: base.ColumnOrder, Value: 1}}, nil)
+	ensureIndex(ctx, base.CollectionChapter,
+		bson.D{{Key: base.ColumnNovelId, Value: 1}, {Key: base.ColumnOrder, Value: 1}}, nil)
+
+	//for chapter assets
+	ensureIndex(ctx, base.CollectionChapterAsset,
+		bson.D{{Key: base.ColumnChapterId, Value: 1}, {Key: base.ColumnPageNo, Value: 1}}, options.Index().SetUnique(true))
+
+	//for content revisions
+	ensureIndex(ctx, base.CollectionContentRevision,
+		bson.D{{Key: base.ColumnContentId, Value: 1}, {Key: base.ColumnRevision, Value: -1}}, nil)
+
+	//for full-text search, the tokens are split at save time so no language specific stemming is applied
+	for _, collection := range []string{base.CollectionNovel, base.CollectionContent} {
+		ensureIndex(ctx, collection, bson.M{columnSearchTokens: "text"}, options.Index().SetDefaultLanguage("none"))
+	}
+
+	//for reading progress and bookmarks of each caller
+	ensureIndex(ctx, base.CollectionReadingProgress,
+		bson.D{{Key: base.ColumnCaller, Value: 1}, {Key: base.ColumnNovelId, Value: 1}}, options.Index().SetUnique(true))
+	ensureIndex(ctx, base.CollectionReadingProgress,
+		bson.D{{Key: base.ColumnCaller, Value: 1}, {Key: "updated", Value: -1}}, nil)
+	ensureIndex(ctx, base.CollectionBookmark,
+		bson.D{{Key: base.ColumnCaller, Value: 1}, {Key: base.ColumnNovelId, Value: 1}}, nil)
+
+	//for querying the tasks by site and status
+	for _, collection := range urlIndexCollection {
+		ensureIndex(ctx, collection,
+			bson.D{{Key: base.ColumnSiteName, Value: 1}, {Key: base.ColumnStatus, Value: 1}}, nil)
+	}
+
+	//for api keys, the key is looked up by its hash
+	ensureIndex(ctx, base.CollectionApiKey, bson.M{base.ColumnHash: 1}, options.Index().SetUnique(true))
+	ensureIndex(ctx, base.CollectionApiKey, bson.M{base.ColumnName: 1}, options.Index().SetUnique(true))
+
+	//for image deduplication
+	ensureIndex(ctx, base.CollectionImageHash, bson.M{base.ColumnHash: 1}, options.Index().SetUnique(true))
 	zap.L().Info("completed checking the indexes of collections")
 }
 
-func ensureIndex(ctx context.Context, collection string, keys primitive.M, options *options.IndexOptions) {
+func ensureIndex(ctx context.Context, collection string, keys any, options *options.IndexOptions) {
 	col := system.GetSystem().GetCollection(collection)
 	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    keys,
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type imageHashRepo interface {
+	FindByHash(ctx context.Context, hash string) (*entity.ImageHash, error)
+	Insert(ctx context.Context, imageHash *entity.ImageHash) (*primitive.ObjectID, error)
+	IncreaseRefCount(ctx context.Context, id primitive.ObjectID) error
+	UpdatePath(ctx context.Context, id primitive.ObjectID, path string) error
+}
+
+type imageHashRepoImpl struct{}
+
+func (i *imageHashRepoImpl) FindByHash(ctx context.Context, hash string) (*entity.ImageHash, error) {
+	return FindByColumn(ctx, base.ColumnHash, hash, base.CollectionImageHash, &entity.ImageHash{})
+}
+
+func (i *imageHashRepoImpl) Insert(ctx context.Context, imageHash *entity.ImageHash) (*primitive.ObjectID, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionImageHash)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionImageHash)
+		return nil, errors.New("collection not found: " + base.CollectionImageHash)
+	}
+	//for creating
+	if !imageHash.Id.IsZero() {
+		return nil, base.ErrDocumentIdExists
+	}
+	curTime := time.Now()
+	imageHash.CreatedTime = &curTime
+	if imageHash.RefCount == 0 {
+		imageHash.RefCount = 1
+	}
+
+	if result, err := collection.InsertOne(ctx, imageHash, &options.InsertOneOptions{}); err != nil {
+		return nil, err
+	} else {
+		insertedId := result.InsertedID.(primitive.ObjectID)
+		return &insertedId, nil
+	}
+}
+
+func (i *imageHashRepoImpl) IncreaseRefCount(ctx context.Context, id primitive.ObjectID) error {
+	collection := system.GetSystem().GetCollection(base.CollectionImageHash)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionImageHash)
+		return errors.New("collection not found: " + base.CollectionImageHash)
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{base.ColumId: id},
+		bson.M{"$inc": bson.M{"refCount": 1}, "$set": bson.M{"updated": time.Now()}})
+	return err
+}
+
+func (i *imageHashRepoImpl) UpdatePath(ctx context.Context, id primitive.ObjectID, path string) error {
+	collection := system.GetSystem().GetCollection(base.CollectionImageHash)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionImageHash)
+		return errors.New("collection not found: " + base.CollectionImageHash)
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{base.ColumId: id},
+		bson.M{"$set": bson.M{"path": path, "updated": time.Now()}})
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"errors"
 	"github.com/jeven2016/mylibs/system"
@@ -16,6 +17,8 @@ import (
 type novelRepo interface {
 	FindById(ctx context.Context, id primitive.ObjectID) (*entity.Novel, error)
 	FindIdByName(ctx context.Context, name string) (*primitive.ObjectID, error)
+	FindByCatalogId(ctx context.Context, catalogId primitive.ObjectID, skip, limit int64) ([]*entity.Novel, int64, error)
+	FindPageByCatalogId(ctx context.Context, catalogId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
 	ExistsByName(ctx context.Context, name string) (bool, error)
 	Insert(ctx context.Context, novel *entity.Novel) (*primitive.ObjectID, error)
 	Save(ctx context.Context, task *entity.Novel) (*primitive.ObjectID, error)
@@ -25,6 +28,13 @@ type novelRepo interface {
 
 type novelRepoImpl struct{}
 
+var novelListSpec = &ListSpec{
+	SortFields:  map[string]string{"order": base.ColumnOrder, "name": base.ColumnName, "created": "created"},
+	DefaultSort: "order",
+	NameColumn:  base.ColumnName,
+	DateColumn:  "created",
+}
+
 func (n *novelRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Novel, error) {
 	novel, err := FindOneByFilter(ctx, bson.M{base.ColumId: id}, base.CollectionNovel, &entity.Novel{},
 		&options.FindOneOptions{})
@@ -43,6 +53,22 @@ func (n *novelRepoImpl) FindIdByName(ctx context.Context, name string) (*primiti
 	return &novel.Id, err
 }
 
+func (n *novelRepoImpl) FindPageByCatalogId(ctx context.Context, catalogId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return FindList[entity.Novel](ctx, base.CollectionNovel, bson.M{base.ColumnCatalogId: catalogId}, query,
+		novelListSpec)
+}
+
+// FindByCatalogId returns a page of novels in the catalog and the total number of them
+func (n *novelRepoImpl) FindByCatalogId(ctx context.Context, catalogId primitive.ObjectID,
+	skip, limit int64) ([]*entity.Novel, int64, error) {
+	var novels []*entity.Novel
+	total, err := FindPage(ctx, &novels, base.CollectionNovel, bson.M{base.ColumnCatalogId: catalogId},
+		options.Find().SetSort(bson.D{{Key: base.ColumnOrder, Value: 1}, {Key: base.ColumId, Value: 1}}).
+			SetProjection(bson.M{columnSearchTokens: 0}).SetSkip(skip).SetLimit(limit))
+	return novels, total, err
+}
+
 func (n *novelRepoImpl) ExistsByName(ctx context.Context, name string) (bool, error) {
 	novel, err := FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionNovel, &entity.Novel{},
 		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
@@ -63,6 +89,7 @@ func (n *novelRepoImpl) Insert(ctx context.Context, novel *entity.Novel) (*primi
 	if exists {
 		return nil, base.ErrDuplicatedDocument
 	}
+	novel.SearchTokens = novelSearchTokens(novel)
 	//insert
 	if result, err := collection.InsertOne(ctx, novel, &options.InsertOneOptions{}); err != nil {
 		return nil, err
@@ -85,6 +112,7 @@ func (c *novelRepoImpl) Save(ctx context.Context, novel *entity.Novel) (*primiti
 		//update
 		curTime := time.Now()
 		novel.UpdatedTime = &curTime
+		novel.SearchTokens = novelSearchTokens(novel)
 
 		taskBytes, err := bson.Marshal(novel)
 		if err != nil {
@@ -3,36 +3,97 @@ package repository
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"errors"
 	"github.com/jeven2016/mylibs/system"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 	"time"
 )
 
 type novelTaskRepo interface {
-	FindByCatalogId(ctx context.Context, catalogId primitive.ObjectID) ([]entity.NovelTask, error)
+	FindDeferred(ctx context.Context, catalogId *primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
+	FindDeferredIds(ctx context.Context, catalogId *primitive.ObjectID, ids []primitive.ObjectID) ([]primitive.ObjectID, error)
+	MarkStarted(ctx context.Context, id primitive.ObjectID) (*entity.NovelTask, error)
+	MarkDeferred(ctx context.Context, id primitive.ObjectID) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*entity.NovelTask, error)
 	FindByUrl(ctx context.Context, url string) (*entity.NovelTask, error)
 	Save(ctx context.Context, task *entity.NovelTask) (*primitive.ObjectID, error)
 }
 
 type novelTaskRepoImpl struct{}
 
-func (c *novelTaskRepoImpl) FindByCatalogId(ctx context.Context, catalogId primitive.ObjectID) ([]entity.NovelTask, error) {
-	findOpts := options.Find()
-	//findOpts.SetProjection(bson.M{base.ColumId: 1, base.ColumnName: 1, base.ColumnDisplayName: 1})
-	findOpts.SetLimit(1000)
+// deferredListSpec the list query of the deferred novel tasks
+var deferredListSpec = &ListSpec{
+	SortFields:     map[string]string{"name": base.ColumnName, "created": columnCreatedDate},
+	DefaultSort:    sortById,
+	NameColumn:     base.ColumnName,
+	DateColumn:     columnCreatedDate,
+	SiteNameColumn: base.ColumnSiteName,
+}
 
-	var tasks []entity.NovelTask
-	err := FindAll(ctx, &tasks, base.CollectionNovelTask, bson.M{base.ColumnCatalogId: catalogId}, findOpts)
+// FindDeferred returns a page of the novel tasks waiting to be started, catalogId is optional
+func (c *novelTaskRepoImpl) FindDeferred(ctx context.Context, catalogId *primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return FindList[entity.NovelTask](ctx, base.CollectionNovelTask, deferredFilter(catalogId, nil), query,
+		deferredListSpec)
+}
 
-	if tasks == nil {
-		tasks = []entity.NovelTask{}
+// FindDeferredIds returns the ids of the deferred tasks in the catalog or in the given ids
+func (c *novelTaskRepoImpl) FindDeferredIds(ctx context.Context, catalogId *primitive.ObjectID,
+	ids []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var docs []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := FindAll(ctx, &docs, base.CollectionNovelTask, deferredFilter(catalogId, ids),
+		options.Find().SetProjection(bson.M{base.ColumId: 1}).SetSort(bson.M{base.ColumId: 1})); err != nil {
+		return nil, err
+	}
+	deferredIds := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		deferredIds = append(deferredIds, doc.Id)
+	}
+	return deferredIds, nil
+}
+
+// MarkStarted sets DownloadNow of a deferred task and returns the updated task, nil is returned if the task
+// doesn't exist or isn't deferred, so a task is never started twice
+func (c *novelTaskRepoImpl) MarkStarted(ctx context.Context, id primitive.ObjectID) (*entity.NovelTask, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionNovelTask)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionNovelTask)
+		return nil, errors.New("collection not found: " + base.CollectionNovelTask)
 	}
-	return tasks, err
+	filter := deferredFilter(nil, nil)
+	filter[base.ColumId] = id
+	result := collection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{base.ColumnDownloadNow: true, columnLastUpdated: time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err := result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var task entity.NovelTask
+	if err := result.Decode(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// MarkDeferred resets DownloadNow of a task marked by MarkStarted, e.g. it fails to be published
+func (c *novelTaskRepoImpl) MarkDeferred(ctx context.Context, id primitive.ObjectID) error {
+	return UpdateById(ctx, id, base.CollectionNovelTask,
+		bson.M{base.ColumnDownloadNow: false, columnLastUpdated: time.Now()})
+}
+
+func (c *novelTaskRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.NovelTask, error) {
+	return FindById(ctx, id, base.CollectionNovelTask, &entity.NovelTask{})
 }
 
 func (c *novelTaskRepoImpl) FindByUrl(ctx context.Context, url string) (*entity.NovelTask, error) {
@@ -71,3 +132,15 @@ func (c *novelTaskRepoImpl) Save(ctx context.Context, task *entity.NovelTask) (*
 		return &task.Id, err
 	}
 }
+
+// the deferred tasks are saved without being crawled, see HandleNovelTask
+func deferredFilter(catalogId *primitive.ObjectID, ids []primitive.ObjectID) bson.M {
+	filter := bson.M{base.ColumnStatus: base.TaskStatusNotStared, base.ColumnDownloadNow: false}
+	if catalogId != nil {
+		filter[base.ColumnCatalogId] = *catalogId
+	}
+	if ids != nil {
+		filter[base.ColumId] = bson.M{"$in": ids}
+	}
+	return filter
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"github.com/jeven2016/mylibs/db"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+)
+
+func TestMarkStarted(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("mark started", func(mt *mtest.T) {
+		previous := system.GetSystem()
+		system.SetSystem(&system.System{MongoClient: &db.Mongo{Client: mt.Client, Db: mt.DB}})
+		defer system.SetSystem(previous)
+
+		repo := &novelTaskRepoImpl{}
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: base.ColumId, Value: id}, {Key: "name", Value: "novel"}, {Key: base.ColumnDownloadNow, Value: true},
+		}}))
+		task, err := repo.MarkStarted(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task == nil || task.Id != id || !task.DownloadNow {
+			t.Errorf("the updated task should be returned, but it's %+v", task)
+		}
+
+		// only a deferred task is updated, so it's never started twice
+		command := mt.GetStartedEvent().Command
+		query := command.Lookup("query").Document()
+		if query.Lookup(base.ColumId).ObjectID() != id ||
+			query.Lookup(base.ColumnDownloadNow).Boolean() ||
+			query.Lookup(base.ColumnStatus).AsInt64() != int64(base.TaskStatusNotStared) {
+			t.Errorf("unexpected query: %v", query)
+		}
+		if !command.Lookup("update", "$set", base.ColumnDownloadNow).Boolean() || !command.Lookup("new").Boolean() {
+			t.Errorf("unexpected update: %v", command)
+		}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+		if task, err = repo.MarkStarted(context.Background(), id); err != nil || task != nil {
+			t.Errorf("nil should be returned for a task started already, task: %+v, error: %v", task, err)
+		}
+
+		// the task failing to be published is deferred again, the collections of mylibs are initialized once
+		// only so it's checked with the same system
+		mt.ClearEvents()
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if err = repo.MarkDeferred(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if update.Lookup("q", base.ColumId).ObjectID() != id ||
+			update.Lookup("u", "$set", base.ColumnDownloadNow).Boolean() {
+			t.Errorf("unexpected update: %v", update)
+		}
+	})
+}
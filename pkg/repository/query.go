@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+)
+
+// sort field of the id, the cursor pagination only works with it
+const sortById = "id"
+
+// columns of the tasks
+const (
+	columnCreatedDate = "createdDate"
+	columnLastUpdated = "lastUpdated"
+)
+
+// ListSpec describes how the common list query applies to a collection, the filters without a column
+// are not supported
+type ListSpec struct {
+	SortFields     map[string]string //sort field -> column, "id" is always supported
+	DefaultSort    string
+	DefaultDesc    bool
+	NameColumn     string
+	DateColumn     string
+	StatusColumn   string
+	SiteNameColumn string
+}
+
+// FindList finds a page of documents matching the filter and the query, base.ParamError is returned for
+// an unsupported filter or sort field
+func FindList[T any](ctx context.Context, collection string, filter bson.M, query *dto.ListQuery,
+	spec *ListSpec) (*dto.PageResult, error) {
+	query.Normalize()
+	list, err := buildListQuery(filter, query, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	//the total number doesn't depend on the cursor
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		return nil, errors.New("collection not found: " + collection)
+	}
+	total, err := col.CountDocuments(ctx, list.countFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*T{}
+	if total > 0 {
+		if err = FindAll(ctx, &items, collection, list.filter, list.findOpts); err != nil {
+			return nil, err
+		}
+	}
+	result := &dto.PageResult{Total: total, Page: query.Page, Size: query.Size, Items: items}
+	if list.byId && int64(len(items)) == query.Limit() {
+		result.NextCursor = lastId(items)
+	}
+	return result, nil
+}
+
+// listQuery the mongo query built from a list query
+type listQuery struct {
+	countFilter bson.M //the filter without the cursor
+	filter      bson.M
+	findOpts    *options.FindOptions
+	byId        bool //whether the documents are sorted by id only, the next cursor is returned then
+}
+
+// buildListQuery the cursor pagination only works with sorting by id, so a cursor along with another
+// sort field is rejected. The skip is used without a cursor.
+func buildListQuery(filter bson.M, query *dto.ListQuery, spec *ListSpec) (*listQuery, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if err := applyFilters(filter, query, spec); err != nil {
+		return nil, err
+	}
+
+	sortField := query.Sort
+	if query.Cursor != "" {
+		if sortField != "" && sortField != sortById {
+			return nil, &base.ParamError{Name: "sort"}
+		}
+		sortField = sortById
+	}
+	if sortField == "" {
+		sortField = spec.DefaultSort
+	}
+	column := base.ColumId
+	if sortField != sortById && sortField != "" {
+		var ok bool
+		if column, ok = spec.SortFields[sortField]; !ok {
+			return nil, &base.ParamError{Name: "sort"}
+		}
+	}
+	desc := query.Descending(spec.DefaultDesc)
+	direction := 1
+	if desc {
+		direction = -1
+	}
+
+	list := &listQuery{countFilter: filter, filter: filter, findOpts: options.Find().SetLimit(query.Limit()),
+		byId: column == base.ColumId}
+	if query.Cursor != "" {
+		cursorId, err := primitive.ObjectIDFromHex(query.Cursor)
+		if err != nil {
+			return nil, &base.ParamError{Name: "cursor"}
+		}
+		operator := "$gt"
+		if desc {
+			operator = "$lt"
+		}
+		list.filter = bson.M{"$and": bson.A{filter, bson.M{base.ColumId: bson.M{operator: cursorId}}}}
+	} else {
+		list.findOpts.SetSkip(query.Skip())
+	}
+	//the id keeps the order stable for the documents with the same value
+	sort := bson.D{{Key: column, Value: direction}}
+	if column != base.ColumId {
+		sort = append(sort, bson.E{Key: base.ColumId, Value: direction})
+	}
+	list.findOpts.SetSort(sort)
+	return list, nil
+}
+
+func applyFilters(filter bson.M, query *dto.ListQuery, spec *ListSpec) error {
+	if query.Name != "" {
+		if spec.NameColumn == "" {
+			return &base.ParamError{Name: "name"}
+		}
+		filter[spec.NameColumn] = bson.M{"$regex": regexp.QuoteMeta(query.Name), "$options": "i"}
+	}
+	if query.Status != 0 {
+		if spec.StatusColumn == "" {
+			return &base.ParamError{Name: "status"}
+		}
+		filter[spec.StatusColumn] = query.Status
+	}
+	if query.SiteName != "" {
+		if spec.SiteNameColumn == "" {
+			return &base.ParamError{Name: "siteName"}
+		}
+		filter[spec.SiteNameColumn] = query.SiteName
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		if spec.DateColumn == "" {
+			return &base.ParamError{Name: "from"}
+		}
+		dateRange := bson.M{}
+		if !query.From.IsZero() {
+			dateRange["$gte"] = query.From
+		}
+		if !query.To.IsZero() {
+			dateRange["$lte"] = query.To
+		}
+		filter[spec.DateColumn] = dateRange
+	}
+	return nil
+}
+
+// the id of the last item is read from its bson document
+func lastId[T any](items []*T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	raw, err := bson.Marshal(items[len(items)-1])
+	if err != nil {
+		return ""
+	}
+	if id, ok := bson.Raw(raw).Lookup(base.ColumId).ObjectIDOK(); ok {
+		return id.Hex()
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testListSpec = &ListSpec{
+	SortFields:  map[string]string{"name": base.ColumnName},
+	DefaultSort: "name",
+	NameColumn:  base.ColumnName,
+	DateColumn:  "created",
+}
+
+func newListQuery(query dto.ListQuery) *dto.ListQuery {
+	query.Normalize()
+	return &query
+}
+
+func TestBuildListQuerySkip(t *testing.T) {
+	list, err := buildListQuery(nil, newListQuery(dto.ListQuery{
+		PageRequest: dto.PageRequest{Page: 3, Size: 10},
+		Order:       "desc",
+	}), testListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *list.findOpts.Skip != 20 || *list.findOpts.Limit != 10 || list.byId {
+		t.Errorf("the page should be skipped, skip: %v, limit: %v", *list.findOpts.Skip, *list.findOpts.Limit)
+	}
+	expectedSort := bson.D{{Key: base.ColumnName, Value: -1}, {Key: base.ColumId, Value: -1}}
+	if !reflect.DeepEqual(list.findOpts.Sort, expectedSort) {
+		t.Errorf("the default sort field should be used along with the id, but it's %v", list.findOpts.Sort)
+	}
+}
+
+func TestBuildListQueryCursor(t *testing.T) {
+	cursor := primitive.NewObjectID()
+	list, err := buildListQuery(bson.M{"siteName": "onej"}, newListQuery(dto.ListQuery{
+		PageRequest: dto.PageRequest{Page: 3, Size: 10},
+		Cursor:      cursor.Hex(),
+	}), testListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.findOpts.Skip != nil || !list.byId {
+		t.Error("the cursor should be used instead of the skip")
+	}
+	if !reflect.DeepEqual(list.findOpts.Sort, bson.D{{Key: base.ColumId, Value: 1}}) {
+		t.Errorf("the documents should be sorted by id, but it's %v", list.findOpts.Sort)
+	}
+	expectedFilter := bson.M{"$and": bson.A{bson.M{"siteName": "onej"},
+		bson.M{base.ColumId: bson.M{"$gt": cursor}}}}
+	if !reflect.DeepEqual(list.filter, expectedFilter) || !reflect.DeepEqual(list.countFilter, bson.M{"siteName": "onej"}) {
+		t.Errorf("unexpected filters: %v, %v", list.filter, list.countFilter)
+	}
+
+	list, err = buildListQuery(nil, newListQuery(dto.ListQuery{Cursor: cursor.Hex(), Sort: "id", Order: "desc"}),
+		testListSpec)
+	if err != nil || !reflect.DeepEqual(list.filter["$and"].(bson.A)[1], bson.M{base.ColumId: bson.M{"$lt": cursor}}) {
+		t.Errorf("the cursor should go backwards in descending order, filter: %v, error: %v", list, err)
+	}
+}
+
+func TestBuildListQueryInvalid(t *testing.T) {
+	cases := map[string]dto.ListQuery{
+		"sort":   {Sort: "created"},
+		"cursor": {Cursor: "abc"},
+		"status": {Status: 1},
+	}
+	for name, query := range cases {
+		_, err := buildListQuery(nil, newListQuery(query), testListSpec)
+		var paramErr *base.ParamError
+		if !errors.As(err, &paramErr) || paramErr.Name != name {
+			t.Errorf("%v should be rejected, but the error is %v", name, err)
+		}
+	}
+
+	// the cursor only works with sorting by id
+	_, err := buildListQuery(nil, newListQuery(dto.ListQuery{Cursor: primitive.NewObjectID().Hex(), Sort: "name"}),
+		testListSpec)
+	var paramErr *base.ParamError
+	if !errors.As(err, &paramErr) || paramErr.Name != "sort" {
+		t.Errorf("the cursor along with a sort field should be rejected, but the error is %v", err)
+	}
+}
+
+func TestBuildListQueryFilters(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	list, err := buildListQuery(nil, newListQuery(dto.ListQuery{Name: "a.b(c", From: from}), testListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.M{
+		base.ColumnName: bson.M{"$regex": `a\.b\(c`, "$options": "i"},
+		"created":       bson.M{"$gte": from},
+	}
+	if !reflect.DeepEqual(list.filter, expected) {
+		t.Errorf("unexpected filter: %v", list.filter)
+	}
+
+	to := from.Add(24 * time.Hour)
+	list, err = buildListQuery(nil, newListQuery(dto.ListQuery{From: from, To: to}), testListSpec)
+	if err != nil || !reflect.DeepEqual(list.filter["created"], bson.M{"$gte": from, "$lte": to}) {
+		t.Errorf("unexpected date range: %v, error: %v", list, err)
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type readingProgressRepo interface {
+	FindByCallerAndNovelId(ctx context.Context, caller string, novelId primitive.ObjectID) (*entity.ReadingProgress, error)
+	FindByCaller(ctx context.Context, caller string, skip, limit int64) ([]*entity.ReadingProgress, int64, error)
+	Save(ctx context.Context, progress *entity.ReadingProgress) (*entity.ReadingProgress, error)
+}
+
+type readingProgressRepoImpl struct{}
+
+func (r *readingProgressRepoImpl) FindByCallerAndNovelId(ctx context.Context, caller string,
+	novelId primitive.ObjectID) (*entity.ReadingProgress, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnCaller: caller, base.ColumnNovelId: novelId},
+		base.CollectionReadingProgress, &entity.ReadingProgress{})
+}
+
+// FindByCaller returns the reading progress of the caller, the latest read comes first
+func (r *readingProgressRepoImpl) FindByCaller(ctx context.Context, caller string,
+	skip, limit int64) ([]*entity.ReadingProgress, int64, error) {
+	var list []*entity.ReadingProgress
+	total, err := FindPage(ctx, &list, base.CollectionReadingProgress, bson.M{base.ColumnCaller: caller},
+		options.Find().SetSort(bson.M{"updated": -1}).SetSkip(skip).SetLimit(limit))
+	return list, total, err
+}
+
+// Save 按caller和novelId保存阅读进度，已存在则更新
+func (r *readingProgressRepoImpl) Save(ctx context.Context,
+	progress *entity.ReadingProgress) (*entity.ReadingProgress, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionReadingProgress)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionReadingProgress)
+		return nil, errors.New("collection not found: " + base.CollectionReadingProgress)
+	}
+	curTime := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			base.ColumnChapterId: progress.ChapterId,
+			"position":           progress.Position,
+			"updated":            curTime,
+		},
+		"$setOnInsert": bson.M{"created": curTime},
+	}
+	result := collection.FindOneAndUpdate(ctx,
+		bson.M{base.ColumnCaller: progress.Caller, base.ColumnNovelId: progress.NovelId}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	var saved entity.ReadingProgress
+	if err := result.Decode(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
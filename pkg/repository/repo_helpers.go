@@ -58,6 +58,19 @@ func FindAll(ctx context.Context, list any, collection string, filter any, opts
 	return nil
 }
 
+// FindPage finds a page of documents and returns the total number of the documents matching the filter
+func FindPage(ctx context.Context, list any, collection string, filter any, opts *options.FindOptions) (int64, error) {
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		return 0, errors.New("collection not found: " + collection)
+	}
+	total, err := col.CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return total, err
+	}
+	return total, FindAll(ctx, list, collection, filter, opts)
+}
+
 func FindOneByFilter[T any](ctx context.Context, mongoFilter interface{}, collection string,
 	decodedObj *T, opts ...*options.FindOneOptions) (*T, error) {
 	col := system.GetSystem().GetCollection(collection)
@@ -88,3 +101,40 @@ func ExistsByMongoFilter(ctx context.Context, mongoFilter interface{},
 	}
 	return false, err
 }
+
+// UpdateById sets the fields of the document with the given object, the _id isn't changed
+func UpdateById(ctx context.Context, id primitive.ObjectID, collection string, obj any) error {
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		return errors.New("collection not found: " + collection)
+	}
+	objBytes, err := bson.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var doc bson.D
+	if err = bson.Unmarshal(objBytes, &doc); err != nil {
+		return err
+	}
+	fields := make(bson.D, 0, len(doc))
+	for _, elem := range doc {
+		if elem.Key != base.ColumId {
+			fields = append(fields, elem)
+		}
+	}
+	_, err = col.UpdateOne(ctx, bson.M{base.ColumId: id}, bson.M{"$set": fields})
+	return err
+}
+
+// DeleteMany deletes the documents matching the filter and returns the number of them
+func DeleteMany(ctx context.Context, collection string, filter any) (int64, error) {
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		return 0, errors.New("collection not found: " + collection)
+	}
+	result, err := col.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
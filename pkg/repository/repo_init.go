@@ -8,6 +8,16 @@ var NovelRepo novelRepo
 var ChapterRepo chapterRepo
 var ChapterTaskRepo chapterTaskRepo
 var ContentRepo contentRepo
+var ImageHashRepo imageHashRepo
+var ChapterAssetRepo chapterAssetRepo
+var ContentRevisionRepo contentRevisionRepo
+var SearchRepo searchRepo
+var ReadingProgressRepo readingProgressRepo
+var BookmarkRepo bookmarkRepo
+var CascadeRepo cascadeRepo
+var TaskRepo taskRepo
+var ApiKeyRepo apiKeyRepo
+var AuditLogRepo auditLogRepo
 
 // InitRepositories initializes all the repository interfaces with their respective implementations.
 // This function should be called once during the application startup to ensure all repositories are ready for use.
@@ -35,4 +45,34 @@ func InitRepositories() {
 
 	// Initialize ContentRepo with contentRepoImpl struct
 	ContentRepo = &contentRepoImpl{}
+
+	// Initialize ImageHashRepo with imageHashRepoImpl struct
+	ImageHashRepo = &imageHashRepoImpl{}
+
+	// Initialize ChapterAssetRepo with chapterAssetRepoImpl struct
+	ChapterAssetRepo = &chapterAssetRepoImpl{}
+
+	// Initialize ContentRevisionRepo with contentRevisionRepoImpl struct
+	ContentRevisionRepo = &contentRevisionRepoImpl{}
+
+	// Initialize SearchRepo with searchRepoImpl struct
+	SearchRepo = &searchRepoImpl{}
+
+	// Initialize ReadingProgressRepo with readingProgressRepoImpl struct
+	ReadingProgressRepo = &readingProgressRepoImpl{}
+
+	// Initialize BookmarkRepo with bookmarkRepoImpl struct
+	BookmarkRepo = &bookmarkRepoImpl{}
+
+	// Initialize CascadeRepo with cascadeRepoImpl struct
+	CascadeRepo = &cascadeRepoImpl{}
+
+	// Initialize TaskRepo with taskRepoImpl struct
+	TaskRepo = &taskRepoImpl{}
+
+	// Initialize ApiKeyRepo with apiKeyRepoImpl struct
+	ApiKeyRepo = &apiKeyRepoImpl{}
+
+	// Initialize AuditLogRepo with auditLogRepoImpl struct
+	AuditLogRepo = &auditLogRepoImpl{}
 }
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"fmt"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const columnSearchTokens = "searchTokens"
+
+type searchRepo interface {
+	SearchNovels(ctx context.Context, query string, catalogIds []primitive.ObjectID,
+		skip, limit int64) ([]*entity.NovelHit, int64, error)
+	SearchChapters(ctx context.Context, query string, catalogIds []primitive.ObjectID,
+		skip, limit int64) ([]*entity.ChapterHit, int64, error)
+	EnsureSearchTokens(ctx context.Context)
+}
+
+type searchRepoImpl struct{}
+
+// SearchNovels 按相关度排序返回匹配的novel，catalogIds为nil时不限制分类
+func (s *searchRepoImpl) SearchNovels(ctx context.Context, query string, catalogIds []primitive.ObjectID,
+	skip, limit int64) ([]*entity.NovelHit, int64, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionNovel)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionNovel)
+		return nil, 0, errors.New("collection not found: " + base.CollectionNovel)
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": base.SearchTokens(query)}}
+	if catalogIds != nil {
+		filter[base.ColumnCatalogId] = bson.M{"$in": catalogIds}
+	}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	var hits []*entity.NovelHit
+	err = FindAll(ctx, &hits, base.CollectionNovel, filter, options.Find().
+		SetProjection(bson.M{"score": score, columnSearchTokens: 0}).
+		SetSort(bson.M{"score": score}).
+		SetSkip(skip).SetLimit(limit))
+	return hits, total, err
+}
+
+// SearchChapters 按相关度排序返回匹配的章节内容，并关联章节和novel用于按分类过滤
+func (s *searchRepoImpl) SearchChapters(ctx context.Context, query string, catalogIds []primitive.ObjectID,
+	skip, limit int64) ([]*entity.ChapterHit, int64, error) {
+	collection := system.GetSystem().GetCollection(base.CollectionContent)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionContent)
+		return nil, 0, errors.New("collection not found: " + base.CollectionContent)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"$text":      bson.M{"$search": base.SearchTokens(query)},
+			"parentType": base.ParentTypeChapter,
+		}}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{{Key: "$lookup", Value: bson.M{"from": base.CollectionChapter, "localField": base.ColumnParentId,
+			"foreignField": base.ColumId, "as": "chapter"}}},
+		{{Key: "$unwind", Value: "$chapter"}},
+		{{Key: "$lookup", Value: bson.M{"from": base.CollectionNovel, "localField": "chapter." + base.ColumnNovelId,
+			"foreignField": base.ColumId, "as": "novel"}}},
+		{{Key: "$unwind", Value: "$novel"}},
+	}
+	if catalogIds != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match",
+			Value: bson.M{"novel." + base.ColumnCatalogId: bson.M{"$in": catalogIds}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: base.ColumId, Value: 1}}}},
+		bson.D{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"hits": bson.A{
+				bson.M{"$skip": skip},
+				bson.M{"$limit": limit},
+				bson.M{"$project": bson.M{columnSearchTokens: 0, "novel." + columnSearchTokens: 0}},
+			},
+		}}},
+	)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Hits []*entity.ChapterHit `bson:"hits"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 || len(results[0].Total) == 0 {
+		return nil, 0, nil
+	}
+	return results[0].Hits, results[0].Total[0].Count, nil
+}
+
+// EnsureSearchTokens fills the search tokens of the novels and contents saved before full-text search is supported
+func (s *searchRepoImpl) EnsureSearchTokens(ctx context.Context) {
+	missing := bson.M{columnSearchTokens: bson.M{"$exists": false}}
+
+	var novels []*entity.Novel
+	if err := FindAll(ctx, &novels, base.CollectionNovel, missing, options.Find()); err != nil {
+		zap.L().Warn("failed to find novels without search tokens", zap.Error(err))
+	}
+	for _, novel := range novels {
+		updateSearchTokens(ctx, base.CollectionNovel, novel.Id, novelSearchTokens(novel))
+	}
+
+	collection := system.GetSystem().GetCollection(base.CollectionContent)
+	if collection == nil {
+		zap.L().Error("collection not found: " + base.CollectionContent)
+		return
+	}
+	//the contents could be large, they are handled one by one
+	cursor, err := collection.Find(ctx, missing)
+	if err != nil {
+		zap.L().Warn("failed to find contents without search tokens", zap.Error(err))
+		return
+	}
+	defer func() {
+		if err = cursor.Close(context.TODO()); err != nil {
+			zap.L().Warn("an error occurs while closing a cursor", zap.Error(err))
+		}
+	}()
+
+	var count int
+	for cursor.Next(ctx) {
+		var content entity.Content
+		if err = cursor.Decode(&content); err != nil {
+			zap.L().Warn("failed to decode content", zap.Error(err))
+			continue
+		}
+		updateSearchTokens(ctx, base.CollectionContent, content.Id, contentSearchTokens(&content))
+		count++
+	}
+	if len(novels) > 0 || count > 0 {
+		zap.L().Info("search tokens filled", zap.Int("novels", len(novels)), zap.Int("contents", count))
+	}
+}
+
+func updateSearchTokens(ctx context.Context, collectionName string, id primitive.ObjectID, tokens string) {
+	collection := system.GetSystem().GetCollection(collectionName)
+	if collection == nil {
+		zap.L().Error("collection not found: " + collectionName)
+		return
+	}
+	if _, err := collection.UpdateOne(ctx, bson.M{base.ColumId: id},
+		bson.M{"$set": bson.M{columnSearchTokens: tokens}}); err != nil {
+		zap.L().Warn("failed to update search tokens", zap.String("collection", collectionName),
+			zap.String("id", id.Hex()), zap.Error(err))
+	}
+}
+
+func novelSearchTokens(novel *entity.Novel) string {
+	var author string
+	if novel.Attributes != nil && novel.Attributes[base.AttrAuthor] != nil {
+		author = fmt.Sprint(novel.Attributes[base.AttrAuthor])
+	}
+	return base.SearchTokens(novel.Name, author, novel.Description)
+}
+
+func contentSearchTokens(content *entity.Content) string {
+	return base.SearchTokens(base.StripTags(content.Content))
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"errors"
 	"github.com/jeven2016/mylibs/system"
@@ -15,15 +16,27 @@ import (
 
 type siteRepo interface {
 	FindSites(ctx context.Context) ([]entity.Site, error)
+	FindPage(ctx context.Context, query *dto.ListQuery) (*dto.PageResult, error)
 	FindById(ctx context.Context, id primitive.ObjectID) (*entity.Site, error)
+	FindByName(ctx context.Context, name string) (*entity.Site, error)
 	ExistsById(ctx context.Context, id primitive.ObjectID) (bool, error)
+	ExistsByName(ctx context.Context, name string) (bool, error)
+	Update(ctx context.Context, site *entity.Site) error
 	DeleteById(ctx context.Context, id primitive.ObjectID) error
 	FindSettings(ctx context.Context, siteId primitive.ObjectID) (*entity.SiteSettings, error)
 	SaveSettings(ctx context.Context, siteSettings *entity.SiteSettings) (*entity.SiteSettings, error)
+	DeleteSettings(ctx context.Context, siteId primitive.ObjectID) error
 }
 
 type siteRepoImpl struct{}
 
+var siteListSpec = &ListSpec{
+	SortFields:  map[string]string{"name": base.ColumnName, "displayName": base.ColumnDisplayName, "created": "created"},
+	DefaultSort: "name",
+	NameColumn:  base.ColumnName,
+	DateColumn:  "created",
+}
+
 func (s *siteRepoImpl) FindSites(ctx context.Context) ([]entity.Site, error) {
 	findOpts := options.Find()
 	//findOpts.SetProjection(bson.M{base.ColumId: 1, base.ColumnName: 1, base.ColumnDisplayName: 1})
@@ -34,16 +47,36 @@ func (s *siteRepoImpl) FindSites(ctx context.Context) ([]entity.Site, error) {
 	return sites, err
 }
 
+func (s *siteRepoImpl) FindPage(ctx context.Context, query *dto.ListQuery) (*dto.PageResult, error) {
+	return FindList[entity.Site](ctx, base.CollectionSite, nil, query, siteListSpec)
+}
+
 func (s *siteRepoImpl) FindById(ctx context.Context, id primitive.ObjectID) (*entity.Site, error) {
 	return FindById(ctx, id, base.CollectionSite, &entity.Site{})
 }
 
+func (s *siteRepoImpl) FindByName(ctx context.Context, name string) (*entity.Site, error) {
+	return FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionSite, &entity.Site{})
+}
+
 func (s *siteRepoImpl) ExistsById(ctx context.Context, id primitive.ObjectID) (bool, error) {
 	site, err := FindById(ctx, id, base.CollectionSite, &entity.Site{},
 		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
 	return site != nil, err
 }
 
+func (s *siteRepoImpl) ExistsByName(ctx context.Context, name string) (bool, error) {
+	site, err := FindOneByFilter(ctx, bson.M{base.ColumnName: name}, base.CollectionSite, &entity.Site{},
+		&options.FindOneOptions{Projection: bson.M{base.ColumId: 1}})
+	return site != nil, err
+}
+
+func (s *siteRepoImpl) Update(ctx context.Context, site *entity.Site) error {
+	curTime := time.Now()
+	site.UpdatedTime = &curTime
+	return UpdateById(ctx, site.Id, base.CollectionSite, site)
+}
+
 func (s *siteRepoImpl) DeleteById(ctx context.Context, id primitive.ObjectID) error {
 	return DeleteById(ctx, id, base.CollectionSite)
 }
@@ -90,12 +123,17 @@ func (s *siteRepoImpl) SaveSettings(ctx context.Context, siteSettings *entity.Si
 	// Set the upsert option to true to upsert the record
 	opts := options.Update().SetUpsert(true)
 
-	// Upsert the record in the collection
-	result, err := collection.UpdateOne(ctx, bson.M{base.ColumId: siteSettings.SiteId}, doc, opts)
-	if err != nil {
+	// Upsert the record of the site in the collection
+	if _, err = collection.UpdateOne(ctx, bson.M{base.ColumnSiteId: siteSettings.SiteId},
+		bson.M{"$set": doc}, opts); err != nil {
 		return nil, err
 	}
 
 	// Find and return the saved or updated site settings
-	return FindById(ctx, result.UpsertedID.(primitive.ObjectID), base.CollectionSiteSettings, &entity.SiteSettings{})
+	return s.FindSettings(ctx, siteSettings.SiteId)
+}
+
+func (s *siteRepoImpl) DeleteSettings(ctx context.Context, siteId primitive.ObjectID) error {
+	_, err := DeleteMany(ctx, base.CollectionSiteSettings, bson.M{base.ColumnSiteId: siteId})
+	return err
 }
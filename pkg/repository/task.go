@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+type taskRepo interface {
+	FindPage(ctx context.Context, taskType string, filter *dto.TaskFilter, query *dto.ListQuery) (*dto.PageResult, error)
+	Count(ctx context.Context, taskType string, filter *dto.TaskFilter) (int64, error)
+	FindIds(ctx context.Context, taskType string, filter *dto.TaskFilter) ([]primitive.ObjectID, error)
+	FindUrls(ctx context.Context, taskType string, filter *dto.TaskFilter) ([]string, error)
+	UpdateStatus(ctx context.Context, taskType string, filter *dto.TaskFilter, status base.TaskStatus) (int64, error)
+	ResetById(ctx context.Context, taskType string, id primitive.ObjectID) (entity.Resource, error)
+	Delete(ctx context.Context, taskType string, filter *dto.TaskFilter) (int64, error)
+}
+
+type taskRepoImpl struct{}
+
+// taskListSpec the list query of the tasks, the name filter matches the url
+var taskListSpec = &ListSpec{
+	SortFields: map[string]string{"url": base.ColumnUrl, "status": base.ColumnStatus, "retries": base.ColumnRetries,
+		"created": columnCreatedDate, "updated": columnLastUpdated},
+	DefaultSort:    sortById,
+	NameColumn:     base.ColumnUrl,
+	DateColumn:     columnCreatedDate,
+	StatusColumn:   base.ColumnStatus,
+	SiteNameColumn: base.ColumnSiteName,
+}
+
+// FindPage returns a page of the tasks of the type
+func (t *taskRepoImpl) FindPage(ctx context.Context, taskType string, filter *dto.TaskFilter,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return nil, err
+	}
+	switch taskType {
+	case base.TaskTypeCatalogPage:
+		return FindList[entity.CatalogPageTask](ctx, collection, mongoFilter, query, taskListSpec)
+	case base.TaskTypeNovel:
+		return FindList[entity.NovelTask](ctx, collection, mongoFilter, query, taskListSpec)
+	default:
+		return FindList[entity.ChapterTask](ctx, collection, mongoFilter, query, taskListSpec)
+	}
+}
+
+func (t *taskRepoImpl) Count(ctx context.Context, taskType string, filter *dto.TaskFilter) (int64, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return 0, err
+	}
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		zap.L().Error("collection not found: " + collection)
+		return 0, errors.New("collection not found: " + collection)
+	}
+	return col.CountDocuments(ctx, mongoFilter)
+}
+
+// FindIds returns the ids of the matched tasks, it's used to handle the tasks one by one
+func (t *taskRepoImpl) FindIds(ctx context.Context, taskType string, filter *dto.TaskFilter) ([]primitive.ObjectID, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err = FindAll(ctx, &docs, collection, mongoFilter,
+		options.Find().SetProjection(bson.M{base.ColumId: 1}).SetSort(bson.M{base.ColumId: 1})); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Id)
+	}
+	return ids, nil
+}
+
+// FindUrls returns the urls of the matched tasks, e.g. to evict the tasks cached by their urls
+func (t *taskRepoImpl) FindUrls(ctx context.Context, taskType string, filter *dto.TaskFilter) ([]string, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		Url string `bson:"url"`
+	}
+	if err = FindAll(ctx, &docs, collection, mongoFilter,
+		options.Find().SetProjection(bson.M{base.ColumnUrl: 1})); err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		urls = append(urls, doc.Url)
+	}
+	return urls, nil
+}
+
+// UpdateStatus sets the status of the matched tasks, the retries are cleared if the tasks are reset to not started
+func (t *taskRepoImpl) UpdateStatus(ctx context.Context, taskType string, filter *dto.TaskFilter,
+	status base.TaskStatus) (int64, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return 0, err
+	}
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		zap.L().Error("collection not found: " + collection)
+		return 0, errors.New("collection not found: " + collection)
+	}
+	result, err := col.UpdateMany(ctx, mongoFilter, bson.M{"$set": statusFields(status)})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ResetById resets the task to not started and returns the updated one, nil is returned if it doesn't exist
+func (t *taskRepoImpl) ResetById(ctx context.Context, taskType string, id primitive.ObjectID) (entity.Resource, error) {
+	collection, err := taskCollection(taskType)
+	if err != nil {
+		return nil, err
+	}
+	col := system.GetSystem().GetCollection(collection)
+	if col == nil {
+		zap.L().Error("collection not found: " + collection)
+		return nil, errors.New("collection not found: " + collection)
+	}
+	result := col.FindOneAndUpdate(ctx, bson.M{base.ColumId: id},
+		bson.M{"$set": statusFields(base.TaskStatusNotStared)},
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err = result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	task := newTask(taskType)
+	if err = result.Decode(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (t *taskRepoImpl) Delete(ctx context.Context, taskType string, filter *dto.TaskFilter) (int64, error) {
+	collection, mongoFilter, err := taskFilter(taskType, filter)
+	if err != nil {
+		return 0, err
+	}
+	return DeleteMany(ctx, collection, mongoFilter)
+}
+
+func taskCollection(taskType string) (string, error) {
+	switch taskType {
+	case base.TaskTypeCatalogPage:
+		return base.CollectionCatalogPageTask, nil
+	case base.TaskTypeNovel:
+		return base.CollectionNovelTask, nil
+	case base.TaskTypeChapter:
+		return base.CollectionChapterTask, nil
+	}
+	return "", &base.ParamError{Name: "type"}
+}
+
+func newTask(taskType string) entity.Resource {
+	switch taskType {
+	case base.TaskTypeCatalogPage:
+		return &entity.CatalogPageTask{}
+	case base.TaskTypeNovel:
+		return &entity.NovelTask{}
+	default:
+		return &entity.ChapterTask{}
+	}
+}
+
+// taskFilter converts the filter into a mongo filter of the task collection, the chapter tasks are
+// filtered by novel and the others by catalog
+func taskFilter(taskType string, filter *dto.TaskFilter) (string, bson.M, error) {
+	collection, err := taskCollection(taskType)
+	if err != nil {
+		return "", nil, err
+	}
+	mongoFilter := bson.M{}
+	if filter.SiteName != "" {
+		mongoFilter[base.ColumnSiteName] = filter.SiteName
+	}
+	if filter.Status != 0 {
+		mongoFilter[base.ColumnStatus] = filter.Status
+	}
+	if filter.MinRetries > 0 {
+		mongoFilter[base.ColumnRetries] = bson.M{"$gte": filter.MinRetries}
+	}
+	if filter.CatalogId != "" {
+		catalogId, err := primitive.ObjectIDFromHex(filter.CatalogId)
+		if err != nil || taskType == base.TaskTypeChapter {
+			return "", nil, &base.ParamError{Name: "catalogId"}
+		}
+		mongoFilter[base.ColumnCatalogId] = catalogId
+	}
+	if filter.NovelId != "" {
+		novelId, err := primitive.ObjectIDFromHex(filter.NovelId)
+		if err != nil || taskType != base.TaskTypeChapter {
+			return "", nil, &base.ParamError{Name: "novelId"}
+		}
+		mongoFilter[base.ColumnNovelId] = novelId
+	}
+	return collection, mongoFilter, nil
+}
+
+func statusFields(status base.TaskStatus) bson.M {
+	fields := bson.M{base.ColumnStatus: status, columnLastUpdated: time.Now()}
+	if status == base.TaskStatusNotStared {
+		fields[base.ColumnRetries] = 0
+	}
+	return fields
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+func TestTaskFilter(t *testing.T) {
+	catalogId := primitive.NewObjectID()
+	collection, filter, err := taskFilter(base.TaskTypeNovel, &dto.TaskFilter{SiteName: "onej",
+		CatalogId: catalogId.Hex(), Status: base.TaskStatusFailed, MinRetries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.M{
+		base.ColumnSiteName:  "onej",
+		base.ColumnCatalogId: catalogId,
+		base.ColumnStatus:    base.TaskStatusFailed,
+		base.ColumnRetries:   bson.M{"$gte": 2},
+	}
+	if collection != base.CollectionNovelTask || !reflect.DeepEqual(filter, expected) {
+		t.Errorf("unexpected filter of %v: %v", collection, filter)
+	}
+
+	novelId := primitive.NewObjectID()
+	collection, filter, err = taskFilter(base.TaskTypeChapter, &dto.TaskFilter{NovelId: novelId.Hex()})
+	if err != nil || collection != base.CollectionChapterTask ||
+		!reflect.DeepEqual(filter, bson.M{base.ColumnNovelId: novelId}) {
+		t.Errorf("unexpected filter of %v: %v, error: %v", collection, filter, err)
+	}
+
+	// the chapter tasks are filtered by novel only and the others by catalog only
+	invalid := map[string]struct {
+		taskType string
+		filter   dto.TaskFilter
+	}{
+		"catalogId": {base.TaskTypeChapter, dto.TaskFilter{CatalogId: catalogId.Hex()}},
+		"novelId":   {base.TaskTypeCatalogPage, dto.TaskFilter{NovelId: novelId.Hex()}},
+		"type":      {"book", dto.TaskFilter{}},
+	}
+	for name, c := range invalid {
+		_, _, err = taskFilter(c.taskType, &c.filter)
+		var paramErr *base.ParamError
+		if !errors.As(err, &paramErr) || paramErr.Name != name {
+			t.Errorf("%v should be rejected, but the error is %v", name, err)
+		}
+	}
+}
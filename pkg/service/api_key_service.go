@@ -0,0 +1,137 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	apiKeyPrefix       = "ck_"
+	apiKeyBytes        = 24
+	apiKeyPrefixLength = 10
+
+	// the name of the admin key in the config, it's used as the caller identity
+	configAdminKeyName = "admin"
+
+	// the last used time is updated at most once in this interval
+	lastUsedInterval = time.Minute
+)
+
+// ApiKeyServiceInterface the api keys, only the hash of a key is saved so the key can't be retrieved again
+type ApiKeyServiceInterface interface {
+	Create(ctx *gin.Context, req *dto.ApiKeyRequest) (*dto.CreatedApiKey, error)
+	FindAll(ctx *gin.Context) ([]*entity.ApiKey, error)
+	Update(ctx *gin.Context, id primitive.ObjectID, patch *dto.ApiKeyPatch) (*entity.ApiKey, error)
+	DeleteById(ctx *gin.Context, id primitive.ObjectID) (bool, error)
+	Authenticate(ctx *gin.Context, key string) (*entity.ApiKey, error)
+}
+
+type apiKeyServiceImpl struct{}
+
+func NewApiKeyService() ApiKeyServiceInterface {
+	return &apiKeyServiceImpl{}
+}
+
+// Create generates a random key, base.ErrDuplicatedDocument is returned if the name exists
+func (a *apiKeyServiceImpl) Create(ctx *gin.Context, req *dto.ApiKeyRequest) (*dto.CreatedApiKey, error) {
+	if len(req.Scopes) == 0 && len(req.SiteRoles) == 0 {
+		return nil, &base.ParamError{Name: "scopes"}
+	}
+	if req.Name == configAdminKeyName {
+		return nil, base.ErrDuplicatedDocument
+	}
+	if exists, err := repository.ApiKeyRepo.ExistsByName(ctx, req.Name); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, base.ErrDuplicatedDocument
+	}
+
+	random := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(random)
+	apiKey := &entity.ApiKey{
+		Name:      req.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		Hash:      base.HashText(key),
+		Scopes:    req.Scopes,
+		SiteRoles: req.SiteRoles,
+	}
+	id, err := repository.ApiKeyRepo.Insert(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	apiKey.Id = *id
+	return &dto.CreatedApiKey{ApiKey: apiKey, Key: key}, nil
+}
+
+func (a *apiKeyServiceImpl) FindAll(ctx *gin.Context) ([]*entity.ApiKey, error) {
+	return repository.ApiKeyRepo.FindAll(ctx)
+}
+
+// Update changes the scopes, site roles or the disabled flag, base.ErrApiKeyNotFound is returned if the key
+// doesn't exist
+func (a *apiKeyServiceImpl) Update(ctx *gin.Context, id primitive.ObjectID,
+	patch *dto.ApiKeyPatch) (*entity.ApiKey, error) {
+	apiKey, err := repository.ApiKeyRepo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, base.ErrApiKeyNotFound
+	}
+	patch.Apply(apiKey)
+	if err = repository.ApiKeyRepo.Update(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+func (a *apiKeyServiceImpl) DeleteById(ctx *gin.Context, id primitive.ObjectID) (bool, error) {
+	return repository.ApiKeyRepo.DeleteById(ctx, id)
+}
+
+// KeyPrefix returns the prefix of the key as it's stored along with the api key, it identifies a rejected key
+// without revealing the whole of it
+func KeyPrefix(key string) string {
+	if len(key) > apiKeyPrefixLength {
+		return key[:apiKeyPrefixLength]
+	}
+	return key
+}
+
+// Authenticate returns the api key matching the key, nil is returned if it's unknown or disabled.
+// The admin key in the config is accepted as well.
+func (a *apiKeyServiceImpl) Authenticate(ctx *gin.Context, key string) (*entity.ApiKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+	cfg := ConfigService.GetConfig().Auth
+	if cfg != nil && cfg.AdminKey != "" &&
+		subtle.ConstantTimeCompare([]byte(cfg.AdminKey), []byte(key)) == 1 {
+		return &entity.ApiKey{Name: configAdminKeyName, Scopes: []string{base.ScopeAdmin}}, nil
+	}
+
+	apiKey, err := repository.ApiKeyRepo.FindByHash(ctx, base.HashText(key))
+	if err != nil || apiKey == nil || apiKey.Disabled {
+		return nil, err
+	}
+	now := time.Now()
+	if apiKey.LastUsedTime == nil || now.Sub(*apiKey.LastUsedTime) > lastUsedInterval {
+		if err = repository.ApiKeyRepo.UpdateLastUsed(ctx, apiKey.Id, now); err != nil {
+			zap.L().Warn("failed to update the last used time of api key", zap.String("name", apiKey.Name),
+				zap.Error(err))
+		}
+	}
+	return apiKey, nil
+}
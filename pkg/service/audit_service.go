@@ -0,0 +1,34 @@
+package service
+
+import (
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type AuditServiceInterface interface {
+	Record(ctx *gin.Context, log *entity.AuditLog) error
+	FindPage(ctx *gin.Context, filter *dto.AuditFilter, query *dto.ListQuery) (*dto.PageResult, error)
+}
+
+type auditServiceImpl struct{}
+
+func NewAuditService() AuditServiceInterface {
+	return &auditServiceImpl{}
+}
+
+// Record saves the audit log, the time is set if it's missing
+func (a *auditServiceImpl) Record(ctx *gin.Context, log *entity.AuditLog) error {
+	if log.Time == nil {
+		curTime := time.Now()
+		log.Time = &curTime
+	}
+	return repository.AuditLogRepo.Insert(ctx, log)
+}
+
+func (a *auditServiceImpl) FindPage(ctx *gin.Context, filter *dto.AuditFilter,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.AuditLogRepo.FindPage(ctx, filter, query)
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+	"github.com/jeven2016/mylibs/system"
+	"go.uber.org/zap"
+)
+
+// evictCache removes the cached keys, e.g. site:exists:<name>, a failure is only logged since
+// the keys expire anyway
+func evictCache(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	if err := system.GetSystem().RedisClient.Client.Del(ctx, keys...).Err(); err != nil {
+		zap.L().Warn("failed to evict cache", zap.Strings("keys", keys), zap.Error(err))
+	}
+}
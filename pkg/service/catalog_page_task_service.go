@@ -8,7 +8,6 @@ import (
 )
 
 type CatalogPageTaskServiceInterface interface {
-	FindTasksByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID) ([]entity.CatalogPageTask, error)
 	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.CatalogPageTask, error)
 	FindByUrl(ctx *gin.Context, url string) (*entity.CatalogPageTask, error)
 	ExistsById(ctx *gin.Context, id primitive.ObjectID) (bool, error)
@@ -23,10 +22,6 @@ func NewCatalogPageTaskService() CatalogPageTaskServiceInterface {
 	return &catalogPageTaskServiceImpl{}
 }
 
-func (c *catalogPageTaskServiceImpl) FindTasksByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID) ([]entity.CatalogPageTask, error) {
-	return repository.CatalogPageTaskRepo.FindTasksByCatalogId(ctx, catalogId)
-}
-
 func (c *catalogPageTaskServiceImpl) FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.CatalogPageTask, error) {
 	return repository.CatalogPageTaskRepo.FindById(ctx, id)
 }
@@ -1,17 +1,26 @@
 package service
 
 import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
 )
 
 type CatalogServiceInterface interface {
 	FindCatalogsBySiteId(ctx *gin.Context, siteId primitive.ObjectID) ([]entity.Catalog, error)
+	FindPageBySiteId(ctx *gin.Context, siteId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
 	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Catalog, error)
 	ExistsById(ctx *gin.Context, id primitive.ObjectID) (bool, error)
 	ExistsByName(ctx *gin.Context, name string) (bool, error)
+	Update(ctx *gin.Context, catalog *entity.Catalog) (*entity.Catalog, error)
+	DeleteById(ctx *gin.Context, id primitive.ObjectID, opts *dto.DeleteOptions) (*dto.DeleteResult, error)
 }
 
 type catalogServiceImpl struct {
@@ -25,6 +34,11 @@ func (s *catalogServiceImpl) FindCatalogsBySiteId(ctx *gin.Context, siteId primi
 	return repository.CatalogRepo.FindCatalogsBySiteId(ctx, siteId)
 }
 
+func (s *catalogServiceImpl) FindPageBySiteId(ctx *gin.Context, siteId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.CatalogRepo.FindPageBySiteId(ctx, siteId, query)
+}
+
 func (s *catalogServiceImpl) FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Catalog, error) {
 	return repository.CatalogRepo.FindById(ctx, id)
 }
@@ -36,3 +50,118 @@ func (s *catalogServiceImpl) ExistsById(ctx *gin.Context, id primitive.ObjectID)
 func (s *catalogServiceImpl) ExistsByName(ctx *gin.Context, name string) (bool, error) {
 	return repository.CatalogRepo.ExistsByName(ctx, name)
 }
+
+// Update replaces the catalog, base.ErrDuplicatedDocument is returned if it's renamed to an existing name
+func (s *catalogServiceImpl) Update(ctx *gin.Context, catalog *entity.Catalog) (*entity.Catalog, error) {
+	existing, err := repository.CatalogRepo.FindById(ctx, catalog.Id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, base.ErrCatalogNotFound
+	}
+	if siteExists, err := repository.SiteRepo.ExistsById(ctx, catalog.SiteId); err != nil {
+		return nil, err
+	} else if !siteExists {
+		return nil, base.ErrSiteNotFound
+	}
+	if catalog.Name != existing.Name {
+		if exists, err := repository.CatalogRepo.ExistsByName(ctx, catalog.Name); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, base.ErrDuplicatedDocument
+		}
+	}
+
+	catalog.CreatedTime = existing.CreatedTime
+	if err = repository.CatalogRepo.Update(ctx, catalog); err != nil {
+		return nil, err
+	}
+	evictCache(ctx, utils.GenKey(base.CatalogKeyExistsPrefix, existing.Name))
+	return catalog, nil
+}
+
+// DeleteById deletes the catalog, the novels and tasks in it are deleted as well if opts.Cascade is true
+func (s *catalogServiceImpl) DeleteById(ctx *gin.Context, id primitive.ObjectID,
+	opts *dto.DeleteOptions) (*dto.DeleteResult, error) {
+	catalog, err := repository.CatalogRepo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		return nil, base.ErrCatalogNotFound
+	}
+
+	result := &dto.DeleteResult{}
+	if opts.Cascade {
+		site, err := repository.SiteRepo.FindById(ctx, catalog.SiteId)
+		if err != nil {
+			return nil, err
+		}
+		if err = deleteCatalogData(ctx, site, catalog, opts, result); err != nil {
+			return result, err
+		}
+	}
+	if err = repository.CatalogRepo.DeleteById(ctx, id); err != nil {
+		return result, err
+	}
+	result.Add(map[string]int64{base.CollectionCatalog: 1})
+	evictCache(ctx, utils.GenKey(base.CatalogKeyExistsPrefix, catalog.Name))
+	return result, nil
+}
+
+// deleteCatalogData deletes the tasks and novels of the catalog, the directories of the novels are removed
+// if opts.DeleteFiles is true
+func deleteCatalogData(ctx *gin.Context, site *entity.Site, catalog *entity.Catalog, opts *dto.DeleteOptions,
+	result *dto.DeleteResult) error {
+	deleted, err := repository.CascadeRepo.DeleteCatalogTasks(ctx, catalog.Id)
+	result.Add(deleted)
+	if err != nil {
+		return err
+	}
+
+	novels, _, err := repository.NovelRepo.FindByCatalogId(ctx, catalog.Id, 0, 0)
+	if err != nil {
+		return err
+	}
+	if opts.DeleteFiles && site != nil {
+		for _, novel := range novels {
+			if err = deleteNovelFiles(ctx, site, novel, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	novelIds := make([]primitive.ObjectID, len(novels))
+	for i, novel := range novels {
+		novelIds[i] = novel.Id
+	}
+	deleted, err = repository.CascadeRepo.DeleteNovels(ctx, novelIds)
+	result.Add(deleted)
+	return err
+}
+
+// the files are downloaded into <directory>/<novel name> of the site
+func deleteNovelFiles(ctx *gin.Context, site *entity.Site, novel *entity.Novel, result *dto.DeleteResult) error {
+	siteCfg := ConfigService.GetSiteConfig(site.Name)
+	if siteCfg == nil {
+		return nil
+	}
+	novelDir, ok := siteCfg.Attributes["directory"]
+	if !ok || novelDir == "" || novel.Name == "" {
+		return nil
+	}
+	dir := filepath.Join(novelDir, novel.Name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	zap.L().Info("novel directory removed", zap.String("novel", novel.Name), zap.String("dir", dir))
+	result.Directories = append(result.Directories, dir)
+
+	count, err := repository.CascadeRepo.DeleteImageHashes(ctx, dir)
+	result.Add(map[string]int64{base.CollectionImageHash: count})
+	return err
+}
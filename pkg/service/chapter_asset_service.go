@@ -0,0 +1,30 @@
+package service
+
+import (
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChapterAssetServiceInterface interface {
+	FindByChapterId(ctx *gin.Context, chapterId primitive.ObjectID) ([]*entity.ChapterAsset, error)
+	FindByChapterIdAndPage(ctx *gin.Context, chapterId primitive.ObjectID, page int) (*entity.ChapterAsset, error)
+}
+
+type chapterAssetServiceImpl struct {
+}
+
+func NewChapterAssetService() ChapterAssetServiceInterface {
+	return &chapterAssetServiceImpl{}
+}
+
+func (c *chapterAssetServiceImpl) FindByChapterId(ctx *gin.Context,
+	chapterId primitive.ObjectID) ([]*entity.ChapterAsset, error) {
+	return repository.ChapterAssetRepo.FindByChapterId(ctx, chapterId)
+}
+
+func (c *chapterAssetServiceImpl) FindByChapterIdAndPage(ctx *gin.Context, chapterId primitive.ObjectID,
+	page int) (*entity.ChapterAsset, error) {
+	return repository.ChapterAssetRepo.FindByChapterIdAndPage(ctx, chapterId, page)
+}
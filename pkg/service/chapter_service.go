@@ -1,6 +1,7 @@
 package service
 
 import (
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
 	"github.com/gin-gonic/gin"
@@ -8,7 +9,9 @@ import (
 )
 
 type ChapterServiceInterface interface {
+	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Chapter, error)
 	FindByName(ctx *gin.Context, name string) (*entity.Chapter, error)
+	FindByNovelId(ctx *gin.Context, novelId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
 	ExistsByName(ctx *gin.Context, name string) (bool, error)
 	Insert(ctx *gin.Context, novel *entity.Chapter) (*primitive.ObjectID, error)
 	BulkInsert(ctx *gin.Context, chapters []*entity.Chapter, novelId *primitive.ObjectID) error
@@ -22,6 +25,15 @@ func NewChapterService() ChapterServiceInterface {
 	return &chapterServiceImpl{}
 }
 
+func (c *chapterServiceImpl) FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Chapter, error) {
+	return repository.ChapterRepo.FindById(ctx, id)
+}
+
+func (c *chapterServiceImpl) FindByNovelId(ctx *gin.Context, novelId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.ChapterRepo.FindPageByNovelId(ctx, novelId, query)
+}
+
 func (c *chapterServiceImpl) FindByName(ctx *gin.Context, name string) (*entity.Chapter, error) {
 	return repository.ChapterRepo.FindByName(ctx, name)
 }
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"fmt"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/v2"
+	"os"
+	"sort"
+	"strings"
+)
+
+// loadEnv overrides the config keys with the environment variables prefixed with base.EnvPrefix, the words
+// of a key are separated by underscores and matched case-insensitively, e.g. CRAWLERS_HTTP_PORT overrides
+// http.port and CRAWLERS_CRAWLERSETTINGS_NOVELTASKPARALLELISM overrides crawlerSettings.novelTaskParallelism.
+// The values of the lists are separated by commas, so the items of webSites, a list of objects, can't be
+// overridden. The keys overridden are returned.
+func loadEnv(k *koanf.Koanf) ([]string, error) {
+	knownKeys := map[string]string{}
+	for _, key := range k.Keys() {
+		knownKeys[strings.ToLower(key)] = key
+	}
+
+	var overridden []string
+	var readErr error
+	provider := env.ProviderWithValue(base.EnvPrefix, ".", func(name string, value string) (string, any) {
+		name = strings.TrimPrefix(name, base.EnvPrefix)
+		if strings.HasSuffix(name, base.EnvFileSuffix) {
+			// the value set directly takes precedence over the file
+			name = strings.TrimSuffix(name, base.EnvFileSuffix)
+			if _, ok := os.LookupEnv(base.EnvPrefix + name); ok {
+				return "", nil
+			}
+			data, err := os.ReadFile(value)
+			if err != nil {
+				readErr = fmt.Errorf("unable to read %v%v%v: %w", base.EnvPrefix, name, base.EnvFileSuffix, err)
+				return "", nil
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+
+		key := strings.ToLower(strings.ReplaceAll(name, "_", "."))
+		if known, ok := knownKeys[key]; ok {
+			key = known
+		}
+		overridden = append(overridden, key)
+		if _, isList := k.Get(key).([]any); isList {
+			return key, strings.Split(value, ",")
+		}
+		return key, value
+	})
+
+	if err := k.Load(provider, nil); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	sort.Strings(overridden)
+	return overridden, nil
+}
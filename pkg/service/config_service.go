@@ -3,141 +3,339 @@ package service
 import (
 	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
 	"errors"
-	"github.com/duke-git/lancet/v2/slice"
-	gconfig "github.com/jeven2016/mylibs/config"
+	"github.com/fsnotify/fsnotify"
 	"github.com/jeven2016/mylibs/system"
-	"github.com/jeven2016/mylibs/utils"
-	"github.com/redis/go-redis/v9"
-	"github.com/vmihailenco/msgpack/v5"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/knadh/koanf/v2"
 	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// the config files are reloaded once the changes settle down
+const configReloadDelay = 500 * time.Millisecond
+
 type ConfigServiceInterface interface {
 	GetConfig() *InternalConfig
 	LoadInternalConfig(yamlConfig string, extraConfigFile *string) error
-	MergeConfig()
+	Reload() error
 	GetSiteConfig(siteName string) *entity.SiteSettings
+	GetEffectiveSiteConfig(ctx context.Context, siteName string) (*dto.EffectiveSiteSettings, error)
+	NotifySiteChanged(ctx context.Context, siteNames ...string)
+	WatchChanges(ctx context.Context)
+	ValidateConfig(isRegistered func(siteName string) bool) (fieldErrors, warnings base.FieldErrors)
+	DiffSiteConfig(ctx context.Context) ([]dto.SiteSettingsDiff, error)
+}
+
+// resolvedSiteConfig the merged settings of a site with the source of each value
+type resolvedSiteConfig struct {
+	settings *entity.SiteSettings
+	sources  map[string]string
 }
 
 type configServiceImpl struct {
-	internalCfg   *InternalConfig
-	siteConfigMap map[string]*entity.SiteSettings
+	internalCfg atomic.Pointer[InternalConfig]
+	yamlConfig  string
+	configFiles []string
+
+	lock          sync.RWMutex
+	siteConfigMap map[string]*resolvedSiteConfig
+	//increased by each invalidation, the settings resolved meanwhile aren't cached since they might be stale
+	generation uint64
 }
 
 func NewConfigService() ConfigServiceInterface {
 	return &configServiceImpl{
-		internalCfg:   nil,
-		siteConfigMap: map[string]*entity.SiteSettings{},
+		siteConfigMap: map[string]*resolvedSiteConfig{},
 	}
 }
 
-// GetSiteConfig retrieves the site configuration for the given siteKey.
-// If the configuration is not found in the internal configuration, it will attempt to retrieve it from Redis.
-// If the configuration is not found in Redis, it will create a default configuration, store it in Redis, and return it.
-//
-// Parameters:
-// siteKey (string): The unique identifier for the site.
-//
-// Returns:
-// *entity.SiteSettings: A pointer to the site configuration. If an error occurs during retrieval or creation, it returns nil.
+// GetSiteConfig returns the effective settings of the site, which are merged from the layers in the order of
+// precedence: defaults < YAML webSites < siteSettings in mongo. The merged settings are cached until the
+// config files are reloaded or the settings of the site are changed, the returned value must not be modified.
 func (c *configServiceImpl) GetSiteConfig(siteName string) *entity.SiteSettings {
-	// Check if the site configuration is present in the internal configuration
-	cfg, ok := slice.FindBy(c.internalCfg.WebSites, func(index int, item entity.SiteSettings) bool {
-		return item.Name == siteName
-	})
-	if ok {
-		return &cfg
+	resolved, err := c.resolve(context.Background(), siteName)
+	if err != nil {
+		zap.L().Warn("failed to resolve the site settings", zap.String("siteName", siteName), zap.Error(err))
 	}
+	return resolved.settings
+}
 
-	// Generate the Redis key for the site configuration
-	siteConfigKey := utils.GenKey("siteConfig", siteName)
+// GetEffectiveSiteConfig returns the effective settings of the site with the source of each value
+func (c *configServiceImpl) GetEffectiveSiteConfig(ctx context.Context,
+	siteName string) (*dto.EffectiveSiteSettings, error) {
+	resolved, err := c.resolve(ctx, siteName)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.EffectiveSiteSettings{
+		SiteName: siteName,
+		Settings: resolved.settings,
+		Sources:  resolved.sources,
+	}, nil
+}
 
-	// Attempt to retrieve the site configuration from Redis
-	value, err := system.GetSystem().RedisClient.Client.Get(context.Background(), siteConfigKey).Result()
+// resolve merges the settings of the site, the ones without the mongo layer are returned along with the error
+// if the mongo layer fails to load, and they aren't cached so that it's retried next time. Neither are the ones
+// resolved while the settings are invalidated.
+func (c *configServiceImpl) resolve(ctx context.Context, siteName string) (*resolvedSiteConfig, error) {
+	c.lock.RLock()
+	resolved, ok := c.siteConfigMap[siteName]
+	generation := c.generation
+	c.lock.RUnlock()
+	if ok {
+		return resolved, nil
+	}
+
+	layers, sources := staticSiteLayers(c.GetConfig(), siteName)
+	stored, err := c.findStoredSettings(ctx, siteName)
+	if stored != nil {
+		layers = append(layers, stored)
+		sources = append(sources, base.ConfigSourceMongo)
+	}
+	resolved = &resolvedSiteConfig{}
+	resolved.settings, resolved.sources = mergeSiteSettings(siteName, layers, sources)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			// If the configuration is not found in Redis, create a default configuration
-			defaultSetting := c.createDefaultSiteSettings(siteName)
-
-			// Marshal the default configuration to a byte array
-			b, err := msgpack.Marshal(defaultSetting)
-			if err != nil {
-				zap.L().Warn("failed to marshal", zap.Error(err))
-				return nil
-			}
+		return resolved, err
+	}
 
-			// Store the default configuration in Redis
-			if err = system.GetSystem().RedisClient.Client.Set(context.Background(), siteConfigKey, b, 0).Err(); err != nil {
-				zap.L().Warn("failed to set", zap.Error(err))
-				return nil
-			}
+	c.lock.Lock()
+	if c.generation == generation {
+		c.siteConfigMap[siteName] = resolved
+	}
+	c.lock.Unlock()
+	return resolved, nil
+}
 
-			// Return the default configuration
-			return defaultSetting
-		}
-		return nil
-	} else {
-		// If the configuration is found in Redis, unmarshal it from the byte array
-		defaultSetting := &entity.SiteSettings{}
-		if err = msgpack.Unmarshal([]byte(value), defaultSetting); err != nil {
-			zap.L().Warn("failed to unmarshal", zap.Error(err))
-			return nil
+// findStoredSettings returns the settings saved through the api, nil is returned if the database isn't ready
+func (c *configServiceImpl) findStoredSettings(ctx context.Context, siteName string) (*entity.SiteSettings, error) {
+	if system.GetSystem() == nil || system.GetSystem().MongoClient == nil {
+		return nil, nil
+	}
+	site, err := repository.SiteRepo.FindByName(ctx, siteName)
+	if err != nil || site == nil {
+		return nil, err
+	}
+	return repository.SiteRepo.FindSettings(ctx, site.Id)
+}
+
+// ValidateConfig validates the loaded config further, the crawlers registered are checked by isRegistered and
+// the sites without one are returned as warnings
+func (c *configServiceImpl) ValidateConfig(isRegistered func(siteName string) bool) (fieldErrors,
+	warnings base.FieldErrors) {
+	return validateConfig(c.GetConfig(), isRegistered)
+}
+
+// DiffSiteConfig compares the sites of the config files with the settings stored in mongo, which take
+// precedence over the files. Redis isn't compared since the site settings are no longer stored there.
+func (c *configServiceImpl) DiffSiteConfig(ctx context.Context) ([]dto.SiteSettingsDiff, error) {
+	fileSites := map[string]*entity.SiteSettings{}
+	var siteNames []string
+	for i, site := range c.GetConfig().WebSites {
+		fileSites[site.Name] = &c.GetConfig().WebSites[i]
+		siteNames = append(siteNames, site.Name)
+	}
+	sites, err := repository.SiteRepo.FindSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, site := range sites {
+		if _, ok := fileSites[site.Name]; !ok {
+			siteNames = append(siteNames, site.Name)
 		}
+	}
 
-		// Return the unmarshalled configuration
-		return defaultSetting
-	}
-}
-
-func (c *configServiceImpl) createDefaultSiteSettings(siteKey string) *entity.SiteSettings {
-	defaultSetting := &entity.SiteSettings{
-		Name:          siteKey + "_default",
-		RegexSettings: &entity.RegexSettings{},
-		MongoCollections: &entity.MongoCollections{
-			Novel:       "novel_default",
-			CatalogPage: "catalogPage_default",
-		},
-		Attributes: map[string]string{},
-		CrawlerSettings: &entity.CrawlerSetting{
-			Catalog: map[string]any{
-				"skipIfPresent":     false,
-				"skipSaveIfPresent": true,
-			},
-			CatalogPage: map[string]any{
-				"skipIfPresent":     false,
-				"skipSaveIfPresent": true,
-			},
-			Novel: map[string]any{
-				"skipIfPresent":     false,
-				"skipSaveIfPresent": true,
-			},
-
-			Chapter: map[string]any{
-				"skipIfPresent":     false,
-				"skipSaveIfPresent": true,
-			},
-		},
-	}
-	return defaultSetting
+	var diffs []dto.SiteSettingsDiff
+	for _, siteName := range siteNames {
+		stored, err := c.findStoredSettings(ctx, siteName)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diffSiteSettings(siteName, fileSites[siteName], stored)...)
+	}
+	return diffs, nil
 }
 
 func (c *configServiceImpl) GetConfig() *InternalConfig {
-	return c.internalCfg
+	return c.internalCfg.Load()
 }
 
+// LoadInternalConfig loads the embedded config and then the config files overriding it, the files are
+// remembered for reloading
 func (c *configServiceImpl) LoadInternalConfig(yamlConfig string, extraConfigFile *string) error {
-	//load internal config
-	cfg := &InternalConfig{}
-	if err := gconfig.LoadConfig([]byte(yamlConfig), cfg, extraConfigFile, base.ConfigFiles); err != nil {
+	files := append([]string{}, base.ConfigFiles...)
+	if extraConfigFile != nil && *extraConfigFile != "" {
+		files = append(files, *extraConfigFile)
+	}
+	cfg, err := loadConfig(yamlConfig, files)
+	if err != nil {
+		return err
+	}
+	c.yamlConfig = yamlConfig
+	c.configFiles = files
+	c.internalCfg.Store(cfg)
+	return nil
+}
+
+// Reload loads the config files again, the cached site settings are dropped
+func (c *configServiceImpl) Reload() error {
+	cfg, err := loadConfig(c.yamlConfig, c.configFiles)
+	if err != nil {
 		return err
 	}
-	c.internalCfg = cfg
+	c.internalCfg.Store(cfg)
+	c.invalidate(base.ConfigChangedAll)
+	zap.L().Info("config reloaded", zap.Strings("files", c.configFiles))
 	return nil
 }
 
-func (c *configServiceImpl) MergeConfig() {
-	//TODO implement me
-	panic("implement me")
+// NotifySiteChanged drops the cached settings of the sites and notifies the other instances via redis
+func (c *configServiceImpl) NotifySiteChanged(ctx context.Context, siteNames ...string) {
+	for _, siteName := range siteNames {
+		c.invalidate(siteName)
+		if system.GetSystem() == nil || system.GetSystem().RedisClient == nil {
+			continue
+		}
+		if err := system.GetSystem().RedisClient.Client.Publish(ctx, base.ChannelSiteConfigChanged,
+			siteName).Err(); err != nil {
+			zap.L().Warn("failed to notify the change of site settings", zap.String("siteName", siteName),
+				zap.Error(err))
+		}
+	}
+}
+
+// WatchChanges reloads the config files once they are changed and drops the cached settings notified by
+// the other instances, it blocks until the context is done
+func (c *configServiceImpl) WatchChanges(ctx context.Context) {
+	go c.watchFiles(ctx)
+
+	pubSub := system.GetSystem().RedisClient.Client.Subscribe(ctx, base.ChannelSiteConfigChanged)
+	defer func() {
+		_ = pubSub.Close()
+	}()
+	zap.L().Info("watching the changes of site settings", zap.String("channel", base.ChannelSiteConfigChanged))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-pubSub.Channel():
+			if !ok {
+				return
+			}
+			zap.L().Info("site settings changed", zap.String("siteName", msg.Payload))
+			c.invalidate(msg.Payload)
+		}
+	}
+}
+
+// watchFiles watches the directories of the config files rather than the files themselves, since a mounted
+// config map is replaced by renaming a symbolic link
+func (c *configServiceImpl) watchFiles(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		zap.L().Warn("unable to watch the config files", zap.Error(err))
+		return
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	watched := map[string]bool{}
+	for _, f := range c.configFiles {
+		if _, err = os.Stat(f); err != nil {
+			continue
+		}
+		watched[filepath.Clean(f)] = true
+		if err = watcher.Add(filepath.Dir(f)); err != nil {
+			zap.L().Warn("unable to watch the config file", zap.String("file", f), zap.Error(err))
+		}
+	}
+	if len(watched) == 0 {
+		return
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !watched[filepath.Clean(event.Name)] && filepath.Base(event.Name) != "..data" {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(configReloadDelay, func() {
+				if err := c.Reload(); err != nil {
+					zap.L().Error("failed to reload the config files, the previous config is kept", zap.Error(err))
+				}
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			zap.L().Warn("error occurs while watching the config files", zap.Error(err))
+		}
+	}
+}
+
+func (c *configServiceImpl) invalidate(siteName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	if siteName == base.ConfigChangedAll {
+		c.siteConfigMap = map[string]*resolvedSiteConfig{}
+		return
+	}
+	delete(c.siteConfigMap, siteName)
+}
+
+// loadConfig loads the config with a new koanf instance each time, so the keys removed from the files
+// don't survive a reload. The environment variables override the files, see loadEnv. The settings of the
+// sites are validated as well.
+func loadConfig(yamlConfig string, files []string) (*InternalConfig, error) {
+	k := koanf.New(".")
+	if err := k.Load(rawbytes.Provider([]byte(yamlConfig)), yaml.Parser()); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				zap.L().Warn("config file ignored", zap.String("file", f), zap.Error(err))
+			}
+			continue
+		}
+		if err := k.Load(file.Provider(f), yaml.Parser()); err != nil {
+			return nil, err
+		}
+	}
+	overridden, err := loadEnv(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(overridden) > 0 {
+		zap.L().Info("config overridden by environment variables", zap.Strings("keys", overridden))
+	}
+
+	cfg := &InternalConfig{}
+	if err := k.Unmarshal("", cfg); err != nil {
+		return nil, err
+	}
+	if err := validateWebSites(k.Get("webSites"), cfg.WebSites); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
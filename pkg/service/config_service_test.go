@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"errors"
+	"github.com/jeven2016/mylibs/db"
+	"github.com/jeven2016/mylibs/system"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// fakeSiteRepo returns the stored settings of a site, onLoad is called while the settings are loaded
+type fakeSiteRepo struct {
+	settings *entity.SiteSettings
+	onLoad   func()
+}
+
+func (f *fakeSiteRepo) FindSites(context.Context) ([]entity.Site, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) FindPage(context.Context, *dto.ListQuery) (*dto.PageResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) FindById(context.Context, primitive.ObjectID) (*entity.Site, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) FindByName(_ context.Context, name string) (*entity.Site, error) {
+	return &entity.Site{Name: name}, nil
+}
+
+func (f *fakeSiteRepo) ExistsById(context.Context, primitive.ObjectID) (bool, error) {
+	return false, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) ExistsByName(context.Context, string) (bool, error) {
+	return false, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) Update(context.Context, *entity.Site) error {
+	return errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) DeleteById(context.Context, primitive.ObjectID) error {
+	return errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) FindSettings(context.Context, primitive.ObjectID) (*entity.SiteSettings, error) {
+	settings := f.settings
+	if onLoad := f.onLoad; onLoad != nil {
+		f.onLoad = nil
+		onLoad()
+	}
+	return settings, nil
+}
+
+func (f *fakeSiteRepo) SaveSettings(context.Context, *entity.SiteSettings) (*entity.SiteSettings, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeSiteRepo) DeleteSettings(context.Context, primitive.ObjectID) error {
+	return errors.New("not supported")
+}
+
+func TestSiteConfigChangedWhileResolving(t *testing.T) {
+	previousSystem, previousRepo := system.GetSystem(), repository.SiteRepo
+	system.SetSystem(&system.System{MongoClient: &db.Mongo{}})
+	t.Cleanup(func() {
+		system.SetSystem(previousSystem)
+		repository.SiteRepo = previousRepo
+	})
+
+	c := NewConfigService().(*configServiceImpl)
+	if err := c.LoadInternalConfig("webSites:\n  - name: onej\n", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings are saved through the api while the previous ones are being loaded
+	fake := &fakeSiteRepo{settings: &entity.SiteSettings{Render: base.RenderHttp}}
+	fake.onLoad = func() {
+		fake.settings = &entity.SiteSettings{Render: base.RenderBrowser}
+		c.NotifySiteChanged(context.Background(), "onej")
+	}
+	repository.SiteRepo = fake
+
+	if render := c.GetSiteConfig("onej").Render; render != base.RenderHttp {
+		t.Errorf("the settings loaded first should be returned, but the render is %v", render)
+	}
+	if render := c.GetSiteConfig("onej").Render; render != base.RenderBrowser {
+		t.Errorf("the stale settings shouldn't be cached, but the render is %v", render)
+	}
+}
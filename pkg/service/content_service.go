@@ -1,16 +1,23 @@
 package service
 
 import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
 )
 
 type ContentServiceInterface interface {
 	FindByParentIdAndPage(ctx *gin.Context, parentId *primitive.ObjectID, pageNo int) (*entity.Content, error)
 	Insert(ctx *gin.Context, content *entity.Content) (*primitive.ObjectID, error)
 	Save(ctx *gin.Context, novel *entity.Content) (*primitive.ObjectID, error)
+	FindRevisions(ctx *gin.Context, parentId primitive.ObjectID, pageNo int) ([]*entity.ContentRevision, error)
+	DiffRevisions(ctx *gin.Context, parentId primitive.ObjectID, pageNo, from, to int) (*dto.ContentDiff, error)
+	FindChapterContent(ctx *gin.Context, chapter *entity.Chapter) (*dto.ChapterContent, error)
 }
 
 type contentServiceImpl struct {
@@ -31,3 +38,141 @@ func (c contentServiceImpl) Insert(ctx *gin.Context, content *entity.Content) (*
 func (c contentServiceImpl) Save(ctx *gin.Context, novel *entity.Content) (*primitive.ObjectID, error) {
 	return repository.ContentRepo.Save(ctx, novel)
 }
+
+// FindRevisions returns all revisions of a content, the current one comes first
+func (c contentServiceImpl) FindRevisions(ctx *gin.Context, parentId primitive.ObjectID,
+	pageNo int) ([]*entity.ContentRevision, error) {
+	content, err := repository.ContentRepo.FindByParentIdAndPage(ctx, &parentId, pageNo)
+	if err != nil || content == nil {
+		return nil, err
+	}
+	history, err := repository.ContentRevisionRepo.FindByContentId(ctx, content.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	createdTime := content.UpdatedTime
+	if createdTime == nil {
+		createdTime = content.CreatedTime
+	}
+	hash := content.Hash
+	if hash == "" {
+		hash = base.HashText(content.Content)
+	}
+	current := &entity.ContentRevision{
+		ContentId:   content.Id,
+		ParentId:    content.ParentId,
+		Page:        content.Page,
+		Revision:    max(content.Revision, 1),
+		Hash:        hash,
+		CreatedTime: createdTime,
+	}
+	return append([]*entity.ContentRevision{current}, history...), nil
+}
+
+// DiffRevisions compares two revisions of a content, the current revision is used if to is 0
+// and the previous one of to is used if from is 0
+func (c contentServiceImpl) DiffRevisions(ctx *gin.Context, parentId primitive.ObjectID,
+	pageNo, from, to int) (*dto.ContentDiff, error) {
+	content, err := repository.ContentRepo.FindByParentIdAndPage(ctx, &parentId, pageNo)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, base.ErrRevisionNotFound
+	}
+
+	if to <= 0 {
+		to = max(content.Revision, 1)
+	}
+	if from <= 0 {
+		from = to - 1
+	}
+
+	fromText, err := c.revisionText(ctx, content, from)
+	if err != nil {
+		return nil, err
+	}
+	toText, err := c.revisionText(ctx, content, to)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ContentDiff{
+		ParentId: parentId.Hex(),
+		Page:     pageNo,
+		From:     from,
+		To:       to,
+		Lines:    base.DiffLines(fromText, toText),
+	}, nil
+}
+
+func (c contentServiceImpl) revisionText(ctx *gin.Context, content *entity.Content, revision int) (string, error) {
+	if revision == max(content.Revision, 1) {
+		return content.Content, nil
+	}
+	history, err := repository.ContentRevisionRepo.FindByContentIdAndRevision(ctx, content.Id, revision)
+	if err != nil {
+		return "", err
+	}
+	if history == nil {
+		return "", base.ErrRevisionNotFound
+	}
+	return history.Content, nil
+}
+
+// FindChapterContent reassembles the pages of the chapter content in order,
+// the links of the downloaded pictures and the previous/next chapters are returned as well
+func (c contentServiceImpl) FindChapterContent(ctx *gin.Context, chapter *entity.Chapter) (*dto.ChapterContent, error) {
+	contents, err := repository.ContentRepo.FindByParentId(ctx, chapter.Id)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(contents))
+	for i, content := range contents {
+		texts[i] = content.Content
+	}
+	chapterContent := &dto.ChapterContent{
+		ChapterId: chapter.Id.Hex(),
+		NovelId:   chapter.NovelId.Hex(),
+		Name:      chapter.Name,
+		Order:     chapter.Order,
+		Pages:     len(contents),
+		Content:   strings.Join(texts, "\n"),
+	}
+
+	assets, err := repository.ChapterAssetRepo.FindByChapterId(ctx, chapter.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		if asset.Status == base.AssetStatusDownloaded {
+			chapterContent.Images = append(chapterContent.Images,
+				fmt.Sprintf("%v/chapters/%v/assets/%v", base.ApiPrefix, chapter.Id.Hex(), asset.Page))
+		}
+	}
+
+	if chapterContent.Prev, err = c.chapterLink(ctx, chapter, false); err != nil {
+		return nil, err
+	}
+	if chapterContent.Next, err = c.chapterLink(ctx, chapter, true); err != nil {
+		return nil, err
+	}
+	return chapterContent, nil
+}
+
+func (c contentServiceImpl) chapterLink(ctx *gin.Context, chapter *entity.Chapter, next bool) (*dto.ChapterLink, error) {
+	sibling, err := repository.ChapterRepo.FindSibling(ctx, chapter, next)
+	if err != nil || sibling == nil {
+		return nil, err
+	}
+	return newChapterLink(sibling), nil
+}
+
+func newChapterLink(chapter *entity.Chapter) *dto.ChapterLink {
+	return &dto.ChapterLink{
+		Id:    chapter.Id.Hex(),
+		Name:  chapter.Name,
+		Order: chapter.Order,
+		Url:   fmt.Sprintf("%v/chapters/%v/content", base.ApiPrefix, chapter.Id.Hex()),
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/export"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"fmt"
+	"github.com/duke-git/lancet/v2/fileutil"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const coverFileName = "cover.jpg"
+
+// ExportServiceInterface exports the downloaded novels as EPUB and the comics as CBZ
+type ExportServiceInterface interface {
+	Format(ctx *gin.Context, novel *entity.Novel) (string, error)
+	FindCover(ctx *gin.Context, novel *entity.Novel) (string, error)
+	BuildBook(ctx *gin.Context, novel *entity.Novel) (*export.Book, error)
+	FindComicPages(ctx *gin.Context, novel *entity.Novel) ([]*export.ComicPage, error)
+}
+
+type exportServiceImpl struct {
+}
+
+func NewExportService() ExportServiceInterface {
+	return &exportServiceImpl{}
+}
+
+// Format returns export.MimeTypeCbz for a comic, otherwise export.MimeTypeEpub
+func (e *exportServiceImpl) Format(ctx *gin.Context, novel *entity.Novel) (string, error) {
+	catalog, site, err := findNovelSite(ctx, novel)
+	if err != nil {
+		return "", err
+	}
+	crawlerType := base.CrawlerType(0)
+	if catalog != nil {
+		crawlerType = catalog.CrawlerType
+	}
+	if crawlerType == 0 && site != nil {
+		crawlerType = site.CrawlerType
+	}
+	if crawlerType == base.ComicCrawlerType {
+		return export.MimeTypeCbz, nil
+	}
+	return export.MimeTypeEpub, nil
+}
+
+// FindCover returns the cover.jpg downloaded into the directory of the novel, it's empty if not downloaded
+func (e *exportServiceImpl) FindCover(ctx *gin.Context, novel *entity.Novel) (string, error) {
+	_, site, err := findNovelSite(ctx, novel)
+	if err != nil || site == nil {
+		return "", err
+	}
+	siteCfg := ConfigService.GetSiteConfig(site.Name)
+	if siteCfg == nil {
+		return "", nil
+	}
+	novelDir, ok := siteCfg.Attributes["directory"]
+	if !ok {
+		return "", nil
+	}
+	cover := filepath.Join(novelDir, novel.Name, coverFileName)
+	if !fileutil.IsExist(cover) {
+		return "", nil
+	}
+	return cover, nil
+}
+
+// BuildBook collects the chapters of a novel in order, the novel without chapters has only one chapter
+func (e *exportServiceImpl) BuildBook(ctx *gin.Context, novel *entity.Novel) (*export.Book, error) {
+	book := &export.Book{
+		Id:          "urn:crawlers:novel:" + novel.Id.Hex(),
+		Title:       novel.Name,
+		Author:      novelAuthor(novel),
+		Description: novel.Description,
+		Modified:    novelUpdated(novel),
+	}
+
+	cover, err := e.FindCover(ctx, novel)
+	if err != nil {
+		return nil, err
+	}
+	if cover != "" {
+		if book.Cover, err = os.ReadFile(cover); err != nil {
+			return nil, err
+		}
+	}
+
+	chapters, _, err := repository.ChapterRepo.FindByNovelId(ctx, novel.Id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		content, err := joinContents(ctx, novel.Id)
+		if err != nil {
+			return nil, err
+		}
+		book.Chapters = append(book.Chapters, &export.BookChapter{Title: novel.Name, Content: content})
+		return book, nil
+	}
+	for _, chapter := range chapters {
+		content, err := joinContents(ctx, chapter.Id)
+		if err != nil {
+			return nil, err
+		}
+		book.Chapters = append(book.Chapters, &export.BookChapter{Title: chapter.Name, Content: content})
+	}
+	return book, nil
+}
+
+// FindComicPages returns the downloaded pictures of all the chapters in order, the missing ones are skipped
+func (e *exportServiceImpl) FindComicPages(ctx *gin.Context, novel *entity.Novel) ([]*export.ComicPage, error) {
+	chapters, _, err := repository.ChapterRepo.FindByNovelId(ctx, novel.Id, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pages []*export.ComicPage
+	for i, chapter := range chapters {
+		assets, err := repository.ChapterAssetRepo.FindByChapterId(ctx, chapter.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, asset := range assets {
+			if asset.Status != base.AssetStatusDownloaded || !fileutil.IsExist(asset.LocalPath) {
+				continue
+			}
+			pages = append(pages, &export.ComicPage{Chapter: i + 1, Page: asset.Page, Path: asset.LocalPath})
+		}
+	}
+	return pages, nil
+}
+
+// the pages of the content are joined in order
+func joinContents(ctx *gin.Context, parentId primitive.ObjectID) (string, error) {
+	contents, err := repository.ContentRepo.FindByParentId(ctx, parentId)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, len(contents))
+	for i, content := range contents {
+		texts[i] = content.Content
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// the catalog or site is nil if it has been deleted
+func findNovelSite(ctx *gin.Context, novel *entity.Novel) (*entity.Catalog, *entity.Site, error) {
+	catalog, err := repository.CatalogRepo.FindById(ctx, novel.CatalogId)
+	if err != nil || catalog == nil {
+		return nil, nil, err
+	}
+	site, err := repository.SiteRepo.FindById(ctx, catalog.SiteId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return catalog, site, nil
+}
+
+func novelAuthor(novel *entity.Novel) string {
+	if novel.Attributes != nil && novel.Attributes[base.AttrAuthor] != nil {
+		return fmt.Sprint(novel.Attributes[base.AttrAuthor])
+	}
+	return ""
+}
+
+func novelUpdated(novel *entity.Novel) time.Time {
+	if novel.UpdatedTime != nil {
+		return *novel.UpdatedTime
+	}
+	if novel.CreatedTime != nil {
+		return *novel.CreatedTime
+	}
+	return time.Now()
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,7 @@ import (
 type NovelServiceInterface interface {
 	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Novel, error)
 	FindIdByName(ctx *gin.Context, name string) (*primitive.ObjectID, error)
+	FindByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
 	ExistsByName(ctx *gin.Context, name string) (bool, error)
 	Insert(ctx *gin.Context, novel *entity.Novel) (*primitive.ObjectID, error)
 	Save(ctx *gin.Context, task *entity.Novel) (*primitive.ObjectID, error)
@@ -32,6 +34,11 @@ func (s *novelServiceImpl) FindIdByName(ctx *gin.Context, name string) (*primiti
 	return repository.NovelRepo.FindIdByName(ctx, name)
 }
 
+func (s *novelServiceImpl) FindByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.NovelRepo.FindPageByCatalogId(ctx, catalogId, query)
+}
+
 func (s *novelServiceImpl) ExistsByName(ctx *gin.Context, name string) (bool, error) {
 	return repository.NovelRepo.ExistsByName(ctx, name)
 }
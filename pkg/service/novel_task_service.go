@@ -1,16 +1,24 @@
 package service
 
 import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
 )
 
 type NovelTaskServiceInterface interface {
-	FindByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID) ([]entity.NovelTask, error)
+	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.NovelTask, error)
 	FindByUrl(ctx *gin.Context, url string) (*entity.NovelTask, error)
 	Save(ctx *gin.Context, task *entity.NovelTask) (*primitive.ObjectID, error)
+	FindDeferred(ctx *gin.Context, catalogId *primitive.ObjectID, query *dto.ListQuery) (*dto.PageResult, error)
+	StartById(ctx *gin.Context, id primitive.ObjectID, publish TaskPublisher) (*entity.NovelTask, error)
+	StartDeferred(ctx *gin.Context, req *dto.StartTasksRequest, publish TaskPublisher) (*dto.StartTasksResult, error)
 }
 
 type novelTaskServiceImpl struct{}
@@ -19,8 +27,8 @@ func NewNovelTaskService() NovelTaskServiceInterface {
 	return &novelTaskServiceImpl{}
 }
 
-func (impl *novelTaskServiceImpl) FindByCatalogId(ctx *gin.Context, catalogId primitive.ObjectID) ([]entity.NovelTask, error) {
-	return repository.NovelTaskRepo.FindByCatalogId(ctx, catalogId)
+func (impl *novelTaskServiceImpl) FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.NovelTask, error) {
+	return repository.NovelTaskRepo.FindById(ctx, id)
 }
 
 func (impl *novelTaskServiceImpl) FindByUrl(ctx *gin.Context, url string) (*entity.NovelTask, error) {
@@ -30,3 +38,92 @@ func (impl *novelTaskServiceImpl) FindByUrl(ctx *gin.Context, url string) (*enti
 func (impl *novelTaskServiceImpl) Save(ctx *gin.Context, task *entity.NovelTask) (*primitive.ObjectID, error) {
 	return repository.NovelTaskRepo.Save(ctx, task)
 }
+
+// FindDeferred returns a page of the deferred novel tasks with their names and covers for manual picking
+func (impl *novelTaskServiceImpl) FindDeferred(ctx *gin.Context, catalogId *primitive.ObjectID,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	result, err := repository.NovelTaskRepo.FindDeferred(ctx, catalogId, query)
+	if err != nil {
+		return nil, err
+	}
+	tasks := result.Items.([]*entity.NovelTask)
+	novels := make([]*dto.DeferredNovel, 0, len(tasks))
+	for _, task := range tasks {
+		novel := &dto.DeferredNovel{
+			Id:          task.Id,
+			CatalogId:   task.CatalogId,
+			SiteName:    task.SiteName,
+			Name:        task.Name,
+			Url:         task.Url,
+			CreatedDate: task.CreatedDate,
+		}
+		if cover, ok := task.Attributes[base.AttrCover]; ok {
+			novel.Cover = fmt.Sprint(cover)
+		}
+		novels = append(novels, novel)
+	}
+	result.Items = novels
+	return result, nil
+}
+
+// StartById republishes a deferred task with DownloadNow=true, base.ErrTaskNotDeferred is returned if the
+// task doesn't exist or has been started. The task stays deferred if it fails to be published.
+func (impl *novelTaskServiceImpl) StartById(ctx *gin.Context, id primitive.ObjectID,
+	publish TaskPublisher) (*entity.NovelTask, error) {
+	task, err := repository.NovelTaskRepo.MarkStarted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, base.ErrTaskNotDeferred
+	}
+	if err = publish(ctx, task); err != nil {
+		if resetErr := repository.NovelTaskRepo.MarkDeferred(ctx, id); resetErr != nil {
+			zap.L().Error("failed to reset the deferred task", zap.String("id", id.Hex()), zap.Error(resetErr))
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// StartDeferred starts the deferred tasks of a catalog or the selected ones, the tasks not deferred are skipped
+func (impl *novelTaskServiceImpl) StartDeferred(ctx *gin.Context, req *dto.StartTasksRequest,
+	publish TaskPublisher) (*dto.StartTasksResult, error) {
+	var catalogId *primitive.ObjectID
+	var ids []primitive.ObjectID
+	if req.CatalogId == "" && len(req.Ids) == 0 {
+		return nil, &base.ParamError{Name: "catalogId"}
+	}
+	if req.CatalogId != "" {
+		id, err := primitive.ObjectIDFromHex(req.CatalogId)
+		if err != nil {
+			return nil, &base.ParamError{Name: "catalogId"}
+		}
+		catalogId = &id
+	}
+	for _, hexId := range req.Ids {
+		id, err := primitive.ObjectIDFromHex(hexId)
+		if err != nil {
+			return nil, &base.ParamError{Name: "ids"}
+		}
+		ids = append(ids, id)
+	}
+
+	deferredIds, err := repository.NovelTaskRepo.FindDeferredIds(ctx, catalogId, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.StartTasksResult{}
+	for _, id := range deferredIds {
+		if _, err = impl.StartById(ctx, id, publish); err != nil {
+			if errors.Is(err, base.ErrTaskNotDeferred) {
+				continue
+			}
+			return result, err
+		}
+		result.Started++
+	}
+	zap.L().Info("deferred novel tasks started", zap.String("catalogId", req.CatalogId),
+		zap.Int64("started", result.Started))
+	return result, nil
+}
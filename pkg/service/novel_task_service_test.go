@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// fakeNovelTaskRepo the deferred tasks are started once only like the mongo one
+type fakeNovelTaskRepo struct {
+	deferred map[primitive.ObjectID]bool
+	ids      []primitive.ObjectID
+}
+
+func (f *fakeNovelTaskRepo) FindDeferred(context.Context, *primitive.ObjectID, *dto.ListQuery) (*dto.PageResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeNovelTaskRepo) FindDeferredIds(context.Context, *primitive.ObjectID,
+	[]primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return f.ids, nil
+}
+
+func (f *fakeNovelTaskRepo) MarkStarted(_ context.Context, id primitive.ObjectID) (*entity.NovelTask, error) {
+	if !f.deferred[id] {
+		return nil, nil
+	}
+	f.deferred[id] = false
+	return &entity.NovelTask{Id: id, DownloadNow: true}, nil
+}
+
+func (f *fakeNovelTaskRepo) MarkDeferred(_ context.Context, id primitive.ObjectID) error {
+	f.deferred[id] = true
+	return nil
+}
+
+func (f *fakeNovelTaskRepo) FindById(context.Context, primitive.ObjectID) (*entity.NovelTask, error) {
+	return nil, nil
+}
+
+func (f *fakeNovelTaskRepo) FindByUrl(context.Context, string) (*entity.NovelTask, error) {
+	return nil, nil
+}
+
+func (f *fakeNovelTaskRepo) Save(context.Context, *entity.NovelTask) (*primitive.ObjectID, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestStartDeferred(t *testing.T) {
+	started, deferred := primitive.NewObjectID(), primitive.NewObjectID()
+	fake := &fakeNovelTaskRepo{
+		deferred: map[primitive.ObjectID]bool{deferred: true},
+		ids:      []primitive.ObjectID{started, deferred},
+	}
+	previous := repository.NovelTaskRepo
+	repository.NovelTaskRepo = fake
+	defer func() {
+		repository.NovelTaskRepo = previous
+	}()
+
+	var published []entity.Resource
+	publish := func(ctx context.Context, task entity.Resource) error {
+		published = append(published, task)
+		return nil
+	}
+	service := NewNovelTaskService()
+	result, err := service.StartDeferred(&gin.Context{}, &dto.StartTasksRequest{
+		Ids: []string{started.Hex(), deferred.Hex()}}, publish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Started != 1 || len(published) != 1 || !published[0].(*entity.NovelTask).DownloadNow {
+		t.Errorf("only the deferred task should be started, result: %+v, published: %v", result, published)
+	}
+
+	if _, err = service.StartById(&gin.Context{}, deferred, publish); !errors.Is(err, base.ErrTaskNotDeferred) {
+		t.Errorf("the task shouldn't be started twice, but the error is %v", err)
+	}
+	if _, err = service.StartDeferred(&gin.Context{}, &dto.StartTasksRequest{}, publish); err == nil {
+		t.Error("either the catalog or the ids should be required")
+	}
+}
+
+func TestStartByIdFailingPublisher(t *testing.T) {
+	id := primitive.NewObjectID()
+	fake := &fakeNovelTaskRepo{deferred: map[primitive.ObjectID]bool{id: true}}
+	previous := repository.NovelTaskRepo
+	repository.NovelTaskRepo = fake
+	defer func() {
+		repository.NovelTaskRepo = previous
+	}()
+
+	publishErr := errors.New("redis is down")
+	service := NewNovelTaskService()
+	if _, err := service.StartById(&gin.Context{}, id, func(context.Context, entity.Resource) error {
+		return publishErr
+	}); !errors.Is(err, publishErr) {
+		t.Errorf("the error of the publisher should be returned, but it's %v", err)
+	}
+	if !fake.deferred[id] {
+		t.Fatal("the task should stay deferred if it fails to be published")
+	}
+
+	// it could be started again
+	if _, err := service.StartById(&gin.Context{}, id, func(context.Context, entity.Resource) error {
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/extension/export"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"time"
+)
+
+const (
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+	dcNamespace         = "http://purl.org/dc/terms/"
+	opdsNamespace       = "http://opds-spec.org/2010/catalog"
+	openSearchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+)
+
+// OpdsServiceInterface builds the OPDS catalog: sites -> catalogs -> novels
+type OpdsServiceInterface interface {
+	RootFeed(ctx *gin.Context) (*dto.OpdsFeed, error)
+	SiteFeed(ctx *gin.Context, site *entity.Site) (*dto.OpdsFeed, error)
+	CatalogFeed(ctx *gin.Context, catalog *entity.Catalog, page *dto.PageRequest) (*dto.OpdsFeed, error)
+	SearchFeed(ctx *gin.Context, query string, page *dto.PageRequest) (*dto.OpdsFeed, error)
+	OpenSearch() *dto.OpenSearchDescription
+}
+
+type opdsServiceImpl struct {
+}
+
+func NewOpdsService() OpdsServiceInterface {
+	return &opdsServiceImpl{}
+}
+
+// RootFeed the navigation feed of the sites
+func (o *opdsServiceImpl) RootFeed(ctx *gin.Context) (*dto.OpdsFeed, error) {
+	sites, appErr := SiteService.FindSites(ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+	feed := newFeed("urn:crawlers:opds", "Crawlers", base.OpdsPrefix, dto.OpdsNavigationType)
+	for _, site := range sites {
+		title := site.DisplayName
+		if title == "" {
+			title = site.Name
+		}
+		feed.Entries = append(feed.Entries, navigationEntry("urn:crawlers:site:"+site.Id.Hex(), title,
+			site.Description, fmt.Sprintf("%v/sites/%v", base.OpdsPrefix, site.Id.Hex()), site.CreatedTime))
+	}
+	return feed, nil
+}
+
+// SiteFeed the navigation feed of the catalogs in a site
+func (o *opdsServiceImpl) SiteFeed(ctx *gin.Context, site *entity.Site) (*dto.OpdsFeed, error) {
+	catalogs, err := CatalogService.FindCatalogsBySiteId(ctx, site.Id)
+	if err != nil {
+		return nil, err
+	}
+	title := site.DisplayName
+	if title == "" {
+		title = site.Name
+	}
+	feed := newFeed("urn:crawlers:site:"+site.Id.Hex(), title,
+		fmt.Sprintf("%v/sites/%v", base.OpdsPrefix, site.Id.Hex()), dto.OpdsNavigationType)
+	feed.Links = append(feed.Links, &dto.OpdsLink{Rel: "up", Href: base.OpdsPrefix, Type: dto.OpdsNavigationType})
+	for _, catalog := range catalogs {
+		feed.Entries = append(feed.Entries, navigationEntry("urn:crawlers:catalog:"+catalog.Id.Hex(), catalog.Name,
+			catalog.Description, fmt.Sprintf("%v/catalogs/%v", base.OpdsPrefix, catalog.Id.Hex()), catalog.CreatedTime))
+	}
+	return feed, nil
+}
+
+// CatalogFeed the paginated acquisition feed of the novels in a catalog
+func (o *opdsServiceImpl) CatalogFeed(ctx *gin.Context, catalog *entity.Catalog,
+	page *dto.PageRequest) (*dto.OpdsFeed, error) {
+	page.Normalize()
+	novels, total, err := repository.NovelRepo.FindByCatalogId(ctx, catalog.Id, page.Skip(), page.Limit())
+	if err != nil {
+		return nil, err
+	}
+	self := fmt.Sprintf("%v/catalogs/%v", base.OpdsPrefix, catalog.Id.Hex())
+	feed := newFeed("urn:crawlers:catalog:"+catalog.Id.Hex(), catalog.Name, self, dto.OpdsAcquisitionType)
+	feed.Links = append(feed.Links, &dto.OpdsLink{Rel: "up", Type: dto.OpdsNavigationType,
+		Href: fmt.Sprintf("%v/sites/%v", base.OpdsPrefix, catalog.SiteId.Hex())})
+	if err = o.addNovels(ctx, feed, novels, self, total, page); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// SearchFeed the paginated acquisition feed of the novels matched by full-text search
+func (o *opdsServiceImpl) SearchFeed(ctx *gin.Context, query string, page *dto.PageRequest) (*dto.OpdsFeed, error) {
+	page.Normalize()
+	hits, total, err := repository.SearchRepo.SearchNovels(ctx, query, nil, page.Skip(), page.Limit())
+	if err != nil {
+		return nil, err
+	}
+	novels := make([]*entity.Novel, len(hits))
+	for i, hit := range hits {
+		novels[i] = &hit.Novel
+	}
+	self := fmt.Sprintf("%v/search?q=%v", base.OpdsPrefix, url.QueryEscape(query))
+	feed := newFeed("urn:crawlers:search:"+url.QueryEscape(query), query, self, dto.OpdsAcquisitionType)
+	if err = o.addNovels(ctx, feed, novels, self, total, page); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (o *opdsServiceImpl) OpenSearch() *dto.OpenSearchDescription {
+	return &dto.OpenSearchDescription{
+		Xmlns:          openSearchNamespace,
+		ShortName:      "Crawlers",
+		Description:    "Search the novels and comics",
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		Urls: []*dto.OpenSearchUrl{{
+			Type:     dto.OpdsAcquisitionType,
+			Template: base.OpdsPrefix + "/search?q={searchTerms}",
+		}},
+	}
+}
+
+// addNovels appends the novels as acquisition entries with the pagination links
+func (o *opdsServiceImpl) addNovels(ctx *gin.Context, feed *dto.OpdsFeed, novels []*entity.Novel, self string,
+	total int64, page *dto.PageRequest) error {
+	feed.TotalResults = total
+	feed.ItemsPerPage = page.Size
+	feed.StartIndex = page.Skip() + 1
+	if page.Page > 1 {
+		feed.Links = append(feed.Links,
+			&dto.OpdsLink{Rel: "first", Href: pageHref(self, 1, page.Size), Type: dto.OpdsAcquisitionType},
+			&dto.OpdsLink{Rel: "previous", Href: pageHref(self, page.Page-1, page.Size), Type: dto.OpdsAcquisitionType})
+	}
+	if int64(page.Page*page.Size) < total {
+		feed.Links = append(feed.Links,
+			&dto.OpdsLink{Rel: "next", Href: pageHref(self, page.Page+1, page.Size), Type: dto.OpdsAcquisitionType})
+	}
+
+	for _, novel := range novels {
+		entry, err := o.novelEntry(ctx, novel)
+		if err != nil {
+			return err
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return nil
+}
+
+// the acquisition link points at the EPUB or CBZ export of the novel
+func (o *opdsServiceImpl) novelEntry(ctx *gin.Context, novel *entity.Novel) (*dto.OpdsEntry, error) {
+	format, err := ExportService.Format(ctx, novel)
+	if err != nil {
+		return nil, err
+	}
+	cover, err := ExportService.FindCover(ctx, novel)
+	if err != nil {
+		return nil, err
+	}
+
+	novelPath := fmt.Sprintf("%v/novels/%v", base.OpdsPrefix, novel.Id.Hex())
+	entry := &dto.OpdsEntry{
+		Id:       "urn:crawlers:novel:" + novel.Id.Hex(),
+		Title:    novel.Name,
+		Updated:  novelUpdated(novel).UTC().Format(time.RFC3339),
+		Language: "zh",
+	}
+	if author := novelAuthor(novel); author != "" {
+		entry.Author = &dto.OpdsAuthor{Name: author}
+	}
+	if novel.Description != "" {
+		entry.Summary = &dto.OpdsContent{Type: "text", Text: novel.Description}
+	}
+	if format == export.MimeTypeCbz {
+		entry.Links = append(entry.Links, &dto.OpdsLink{Rel: dto.OpdsRelAcquisition, Href: novelPath + "/cbz", Type: format})
+	} else {
+		entry.Links = append(entry.Links, &dto.OpdsLink{Rel: dto.OpdsRelAcquisition, Href: novelPath + "/epub", Type: format})
+	}
+	if cover != "" {
+		entry.Links = append(entry.Links,
+			&dto.OpdsLink{Rel: dto.OpdsRelImage, Href: novelPath + "/cover", Type: "image/jpeg"},
+			&dto.OpdsLink{Rel: dto.OpdsRelThumbnail, Href: novelPath + "/cover", Type: "image/jpeg"})
+	}
+	return entry, nil
+}
+
+func newFeed(id, title, self, feedType string) *dto.OpdsFeed {
+	return &dto.OpdsFeed{
+		Xmlns:           atomNamespace,
+		XmlnsDc:         dcNamespace,
+		XmlnsOpds:       opdsNamespace,
+		XmlnsOpenSearch: openSearchNamespace,
+		Id:              id,
+		Title:           title,
+		Updated:         time.Now().UTC().Format(time.RFC3339),
+		Author:          &dto.OpdsAuthor{Name: "Crawlers"},
+		Links: []*dto.OpdsLink{
+			{Rel: "self", Href: self, Type: feedType},
+			{Rel: "start", Href: base.OpdsPrefix, Type: dto.OpdsNavigationType},
+			{Rel: "search", Href: base.OpdsPrefix + "/opensearch.xml", Type: dto.OpenSearchType},
+		},
+	}
+}
+
+func navigationEntry(id, title, description, href string, updated *time.Time) *dto.OpdsEntry {
+	entry := &dto.OpdsEntry{
+		Id:      id,
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links:   []*dto.OpdsLink{{Rel: "subsection", Href: href, Type: dto.OpdsNavigationType}},
+	}
+	if updated != nil {
+		entry.Updated = updated.UTC().Format(time.RFC3339)
+	}
+	if description != "" {
+		entry.Content = &dto.OpdsContent{Type: "text", Text: description}
+	}
+	return entry
+}
+
+func pageHref(self string, page, size int) string {
+	u, err := url.Parse(self)
+	if err != nil {
+		return self
+	}
+	query := u.Query()
+	query.Set("page", fmt.Sprint(page))
+	query.Set("size", fmt.Sprint(size))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// ReaderServiceInterface the reading progress and bookmarks of the api callers
+type ReaderServiceInterface interface {
+	SaveProgress(ctx *gin.Context, caller string, novelId primitive.ObjectID,
+		req *dto.ProgressRequest) (*entity.ReadingProgress, error)
+	FindProgress(ctx *gin.Context, caller string, novelId primitive.ObjectID) (*entity.ReadingProgress, error)
+	ContinueReading(ctx *gin.Context, caller string, page *dto.PageRequest) (*dto.PageResult, error)
+
+	CreateBookmark(ctx *gin.Context, caller string, req *dto.BookmarkRequest) (*entity.Bookmark, error)
+	FindBookmarks(ctx *gin.Context, caller string, novelId *primitive.ObjectID,
+		page *dto.PageRequest) (*dto.PageResult, error)
+	DeleteBookmark(ctx *gin.Context, caller string, id primitive.ObjectID) (bool, error)
+}
+
+type readerServiceImpl struct {
+}
+
+func NewReaderService() ReaderServiceInterface {
+	return &readerServiceImpl{}
+}
+
+// SaveProgress records the chapter being read, base.ErrChapterNotFound is returned if the chapter
+// doesn't belong to the novel
+func (r *readerServiceImpl) SaveProgress(ctx *gin.Context, caller string, novelId primitive.ObjectID,
+	req *dto.ProgressRequest) (*entity.ReadingProgress, error) {
+	chapter, err := r.findChapter(ctx, req.ChapterId)
+	if err != nil {
+		return nil, err
+	}
+	if chapter.NovelId != novelId {
+		return nil, base.ErrChapterNotFound
+	}
+	return repository.ReadingProgressRepo.Save(ctx, &entity.ReadingProgress{
+		Caller:    caller,
+		NovelId:   novelId,
+		ChapterId: chapter.Id,
+		Position:  req.Position,
+	})
+}
+
+func (r *readerServiceImpl) FindProgress(ctx *gin.Context, caller string,
+	novelId primitive.ObjectID) (*entity.ReadingProgress, error) {
+	return repository.ReadingProgressRepo.FindByCallerAndNovelId(ctx, caller, novelId)
+}
+
+// ContinueReading returns the novels read recently, the latest read comes first
+func (r *readerServiceImpl) ContinueReading(ctx *gin.Context, caller string,
+	page *dto.PageRequest) (*dto.PageResult, error) {
+	page.Normalize()
+	progresses, total, err := repository.ReadingProgressRepo.FindByCaller(ctx, caller, page.Skip(), page.Limit())
+	if err != nil {
+		return nil, err
+	}
+
+	var items = make([]*dto.ContinueReading, 0, len(progresses))
+	for _, progress := range progresses {
+		item, err := r.continueReading(ctx, progress)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return &dto.PageResult{Total: total, Page: page.Page, Size: page.Size, Items: items}, nil
+}
+
+// the novel or chapter might be deleted after reading, the progress is skipped then
+func (r *readerServiceImpl) continueReading(ctx *gin.Context,
+	progress *entity.ReadingProgress) (*dto.ContinueReading, error) {
+	novel, err := repository.NovelRepo.FindById(ctx, progress.NovelId)
+	if err != nil {
+		return nil, err
+	}
+	chapter, err := repository.ChapterRepo.FindById(ctx, progress.ChapterId)
+	if err != nil {
+		return nil, err
+	}
+	if novel == nil || chapter == nil {
+		zap.L().Warn("novel or chapter of the reading progress not found",
+			zap.String("novelId", progress.NovelId.Hex()), zap.String("chapterId", progress.ChapterId.Hex()))
+		return nil, nil
+	}
+
+	item := &dto.ContinueReading{
+		NovelId:     novel.Id.Hex(),
+		NovelName:   novel.Name,
+		ChapterId:   chapter.Id.Hex(),
+		ChapterName: chapter.Name,
+		Position:    progress.Position,
+		UpdatedTime: progress.UpdatedTime,
+		Current:     newChapterLink(chapter),
+	}
+	next, err := repository.ChapterRepo.FindSibling(ctx, chapter, true)
+	if err != nil {
+		return nil, err
+	}
+	if next != nil {
+		item.Next = newChapterLink(next)
+	}
+	return item, nil
+}
+
+// CreateBookmark the novel of the bookmark is the one the chapter belongs to
+func (r *readerServiceImpl) CreateBookmark(ctx *gin.Context, caller string,
+	req *dto.BookmarkRequest) (*entity.Bookmark, error) {
+	chapter, err := r.findChapter(ctx, req.ChapterId)
+	if err != nil {
+		return nil, err
+	}
+	bookmark := &entity.Bookmark{
+		Caller:    caller,
+		NovelId:   chapter.NovelId,
+		ChapterId: chapter.Id,
+		Position:  req.Position,
+		Note:      req.Note,
+	}
+	id, err := repository.BookmarkRepo.Insert(ctx, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	bookmark.Id = *id
+	return bookmark, nil
+}
+
+func (r *readerServiceImpl) FindBookmarks(ctx *gin.Context, caller string, novelId *primitive.ObjectID,
+	page *dto.PageRequest) (*dto.PageResult, error) {
+	page.Normalize()
+	bookmarks, total, err := repository.BookmarkRepo.FindByCaller(ctx, caller, novelId, page.Skip(), page.Limit())
+	if err != nil {
+		return nil, err
+	}
+	if bookmarks == nil {
+		bookmarks = []*entity.Bookmark{}
+	}
+	return &dto.PageResult{Total: total, Page: page.Page, Size: page.Size, Items: bookmarks}, nil
+}
+
+func (r *readerServiceImpl) DeleteBookmark(ctx *gin.Context, caller string, id primitive.ObjectID) (bool, error) {
+	return repository.BookmarkRepo.DeleteByCallerAndId(ctx, caller, id)
+}
+
+func (r *readerServiceImpl) findChapter(ctx *gin.Context, chapterId string) (*entity.Chapter, error) {
+	objectId, err := primitive.ObjectIDFromHex(chapterId)
+	if err != nil {
+		return nil, base.ErrChapterNotFound
+	}
+	chapter, err := repository.ChapterRepo.FindById(ctx, objectId)
+	if err != nil {
+		return nil, err
+	}
+	if chapter == nil {
+		return nil, base.ErrChapterNotFound
+	}
+	return chapter, nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+)
+
+const (
+	defaultSearchSize = 20
+	maxSearchSize     = 100
+	snippetLength     = 120
+)
+
+type SearchServiceInterface interface {
+	Search(ctx *gin.Context, req *dto.SearchRequest, siteId, catalogId *primitive.ObjectID) (*dto.SearchResult, error)
+}
+
+type searchServiceImpl struct {
+}
+
+func NewSearchService() SearchServiceInterface {
+	return &searchServiceImpl{}
+}
+
+// Search 全文检索novel或章节内容，结果按相关度排序
+func (s *searchServiceImpl) Search(ctx *gin.Context, req *dto.SearchRequest,
+	siteId, catalogId *primitive.ObjectID) (*dto.SearchResult, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Size <= 0 {
+		req.Size = defaultSearchSize
+	}
+	req.Size = min(req.Size, maxSearchSize)
+	result := &dto.SearchResult{Page: req.Page, Size: req.Size, Hits: []*dto.SearchHit{}}
+
+	terms := base.Tokenize(req.Query)
+	if len(terms) == 0 {
+		return result, nil
+	}
+
+	catalogIds, err := s.filterCatalogs(ctx, siteId, catalogId, base.CrawlerType(req.CrawlerType))
+	if err != nil {
+		return nil, err
+	}
+	if catalogIds != nil && len(catalogIds) == 0 {
+		return result, nil
+	}
+
+	skip := int64((req.Page - 1) * req.Size)
+	if req.Type == base.SearchTypeChapter {
+		hits, total, err := repository.SearchRepo.SearchChapters(ctx, req.Query, catalogIds, skip, int64(req.Size))
+		if err != nil {
+			return nil, err
+		}
+		result.Total = total
+		for _, hit := range hits {
+			result.Hits = append(result.Hits, &dto.SearchHit{
+				Type:      base.SearchTypeChapter,
+				Id:        hit.Chapter.Id.Hex(),
+				NovelId:   hit.Novel.Id.Hex(),
+				NovelName: hit.Novel.Name,
+				CatalogId: hexId(hit.Novel.CatalogId),
+				Name:      base.Highlight(hit.Chapter.Name, terms, 0),
+				Page:      hit.Page,
+				Score:     hit.Score,
+				Snippet:   base.Highlight(base.StripTags(hit.Content.Content), terms, snippetLength),
+			})
+		}
+		return result, nil
+	}
+
+	hits, total, err := repository.SearchRepo.SearchNovels(ctx, req.Query, catalogIds, skip, int64(req.Size))
+	if err != nil {
+		return nil, err
+	}
+	result.Total = total
+	for _, hit := range hits {
+		snippet := hit.Description
+		if author, ok := hit.Attributes[base.AttrAuthor].(string); ok && !containsAny(snippet, terms) {
+			snippet = strings.TrimSpace(author + " " + snippet)
+		}
+		result.Hits = append(result.Hits, &dto.SearchHit{
+			Type:      base.SearchTypeNovel,
+			Id:        hit.Id.Hex(),
+			NovelId:   hit.Id.Hex(),
+			NovelName: hit.Name,
+			CatalogId: hexId(hit.CatalogId),
+			Name:      base.Highlight(hit.Name, terms, 0),
+			Score:     hit.Score,
+			Snippet:   base.Highlight(snippet, terms, snippetLength),
+		})
+	}
+	return result, nil
+}
+
+// filterCatalogs returns the catalogs matching the filters, nil means no filter is specified
+func (s *searchServiceImpl) filterCatalogs(ctx *gin.Context, siteId, catalogId *primitive.ObjectID,
+	crawlerType base.CrawlerType) ([]primitive.ObjectID, error) {
+	if siteId == nil && catalogId == nil && crawlerType == 0 {
+		return nil, nil
+	}
+
+	sites, err := repository.SiteRepo.FindSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalogIds := []primitive.ObjectID{}
+	for _, site := range sites {
+		if siteId != nil && site.Id != *siteId {
+			continue
+		}
+		catalogs, err := repository.CatalogRepo.FindCatalogsBySiteId(ctx, site.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, catalog := range catalogs {
+			if catalogId != nil && catalog.Id != *catalogId {
+				continue
+			}
+			//the crawler type of site is used if the catalog doesn't specify one
+			catalogType := catalog.CrawlerType
+			if catalogType == 0 {
+				catalogType = site.CrawlerType
+			}
+			if crawlerType != 0 && catalogType != crawlerType {
+				continue
+			}
+			catalogIds = append(catalogIds, catalog.Id)
+		}
+	}
+	return catalogIds, nil
+}
+
+func containsAny(text string, terms []string) bool {
+	text = strings.ToLower(text)
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func hexId(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
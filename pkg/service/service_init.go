@@ -9,6 +9,14 @@ var NovelTaskService NovelTaskServiceInterface
 var ChapterService ChapterServiceInterface
 var ChapterTaskService ChapterTaskServiceInterface
 var ContentService ContentServiceInterface
+var ChapterAssetService ChapterAssetServiceInterface
+var SearchService SearchServiceInterface
+var ReaderService ReaderServiceInterface
+var ExportService ExportServiceInterface
+var OpdsService OpdsServiceInterface
+var TaskService TaskServiceInterface
+var ApiKeyService ApiKeyServiceInterface
+var AuditService AuditServiceInterface
 
 func InitServices() {
 	ConfigService = NewConfigService()
@@ -20,4 +28,12 @@ func InitServices() {
 	ChapterService = NewChapterService()
 	ChapterTaskService = NewChapterTaskService()
 	ContentService = NewContentService()
+	ChapterAssetService = NewChapterAssetService()
+	SearchService = NewSearchService()
+	ReaderService = NewReaderService()
+	ExportService = NewExportService()
+	OpdsService = NewOpdsService()
+	TaskService = NewTaskService()
+	ApiKeyService = NewApiKeyService()
+	AuditService = NewAuditService()
 }
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"fmt"
+	"github.com/duke-git/lancet/v2/maputil"
+	"github.com/duke-git/lancet/v2/pointer"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// the fields identifying the settings document, they aren't merged
+var settingsMetaFields = map[string]bool{
+	"siteId":      true,
+	"name":        true,
+	"createdTime": true,
+	"updatedTime": true,
+}
+
+// defaultSiteSettings the lowest layer of the site settings
+func defaultSiteSettings(siteName string) *entity.SiteSettings {
+	return &entity.SiteSettings{
+		Name:          siteName,
+		RegexSettings: &entity.RegexSettings{},
+		MongoCollections: &entity.MongoCollections{
+			Novel:       "novel_default",
+			CatalogPage: "catalogPage_default",
+		},
+		Attributes: map[string]string{},
+		CrawlerSettings: &entity.CrawlerSetting{
+			Catalog:     defaultStageSettings(),
+			CatalogPage: defaultStageSettings(),
+			Novel:       defaultStageSettings(),
+			Chapter:     defaultStageSettings(),
+		},
+	}
+}
+
+func defaultStageSettings() *entity.StageSettings {
+	return &entity.StageSettings{
+		SkipIfPresent:     pointer.Of(entity.DefaultSkipIfPresent),
+		SkipSaveIfPresent: pointer.Of(entity.DefaultSkipSaveIfPresent),
+		Enabled:           pointer.Of(entity.DefaultStageEnabled),
+	}
+}
+
+// mergeSiteSettings merges the layers of the settings in the order of precedence and records the source of
+// each value by its json path, e.g. regexSettings.pagePrefix or crawlerSettings.novel.skipIfPresent.
+// The zero values of a higher layer don't override the lower ones, but the entries of the maps and the values
+// set through pointers, e.g. the stage settings, always do.
+func mergeSiteSettings(siteName string, layers []*entity.SiteSettings,
+	sources []string) (*entity.SiteSettings, map[string]string) {
+	merged := &entity.SiteSettings{Name: siteName}
+	valueSources := make(map[string]string)
+	for i, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		mergeValue(reflect.ValueOf(merged).Elem(), reflect.ValueOf(layer).Elem(), "", sources[i], valueSources)
+	}
+	return merged, valueSources
+}
+
+func mergeValue(dst, src reflect.Value, path, source string, valueSources map[string]string) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		if src.Elem().Kind() != reflect.Struct {
+			value := reflect.New(src.Type().Elem())
+			value.Elem().Set(src.Elem())
+			dst.Set(value)
+			valueSources[path] = source
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(src.Type().Elem()))
+		}
+		mergeValue(dst.Elem(), src.Elem(), path, source, valueSources)
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			field := src.Type().Field(i)
+			name := jsonName(field)
+			if !field.IsExported() || name == "-" || (path == "" && settingsMetaFields[name]) {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i), joinPath(path, name), source, valueSources)
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(src.Type()))
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+			valueSources[joinPath(path, fmt.Sprint(iter.Key().Interface()))] = source
+		}
+	default:
+		if src.IsZero() {
+			return
+		}
+		dst.Set(src)
+		valueSources[path] = source
+	}
+}
+
+// collectValues collects the values of the settings overriding the lower layers by their json paths,
+// following the same rules as mergeValue
+func collectValues(v reflect.Value, path string, values map[string]any) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if v.Elem().Kind() != reflect.Struct {
+			values[path] = v.Elem().Interface()
+			return
+		}
+		collectValues(v.Elem(), path, values)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := jsonName(field)
+			if !field.IsExported() || name == "-" || (path == "" && settingsMetaFields[name]) {
+				continue
+			}
+			collectValues(v.Field(i), joinPath(path, name), values)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			values[joinPath(path, fmt.Sprint(iter.Key().Interface()))] = iter.Value().Interface()
+		}
+	default:
+		if !v.IsZero() {
+			values[path] = v.Interface()
+		}
+	}
+}
+
+// diffSiteSettings compares the values set in the config files with the stored ones, the paths are sorted
+func diffSiteSettings(siteName string, file, stored *entity.SiteSettings) []dto.SiteSettingsDiff {
+	fileValues, storedValues := map[string]any{}, map[string]any{}
+	if file != nil {
+		collectValues(reflect.ValueOf(file).Elem(), "", fileValues)
+	}
+	if stored != nil {
+		collectValues(reflect.ValueOf(stored).Elem(), "", storedValues)
+	}
+	paths := maputil.Keys(fileValues)
+	for path := range storedValues {
+		if _, ok := fileValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []dto.SiteSettingsDiff
+	for _, path := range paths {
+		if !reflect.DeepEqual(fileValues[path], storedValues[path]) {
+			diffs = append(diffs, dto.SiteSettingsDiff{
+				SiteName: siteName,
+				Path:     path,
+				File:     fileValues[path],
+				Stored:   storedValues[path],
+			})
+		}
+	}
+	return diffs
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// the layers of the site settings except the mongo one, which is loaded per request
+func staticSiteLayers(cfg *InternalConfig, siteName string) ([]*entity.SiteSettings, []string) {
+	layers := []*entity.SiteSettings{defaultSiteSettings(siteName)}
+	sources := []string{base.ConfigSourceDefault}
+	for i := range cfg.WebSites {
+		if cfg.WebSites[i].Name == siteName {
+			layers = append(layers, &cfg.WebSites[i])
+			sources = append(sources, base.ConfigSourceYaml)
+			break
+		}
+	}
+	return layers, sources
+}
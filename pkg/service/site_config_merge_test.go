@@ -0,0 +1,108 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"github.com/duke-git/lancet/v2/pointer"
+	"testing"
+)
+
+func TestMergeSiteSettings(t *testing.T) {
+	yamlLayer := &entity.SiteSettings{
+		Name:          "onej",
+		RegexSettings: &entity.RegexSettings{PagePrefix: "/page/", PageSuffix: ".html"},
+		Attributes:    map[string]string{"homepage": "https://onej.example"},
+		CrawlerSettings: &entity.CrawlerSetting{
+			Novel: &entity.StageSettings{SkipIfPresent: pointer.Of(true)},
+		},
+	}
+	mongoLayer := &entity.SiteSettings{
+		Name:             "onej-settings",
+		RegexSettings:    &entity.RegexSettings{PageSuffix: ".htm"},
+		UseSeparateSpace: true,
+		CrawlerSettings: &entity.CrawlerSetting{
+			Novel: &entity.StageSettings{SkipSaveIfPresent: pointer.Of(false)},
+		},
+	}
+
+	merged, sources := mergeSiteSettings("onej",
+		[]*entity.SiteSettings{defaultSiteSettings("onej"), yamlLayer, mongoLayer},
+		[]string{base.ConfigSourceDefault, base.ConfigSourceYaml, base.ConfigSourceMongo})
+
+	if merged.Name != "onej" {
+		t.Errorf("the name should be the site name, but it's %v", merged.Name)
+	}
+	if merged.RegexSettings.PagePrefix != "/page/" || merged.RegexSettings.PageSuffix != ".htm" {
+		t.Errorf("unexpected regex settings: %+v", merged.RegexSettings)
+	}
+	if !merged.UseSeparateSpace {
+		t.Error("useSeparateSpace should be overridden by mongo")
+	}
+	novel := merged.CrawlerSettings.Novel
+	if !novel.ShouldSkipIfPresent() || novel.ShouldSkipSaveIfPresent() || !novel.IsEnabled() {
+		t.Errorf("unexpected novel settings: %+v", novel)
+	}
+	if yamlLayer.CrawlerSettings.Novel.SkipSaveIfPresent != nil {
+		t.Error("the yaml layer shouldn't be modified")
+	}
+	if merged.MongoCollections.Novel != "novel_default" {
+		t.Errorf("the default collection should be kept, but it's %v", merged.MongoCollections.Novel)
+	}
+
+	expected := map[string]string{
+		"regexSettings.pagePrefix":              base.ConfigSourceYaml,
+		"regexSettings.pageSuffix":              base.ConfigSourceMongo,
+		"attributes.homepage":                   base.ConfigSourceYaml,
+		"useSeparateSpace":                      base.ConfigSourceMongo,
+		"crawlerSettings.novel.skipIfPresent":   base.ConfigSourceYaml,
+		"crawlerSettings.chapter.skipIfPresent": base.ConfigSourceDefault,
+		"mongoCollections.novel":                base.ConfigSourceDefault,
+	}
+	for path, source := range expected {
+		if sources[path] != source {
+			t.Errorf("the source of %v should be %v, but it's %v", path, source, sources[path])
+		}
+	}
+	if _, ok := sources["name"]; ok {
+		t.Error("the name shouldn't be merged")
+	}
+}
+
+func TestMergeSiteSettingsKeepsLayers(t *testing.T) {
+	yamlLayer := &entity.SiteSettings{Attributes: map[string]string{"a": "1"}}
+	mongoLayer := &entity.SiteSettings{Attributes: map[string]string{"b": "2"}}
+	merged, _ := mergeSiteSettings("s", []*entity.SiteSettings{yamlLayer, mongoLayer},
+		[]string{base.ConfigSourceYaml, base.ConfigSourceMongo})
+
+	if len(merged.Attributes) != 2 {
+		t.Errorf("the attributes should be merged, but they're %v", merged.Attributes)
+	}
+	if len(yamlLayer.Attributes) != 1 {
+		t.Errorf("the yaml layer shouldn't be modified, but it's %v", yamlLayer.Attributes)
+	}
+}
+
+func TestDiffSiteSettings(t *testing.T) {
+	file := &entity.SiteSettings{
+		Name:          "onej",
+		RegexSettings: &entity.RegexSettings{PagePrefix: "/page/", PageSuffix: ".html"},
+	}
+	stored := &entity.SiteSettings{
+		Name:          "onej-settings",
+		RegexSettings: &entity.RegexSettings{PageSuffix: ".htm", PagePrefix: "/page/"},
+		CrawlerSettings: &entity.CrawlerSetting{
+			Novel: &entity.StageSettings{Enabled: pointer.Of(false)},
+		},
+	}
+
+	diffs := diffSiteSettings("onej", file, stored)
+	if len(diffs) != 2 {
+		t.Fatalf("two differences should be found, but they're %+v", diffs)
+	}
+	if diffs[0].Path != "crawlerSettings.novel.enabled" || diffs[0].File != nil || diffs[0].Stored != false {
+		t.Errorf("unexpected difference: %+v", diffs[0])
+	}
+	if diffs[1].Path != "regexSettings.pageSuffix" || diffs[1].File != ".html" || diffs[1].Stored != ".htm" {
+		t.Errorf("unexpected difference: %+v", diffs[1])
+	}
+}
@@ -2,21 +2,28 @@ package service
 
 import (
 	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
 	"crawlers/pkg/model/entity"
 	"crawlers/pkg/repository"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"time"
 )
 
 type SiteServiceInterface interface {
 	FindSites(ctx *gin.Context) ([]entity.Site, *base.AppError)
+	FindPage(ctx *gin.Context, query *dto.ListQuery) (*dto.PageResult, error)
 	FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Site, error)
 	ExistsById(ctx *gin.Context, id primitive.ObjectID) (bool, error)
-	DeleteById(ctx *gin.Context, id primitive.ObjectID) error
+	ExistsByName(ctx *gin.Context, name string) (bool, error)
+	Update(ctx *gin.Context, site *entity.Site) (*entity.Site, error)
+	DeleteById(ctx *gin.Context, id primitive.ObjectID, opts *dto.DeleteOptions) (*dto.DeleteResult, error)
 	FindSettings(ctx *gin.Context, siteId primitive.ObjectID) (*entity.SiteSettings, *base.AppError)
 	SaveSettings(ctx *gin.Context, siteSettings *entity.SiteSettings) (*entity.SiteSettings, error)
+	DeleteSettings(ctx *gin.Context, siteId primitive.ObjectID) error
 }
 
 type siteServiceImpl struct {
@@ -34,6 +41,10 @@ func (s siteServiceImpl) FindSites(ctx *gin.Context) ([]entity.Site, *base.AppEr
 	return sites, nil
 }
 
+func (s siteServiceImpl) FindPage(ctx *gin.Context, query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.SiteRepo.FindPage(ctx, query)
+}
+
 func (s siteServiceImpl) FindById(ctx *gin.Context, id primitive.ObjectID) (*entity.Site, error) {
 	return repository.SiteRepo.FindById(ctx, id)
 }
@@ -42,8 +53,80 @@ func (s siteServiceImpl) ExistsById(ctx *gin.Context, id primitive.ObjectID) (bo
 	return repository.SiteRepo.ExistsById(ctx, id)
 }
 
-func (s siteServiceImpl) DeleteById(ctx *gin.Context, id primitive.ObjectID) error {
-	return repository.SiteRepo.DeleteById(ctx, id)
+func (s siteServiceImpl) ExistsByName(ctx *gin.Context, name string) (bool, error) {
+	return repository.SiteRepo.ExistsByName(ctx, name)
+}
+
+// Update replaces the site, base.ErrDuplicatedDocument is returned if it's renamed to an existing name
+func (s siteServiceImpl) Update(ctx *gin.Context, site *entity.Site) (*entity.Site, error) {
+	existing, err := repository.SiteRepo.FindById(ctx, site.Id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, base.ErrSiteNotFound
+	}
+	if site.Name != existing.Name {
+		if exists, err := repository.SiteRepo.ExistsByName(ctx, site.Name); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, base.ErrDuplicatedDocument
+		}
+	}
+
+	site.CreatedTime = existing.CreatedTime
+	if err = repository.SiteRepo.Update(ctx, site); err != nil {
+		return nil, err
+	}
+	evictCache(ctx, utils.GenKey(base.SiteKeyExistsPrefix, existing.Name))
+	if site.Name != existing.Name {
+		//the settings are looked up by the site name
+		ConfigService.NotifySiteChanged(ctx, existing.Name, site.Name)
+	}
+	return site, nil
+}
+
+// DeleteById deletes the site and its settings, the catalogs with their novels and tasks are deleted as well
+// if opts.Cascade is true
+func (s siteServiceImpl) DeleteById(ctx *gin.Context, id primitive.ObjectID,
+	opts *dto.DeleteOptions) (*dto.DeleteResult, error) {
+	site, err := repository.SiteRepo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if site == nil {
+		return nil, base.ErrSiteNotFound
+	}
+
+	result := &dto.DeleteResult{}
+	if opts.Cascade {
+		catalogs, err := repository.CatalogRepo.FindCatalogsBySiteId(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for i := range catalogs {
+			catalog := &catalogs[i]
+			if err = deleteCatalogData(ctx, site, catalog, opts, result); err != nil {
+				return result, err
+			}
+			if err = repository.CatalogRepo.DeleteById(ctx, catalog.Id); err != nil {
+				return result, err
+			}
+			result.Add(map[string]int64{base.CollectionCatalog: 1})
+			evictCache(ctx, utils.GenKey(base.CatalogKeyExistsPrefix, catalog.Name))
+		}
+	}
+
+	if err = s.DeleteSettings(ctx, id); err != nil {
+		return result, err
+	}
+	if err = repository.SiteRepo.DeleteById(ctx, id); err != nil {
+		return result, err
+	}
+	result.Add(map[string]int64{base.CollectionSite: 1})
+	evictCache(ctx, utils.GenKey(base.SiteKeyExistsPrefix, site.Name),
+		utils.GenKey(base.SiteKeyExistsPrefix, id.Hex()))
+	return result, nil
 }
 
 func (s siteServiceImpl) FindSettings(ctx *gin.Context, siteId primitive.ObjectID) (*entity.SiteSettings, *base.AppError) {
@@ -69,6 +152,51 @@ func (s siteServiceImpl) FindSettings(ctx *gin.Context, siteId primitive.ObjectI
 	return settings, nil
 }
 
+// SaveSettings replaces the settings of the site, the created time of the existing settings is kept.
+// base.FieldErrors is returned if the settings are invalid.
 func (s siteServiceImpl) SaveSettings(ctx *gin.Context, siteSettings *entity.SiteSettings) (*entity.SiteSettings, error) {
-	return repository.SiteRepo.SaveSettings(ctx, siteSettings)
+	if fieldErrors := ValidateSiteSettings(siteSettings); fieldErrors != nil {
+		return nil, fieldErrors
+	}
+	site, err := repository.SiteRepo.FindById(ctx, siteSettings.SiteId)
+	if err != nil {
+		return nil, err
+	}
+	if site == nil {
+		return nil, base.ErrSiteNotFound
+	}
+
+	existing, err := repository.SiteRepo.FindSettings(ctx, siteSettings.SiteId)
+	if err != nil {
+		return nil, err
+	}
+	curTime := time.Now()
+	siteSettings.CreatedTime = &curTime
+	if existing != nil {
+		siteSettings.CreatedTime = existing.CreatedTime
+		//the secret attributes read from the api are redacted, they're saved back as they are
+		base.RestoreAttributes(siteSettings.Attributes, existing.Attributes)
+		if siteSettings.ProxySettings != nil && existing.ProxySettings != nil {
+			base.RestoreUrls(siteSettings.ProxySettings.Proxies, existing.ProxySettings.Proxies)
+		}
+	}
+
+	saved, err := repository.SiteRepo.SaveSettings(ctx, siteSettings)
+	if err != nil {
+		return nil, err
+	}
+	ConfigService.NotifySiteChanged(ctx, site.Name)
+	return saved, nil
+}
+
+func (s siteServiceImpl) DeleteSettings(ctx *gin.Context, siteId primitive.ObjectID) error {
+	site, err := repository.SiteRepo.FindById(ctx, siteId)
+	if err != nil || site == nil {
+		return err
+	}
+	if err = repository.SiteRepo.DeleteSettings(ctx, siteId); err != nil {
+		return err
+	}
+	ConfigService.NotifySiteChanged(ctx, site.Name)
+	return nil
 }
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/entity"
+	"errors"
+	"fmt"
+	"github.com/duke-git/lancet/v2/slice"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// the max hamming distance of two 64-bit perceptual hashes
+	maxPerceptualDistance = 64
+
+	// the max number of the goroutines consuming the tasks of a stage
+	maxTaskParallelism = 64
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIdType = reflect.TypeOf(entity.SiteSettings{}.SiteId)
+)
+
+// CheckSiteSettingsDocument checks the keys and the types of the values of a raw settings document, the keys
+// are matched with the tag of the fields, e.g. koanf for the yaml config and json for the api requests
+func CheckSiteSettingsDocument(document map[string]any, tag string) base.FieldErrors {
+	fieldErrors := base.FieldErrors{}
+	checkDocument(document, reflect.TypeOf(entity.SiteSettings{}), tag, "", fieldErrors)
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return fieldErrors
+}
+
+// ValidateSiteSettings validates the values of the settings, nil is returned if they're valid
+func ValidateSiteSettings(settings *entity.SiteSettings) base.FieldErrors {
+	fieldErrors := base.FieldErrors{}
+	if regex := settings.RegexSettings; regex != nil {
+		if _, err := regexp.Compile(regex.ParsePageRegex); regex.ParsePageRegex != "" && err != nil {
+			fieldErrors["regexSettings.parsePageRegex"] = err.Error()
+		}
+		if regex.PageSize < 0 {
+			fieldErrors["regexSettings.pageSize"] = "should not be negative"
+		}
+		if regex.MaxPages < 0 {
+			fieldErrors["regexSettings.maxPages"] = "should not be negative"
+		}
+		if regex.PageMode != "" && regex.PageMode != base.PageModeNumber && regex.PageMode != base.PageModeNext {
+			fieldErrors["regexSettings.pageMode"] = fmt.Sprintf("should be %v or %v", base.PageModeNumber,
+				base.PageModeNext)
+		}
+	}
+	if settings.Render != "" && settings.Render != base.RenderHttp && settings.Render != base.RenderBrowser {
+		fieldErrors["render"] = fmt.Sprintf("should be %v or %v", base.RenderHttp, base.RenderBrowser)
+	}
+	if browser := settings.BrowserSettings; browser != nil {
+		if browser.TimeoutSeconds < 0 {
+			fieldErrors["browserSettings.timeoutSeconds"] = "should not be negative"
+		}
+		validateResourceTypes(browser.BlockedResourceTypes, "browserSettings.blockedResourceTypes", fieldErrors)
+	}
+	if httpCache := settings.HttpCache; httpCache != nil && httpCache.TtlSeconds < 0 {
+		fieldErrors["httpCache.ttlSeconds"] = "should not be negative"
+	}
+	if proxySettings := settings.ProxySettings; proxySettings != nil {
+		validateProxies(proxySettings.Proxies, proxySettings.Strategy, "proxySettings", fieldErrors)
+		for i, proxy := range proxySettings.Proxies {
+			if msg := checkBrowserProxy(proxy, ""); msg != "" && settings.Render == base.RenderBrowser {
+				fieldErrors[fmt.Sprintf("proxySettings.proxies[%d]", i)] = msg
+			}
+		}
+	}
+	if image := settings.ImageSettings; image != nil {
+		if image.LinkMode != "" && image.LinkMode != base.ImageLinkModeHardlink &&
+			image.LinkMode != base.ImageLinkModeReference {
+			fieldErrors["imageSettings.linkMode"] = fmt.Sprintf("should be %v or %v", base.ImageLinkModeHardlink,
+				base.ImageLinkModeReference)
+		}
+		if image.MaxPerceptualDistance < 0 || image.MaxPerceptualDistance > maxPerceptualDistance {
+			fieldErrors["imageSettings.maxPerceptualDistance"] = fmt.Sprintf("should be between 0 and %v",
+				maxPerceptualDistance)
+		}
+		for i, hash := range image.BlockedHashes {
+			if !strings.HasPrefix(hash, "sha256:") && !strings.HasPrefix(hash, "phash:") {
+				fieldErrors[fmt.Sprintf("imageSettings.blockedHashes[%d]", i)] = "should be sha256:<hex> or phash:<hex>"
+			}
+		}
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return fieldErrors
+}
+
+func validateResourceTypes(resourceTypes []string, path string, fieldErrors base.FieldErrors) {
+	for i, resourceType := range resourceTypes {
+		if !slice.Contain(base.RenderResourceTypes, strings.ToLower(resourceType)) {
+			fieldErrors[fmt.Sprintf("%v[%d]", path, i)] = "should be one of " + strings.Join(base.RenderResourceTypes, ", ")
+		}
+	}
+}
+
+// validateProxies the proxies should be http, https or socks5 urls
+func validateProxies(proxies []string, strategy, path string, fieldErrors base.FieldErrors) {
+	for i, proxy := range proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" || !slice.Contain([]string{"http", "https", "socks5"}, u.Scheme) {
+			fieldErrors[fmt.Sprintf("%v.proxies[%d]", path, i)] = "should be a http, https or socks5 url"
+		}
+	}
+	if strategy != "" && strategy != base.ProxyStrategyRoundRobin && strategy != base.ProxyStrategySticky &&
+		strategy != base.ProxyStrategyRandom {
+		fieldErrors[path+".strategy"] = fmt.Sprintf("should be %v, %v or %v", base.ProxyStrategyRoundRobin,
+			base.ProxyStrategySticky, base.ProxyStrategyRandom)
+	}
+}
+
+// checkBrowserProxy the browser can't authenticate to the socks5 proxies requiring the credentials, siteName
+// is set if the proxy is a global one
+func checkBrowserProxy(proxy, siteName string) string {
+	if u, err := url.Parse(proxy); err != nil || !base.IsSocks5WithCredentials(u) {
+		return ""
+	}
+	if siteName != "" {
+		return "the browser of " + siteName + " can't authenticate to a socks5 proxy"
+	}
+	return "the browser can't authenticate to a socks5 proxy"
+}
+
+// validateWebSites checks the sites of the yaml config, the raw documents are checked for the unknown keys
+// and the wrong types
+func validateWebSites(rawSites any, sites []entity.SiteSettings) error {
+	fieldErrors := base.FieldErrors{}
+	if rawSites != nil {
+		checkDocument(rawSites, reflect.TypeOf(sites), "koanf", "webSites", fieldErrors)
+	}
+	for i := range sites {
+		for path, msg := range ValidateSiteSettings(&sites[i]) {
+			fieldErrors[fmt.Sprintf("webSites[%d].%v", i, path)] = msg
+		}
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid webSites: %w", fieldErrors)
+}
+
+// validateConfig checks what can only be found at runtime: the directories not writable and the parallelism
+// out of range. The sites without a registered crawler are only warned since the server runs with them, their
+// tasks fail until a crawler is registered.
+func validateConfig(cfg *InternalConfig, isRegistered func(siteName string) bool) (fieldErrors, warnings base.FieldErrors) {
+	fieldErrors, warnings = base.FieldErrors{}, base.FieldErrors{}
+	if settings := cfg.CrawlerSettings; settings != nil {
+		parallelism := map[string]int{
+			"crawlerSettings.catalogPageTaskParallelism": settings.CatalogPageTaskParallelism,
+			"crawlerSettings.novelTaskParallelism":       settings.NovelTaskParallelism,
+			"crawlerSettings.chapterTaskParallelism":     settings.ChapterTaskParallelism,
+		}
+		for path, value := range parallelism {
+			if value < 1 || value > maxTaskParallelism {
+				fieldErrors[path] = fmt.Sprintf("should be between 1 and %v", maxTaskParallelism)
+			}
+		}
+	} else {
+		fieldErrors["crawlerSettings"] = "is required"
+	}
+
+	if render := cfg.Render; render != nil {
+		if render.PoolSize < 0 {
+			fieldErrors["render.poolSize"] = "should not be negative"
+		}
+		if render.PageTimeoutSeconds < 0 {
+			fieldErrors["render.pageTimeoutSeconds"] = "should not be negative"
+		}
+		validateResourceTypes(render.BlockedResourceTypes, "render.blockedResourceTypes", fieldErrors)
+	}
+
+	if httpCache := cfg.HttpCache; httpCache != nil {
+		if httpCache.TtlSeconds < 0 {
+			fieldErrors["httpCache.ttlSeconds"] = "should not be negative"
+		}
+		if err := checkWritable(httpCache.Dir()); err != nil {
+			fieldErrors["httpCache.directory"] = err.Error()
+		}
+	}
+
+	if proxyPool := cfg.ProxyPool; proxyPool != nil {
+		validateProxies(proxyPool.Proxies, proxyPool.Strategy, "proxyPool", fieldErrors)
+		if u, err := url.Parse(proxyPool.HealthCheckUrl); proxyPool.HealthCheckUrl != "" && (err != nil || u.Host == "") {
+			fieldErrors["proxyPool.healthCheckUrl"] = "should be an absolute url"
+		}
+		nonNegative := map[string]int{
+			"proxyPool.healthCheckIntervalSeconds": proxyPool.HealthCheckIntervalSeconds,
+			"proxyPool.maxFailures":                proxyPool.MaxFailures,
+			"proxyPool.ejectSeconds":               proxyPool.EjectSeconds,
+		}
+		for path, value := range nonNegative {
+			if value < 0 {
+				fieldErrors[path] = "should not be negative"
+			}
+		}
+	}
+
+	for i, site := range cfg.WebSites {
+		path := fmt.Sprintf("webSites[%d]", i)
+		if !isRegistered(site.Name) {
+			warnings[path+".name"] = "no crawler registered for " + site.Name
+		}
+		if site.Render == base.RenderBrowser && (site.ProxySettings == nil || len(site.ProxySettings.Proxies) == 0) {
+			//the site renders the pages through the global proxies
+			if cfg.ProxyPool != nil && len(cfg.ProxyPool.Proxies) > 0 {
+				for j, proxy := range cfg.ProxyPool.Proxies {
+					if msg := checkBrowserProxy(proxy, site.Name); msg != "" {
+						fieldErrors[fmt.Sprintf("proxyPool.proxies[%d]", j)] = msg
+					}
+				}
+			} else if cfg.Http != nil {
+				if msg := checkBrowserProxy(cfg.Http.Proxy, site.Name); msg != "" {
+					fieldErrors["http.proxy"] = msg
+				}
+			}
+		}
+		if dir, ok := site.Attributes[base.AttributeDirectory]; ok {
+			if err := checkWritable(dir); err != nil {
+				fieldErrors[path+".attributes."+base.AttributeDirectory] = err.Error()
+			}
+		}
+	}
+	if len(fieldErrors) == 0 {
+		fieldErrors = nil
+	}
+	if len(warnings) == 0 {
+		warnings = nil
+	}
+	return fieldErrors, warnings
+}
+
+// checkWritable checks whether a file can be created in the directory, the nearest existing parent is
+// checked instead if it doesn't exist since it's created while crawling
+func checkWritable(dir string) error {
+	if dir == "" {
+		return errors.New("should not be empty")
+	}
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, os.ErrNotExist) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%v is not a directory", dir)
+		}
+		break
+	}
+	f, err := os.CreateTemp(dir, ".crawlers-*")
+	if err != nil {
+		return fmt.Errorf("%v is not writable: %w", dir, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+func checkDocument(value any, t reflect.Type, tag, path string, fieldErrors base.FieldErrors) {
+	if value == nil {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType || t == objectIdType:
+		checkType[string](value, "a string", path, fieldErrors)
+	case t.Kind() == reflect.Struct:
+		document, ok := value.(map[string]any)
+		if !ok {
+			fieldErrors[path] = "should be an object"
+			return
+		}
+		fields := fieldsByTag(t, tag)
+		for key, v := range document {
+			field, ok := fields[key]
+			if !ok {
+				fieldErrors[joinPath(path, key)] = "unknown key"
+				continue
+			}
+			checkDocument(v, field.Type, tag, joinPath(path, key), fieldErrors)
+		}
+	case t.Kind() == reflect.Map:
+		document, ok := value.(map[string]any)
+		if !ok {
+			fieldErrors[path] = "should be an object"
+			return
+		}
+		for key, v := range document {
+			checkDocument(v, t.Elem(), tag, joinPath(path, key), fieldErrors)
+		}
+	case t.Kind() == reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			fieldErrors[path] = "should be an array"
+			return
+		}
+		for i, item := range items {
+			checkDocument(item, t.Elem(), tag, fmt.Sprintf("%v[%d]", path, i), fieldErrors)
+		}
+	case t.Kind() == reflect.Bool:
+		checkType[bool](value, "a boolean", path, fieldErrors)
+	case t.Kind() == reflect.String:
+		// the yaml scalars are weakly converted into strings while unmarshalling, e.g. consumers: 3
+		if tag == "koanf" && isScalar(value) {
+			return
+		}
+		checkType[string](value, "a string", path, fieldErrors)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		if !isInteger(value) {
+			fieldErrors[path] = "should be an integer"
+		}
+	}
+}
+
+func checkType[T any](value any, name, path string, fieldErrors base.FieldErrors) {
+	if _, ok := value.(T); !ok {
+		fieldErrors[path] = "should be " + name
+	}
+}
+
+func isScalar(value any) bool {
+	switch value.(type) {
+	case string, bool, float64:
+		return true
+	}
+	return isInteger(value)
+}
+
+// isInteger the numbers of the json documents are decoded as float64
+func isInteger(value any) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	case float64:
+		return v == math.Trunc(v)
+	}
+	return false
+}
+
+// fieldsByTag the fields keyed by the name in the tag, the json name is used if the tag is missing
+func fieldsByTag(t reflect.Type, tag string) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "" {
+			name = jsonName(field)
+		}
+		if name != "-" {
+			fields[name] = field
+		}
+	}
+	return fields
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TaskPublisher publishes a task into the stream of its site
+type TaskPublisher func(ctx context.Context, task entity.Resource) error
+
+type TaskServiceInterface interface {
+	FindPage(ctx *gin.Context, taskType string, filter *dto.TaskFilter, query *dto.ListQuery) (*dto.PageResult, error)
+	Bulk(ctx *gin.Context, req *dto.BulkTaskRequest, publish TaskPublisher) (*dto.BulkTaskResult, error)
+}
+
+type taskServiceImpl struct{}
+
+func NewTaskService() TaskServiceInterface {
+	return &taskServiceImpl{}
+}
+
+func (t *taskServiceImpl) FindPage(ctx *gin.Context, taskType string, filter *dto.TaskFilter,
+	query *dto.ListQuery) (*dto.PageResult, error) {
+	return repository.TaskRepo.FindPage(ctx, taskType, filter, query)
+}
+
+// Bulk applies the action on the tasks matching the filter, only the matched tasks are counted for a dry run.
+// An empty filter is rejected to avoid touching all the tasks by mistake.
+func (t *taskServiceImpl) Bulk(ctx *gin.Context, req *dto.BulkTaskRequest,
+	publish TaskPublisher) (*dto.BulkTaskResult, error) {
+	if req.Filter.IsEmpty() {
+		return nil, &base.ParamError{Name: "filter"}
+	}
+	matched, err := repository.TaskRepo.Count(ctx, req.Type, &req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.BulkTaskResult{Type: req.Type, Action: req.Action, DryRun: req.DryRun, Matched: matched}
+	if req.DryRun || matched == 0 {
+		return result, nil
+	}
+
+	switch req.Action {
+	case base.TaskActionRequeue:
+		result.Affected, err = t.requeue(ctx, req, publish)
+	case base.TaskActionReset:
+		result.Affected, err = t.reset(ctx, req)
+	case base.TaskActionFinish:
+		result.Affected, err = repository.TaskRepo.UpdateStatus(ctx, req.Type, &req.Filter, base.TaskStatusFinished)
+	case base.TaskActionDelete:
+		result.Affected, err = repository.TaskRepo.Delete(ctx, req.Type, &req.Filter)
+	default:
+		return nil, &base.ParamError{Name: "action"}
+	}
+	return result, err
+}
+
+// reset sets the tasks to not started, the tasks cached by their urls are evicted as well, otherwise the stream
+// processor still finds them finished in the cache until it expires
+func (t *taskServiceImpl) reset(ctx *gin.Context, req *dto.BulkTaskRequest) (int64, error) {
+	urls, err := repository.TaskRepo.FindUrls(ctx, req.Type, &req.Filter)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := repository.TaskRepo.UpdateStatus(ctx, req.Type, &req.Filter, base.TaskStatusNotStared)
+	if err != nil {
+		return affected, err
+	}
+	evictCache(ctx, urls...)
+	return affected, nil
+}
+
+// requeue resets the tasks one by one before publishing them, so that a task isn't skipped as finished
+// by the stream processor, see reset
+func (t *taskServiceImpl) requeue(ctx *gin.Context, req *dto.BulkTaskRequest, publish TaskPublisher) (int64, error) {
+	ids, err := repository.TaskRepo.FindIds(ctx, req.Type, &req.Filter)
+	if err != nil {
+		return 0, err
+	}
+	var published int64
+	for _, id := range ids {
+		task, err := repository.TaskRepo.ResetById(ctx, req.Type, id)
+		if err != nil {
+			return published, err
+		}
+		if task == nil {
+			continue
+		}
+		evictCache(ctx, task.GetUrl())
+		if err = publish(ctx, task); err != nil {
+			return published, err
+		}
+		published++
+	}
+	zap.L().Info("tasks requeued", zap.String("type", req.Type), zap.Int64("published", published))
+	return published, nil
+}
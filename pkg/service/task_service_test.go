@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"crawlers/pkg/base"
+	"crawlers/pkg/model/dto"
+	"crawlers/pkg/model/entity"
+	"crawlers/pkg/repository"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jeven2016/mylibs/cache"
+	"github.com/jeven2016/mylibs/system"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// fakeTaskRepo records the calls changing the tasks
+type fakeTaskRepo struct {
+	ids     []primitive.ObjectID
+	updated []base.TaskStatus
+	deleted int
+	reset   []primitive.ObjectID
+}
+
+func (f *fakeTaskRepo) FindPage(context.Context, string, *dto.TaskFilter, *dto.ListQuery) (*dto.PageResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeTaskRepo) Count(context.Context, string, *dto.TaskFilter) (int64, error) {
+	return int64(len(f.ids)), nil
+}
+
+func (f *fakeTaskRepo) FindIds(context.Context, string, *dto.TaskFilter) ([]primitive.ObjectID, error) {
+	return f.ids, nil
+}
+
+func (f *fakeTaskRepo) FindUrls(context.Context, string, *dto.TaskFilter) ([]string, error) {
+	var urls []string
+	for _, id := range f.ids {
+		urls = append(urls, taskUrl(id))
+	}
+	return urls, nil
+}
+
+func (f *fakeTaskRepo) UpdateStatus(_ context.Context, _ string, _ *dto.TaskFilter, status base.TaskStatus) (int64, error) {
+	f.updated = append(f.updated, status)
+	return int64(len(f.ids)), nil
+}
+
+func (f *fakeTaskRepo) ResetById(_ context.Context, _ string, id primitive.ObjectID) (entity.Resource, error) {
+	f.reset = append(f.reset, id)
+	return &entity.NovelTask{Id: id, Url: taskUrl(id)}, nil
+}
+
+func (f *fakeTaskRepo) Delete(context.Context, string, *dto.TaskFilter) (int64, error) {
+	f.deleted++
+	return int64(len(f.ids)), nil
+}
+
+func useFakeTaskRepo(t *testing.T, ids ...primitive.ObjectID) *fakeTaskRepo {
+	fake := &fakeTaskRepo{ids: ids}
+	previous := repository.TaskRepo
+	repository.TaskRepo = fake
+	t.Cleanup(func() {
+		repository.TaskRepo = previous
+	})
+	return fake
+}
+
+func taskUrl(id primitive.ObjectID) string {
+	return "https://www.example.com/novel/" + id.Hex()
+}
+
+// useRedis the tasks are cached by their urls in the miniredis
+func useRedis(t *testing.T, ids ...primitive.ObjectID) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+	previous := system.GetSystem()
+	system.SetSystem(&system.System{
+		RedisClient: &cache.Redis{Client: redis.NewClient(&redis.Options{Addr: server.Addr()})},
+	})
+	t.Cleanup(func() {
+		system.SetSystem(previous)
+	})
+	for _, id := range ids {
+		if err := server.Set(taskUrl(id), "{}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server
+}
+
+func TestBulkRejectsEmptyFilter(t *testing.T) {
+	fake := useFakeTaskRepo(t, primitive.NewObjectID())
+	_, err := NewTaskService().Bulk(&gin.Context{}, &dto.BulkTaskRequest{Type: base.TaskTypeNovel,
+		Action: base.TaskActionDelete}, nil)
+	var paramErr *base.ParamError
+	if !errors.As(err, &paramErr) || paramErr.Name != "filter" || fake.deleted != 0 {
+		t.Errorf("the empty filter should be rejected, but the error is %v", err)
+	}
+}
+
+func TestBulkDryRun(t *testing.T) {
+	fake := useFakeTaskRepo(t, primitive.NewObjectID(), primitive.NewObjectID())
+	result, err := NewTaskService().Bulk(&gin.Context{}, &dto.BulkTaskRequest{Type: base.TaskTypeNovel,
+		Action: base.TaskActionDelete, DryRun: true, Filter: dto.TaskFilter{SiteName: "onej"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched != 2 || result.Affected != 0 || !result.DryRun || fake.deleted != 0 {
+		t.Errorf("only the matched tasks should be counted, result: %+v, deleted: %v", result, fake.deleted)
+	}
+}
+
+func TestBulkActions(t *testing.T) {
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	filter := dto.TaskFilter{Status: base.TaskStatusFailed}
+
+	fake := useFakeTaskRepo(t, ids...)
+	server := useRedis(t, ids...)
+	var published []entity.Resource
+	result, err := NewTaskService().Bulk(&gin.Context{}, &dto.BulkTaskRequest{Type: base.TaskTypeNovel,
+		Action: base.TaskActionRequeue, Filter: filter}, func(ctx context.Context, task entity.Resource) error {
+		published = append(published, task)
+		return nil
+	})
+	if err != nil || result.Affected != 2 || len(published) != 2 || len(fake.reset) != 2 || fake.reset[1] != ids[1] {
+		t.Errorf("the tasks should be reset and published, result: %+v, error: %v", result, err)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("the requeued tasks should be evicted from the cache, but %v are left", keys)
+	}
+
+	for action, status := range map[string]base.TaskStatus{base.TaskActionReset: base.TaskStatusNotStared,
+		base.TaskActionFinish: base.TaskStatusFinished} {
+		fake = useFakeTaskRepo(t, ids...)
+		server = useRedis(t, ids...)
+		result, err = NewTaskService().Bulk(&gin.Context{}, &dto.BulkTaskRequest{Type: base.TaskTypeNovel,
+			Action: action, Filter: filter}, nil)
+		if err != nil || result.Affected != 2 || len(fake.updated) != 1 || fake.updated[0] != status {
+			t.Errorf("the tasks should be set to %v, result: %+v, error: %v", status, result, err)
+		}
+		if keys := server.Keys(); action == base.TaskActionReset && len(keys) != 0 {
+			t.Errorf("the reset tasks should be evicted from the cache, but %v are left", keys)
+		}
+	}
+
+	fake = useFakeTaskRepo(t, ids...)
+	result, err = NewTaskService().Bulk(&gin.Context{}, &dto.BulkTaskRequest{Type: base.TaskTypeNovel,
+		Action: base.TaskActionDelete, Filter: filter}, nil)
+	if err != nil || result.Affected != 2 || fake.deleted != 1 {
+		t.Errorf("the tasks should be deleted, result: %+v, error: %v", result, err)
+	}
+}
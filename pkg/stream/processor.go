@@ -1,6 +1,7 @@
 package stream
 
 import (
+	"context"
 	"crawlers/pkg/base"
 	"crawlers/pkg/metrics"
 	"crawlers/pkg/model/entity"
@@ -8,9 +9,9 @@ import (
 	"crawlers/pkg/service"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/duke-git/lancet/v2/convertor"
 	"github.com/duke-git/lancet/v2/slice"
-	"github.com/fatih/structs"
 	"github.com/jeven2016/mylibs/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -21,7 +22,7 @@ import (
 )
 
 type TaskProcessor interface {
-	ParsePageUrls(siteName, originPageUrl string) ([]string, error)
+	ParsePageUrls(ctx context.Context, siteName, originPageUrl string) (*base.PageUrls, error)
 	HandleCatalogPageTask(jsonData string) []entity.NovelTask
 	HandleNovelTask(jsonData string) []entity.ChapterTask
 	HandleChapterTask(jsonData string) interface{}
@@ -33,18 +34,38 @@ func NewTaskProcessor() TaskProcessor {
 	return &DefaultTaskProcessor{}
 }
 
-// ParsePageUrls parses all page urls based on origin page url which could be combined by multiple pages
-func (d DefaultTaskProcessor) ParsePageUrls(siteName, originPageUrl string) ([]string, error) {
+// ParsePageUrls parses all page urls based on origin page url which could be combined by multiple pages,
+// see base.PageSpec. The last page is probed for "last N" if the crawler is a PageProber.
+func (d DefaultTaskProcessor) ParsePageUrls(ctx context.Context, siteName, originPageUrl string) (*base.PageUrls, error) {
 	cfg := service.ConfigService.GetSiteConfig(siteName)
 	if cfg == nil {
 		return nil, errors.New("Could not find site config: " + siteName)
 	}
-	if cfg.RegexSettings == nil || cfg.RegexSettings.ParsePageRegex == "" {
+	regex := cfg.RegexSettings
+	if regex != nil && regex.PageMode == base.PageModeNext {
+		if _, ok := GetSiteCrawler(siteName).(NextPageCrawler); !ok {
+			return nil, fmt.Errorf("the crawler of %v doesn't support the next page links", siteName)
+		}
+		return &base.PageUrls{Urls: []string{originPageUrl}, NextPages: base.NewNextPageChain(regex.MaxPages)}, nil
+	}
+	if regex == nil || regex.ParsePageRegex == "" {
 		zap.L().Info("no RegexSettings setting defined, just return origin url", zap.String("siteName", siteName),
 			zap.String("url", originPageUrl))
-		return []string{originPageUrl}, nil
+		return &base.PageUrls{Urls: []string{originPageUrl}}, nil
+	}
+
+	opts := &base.PageOptions{
+		Prefix:   regex.PagePrefix,
+		Suffix:   regex.PageSuffix,
+		PageSize: regex.PageSize,
+		MaxPages: regex.MaxPages,
+	}
+	if prober, ok := GetSiteCrawler(siteName).(PageProber); ok {
+		opts.HasItems = func(url string) (bool, error) {
+			return prober.HasItems(ctx, url)
+		}
 	}
-	return base.GenPageUrls(cfg.RegexSettings.ParsePageRegex, originPageUrl, cfg.RegexSettings.PagePrefix, "")
+	return base.ExpandPageUrl(regex.ParsePageRegex, originPageUrl, opts)
 }
 
 // HandleCatalogPageTask handles an individual catalog page to get a list of novel pages for further processing
@@ -74,8 +95,9 @@ func (d DefaultTaskProcessor) HandleCatalogPageTask(jsonData string) (novelMsgs
 	cfg := service.ConfigService.GetSiteConfig(catalogPageTask.SiteName)
 
 	//check if to skip specific operations
-	var skipIfPresent = getSettingValue[bool](cfg, "CatalogPage", "skipIfPresent", true)
-	var skipSaveIfPresent = getSettingValue[bool](cfg, "CatalogPage", "skipSaveIfPresent", true)
+	stage := cfg.Stages().CatalogPage
+	var skipIfPresent = stage.ShouldSkipIfPresent()
+	var skipSaveIfPresent = stage.ShouldSkipSaveIfPresent()
 
 	//check if page url is duplicated
 	exists, err := isDuplicatedTask(&entity.CatalogPageTask{},
@@ -108,7 +130,13 @@ func (d DefaultTaskProcessor) HandleCatalogPageTask(jsonData string) (novelMsgs
 		return nil
 	}
 
-	if novelMsgs, err = crawler.CrawlCatalogPage(base.GetSystemContext(), &catalogPageTask); err != nil {
+	var nextUrl string
+	if nextPageCrawler, ok := crawler.(NextPageCrawler); ok && catalogPageTask.NextPages != nil {
+		novelMsgs, nextUrl, err = nextPageCrawler.CrawlCatalogPageWithNext(base.GetSystemContext(), &catalogPageTask)
+	} else {
+		novelMsgs, err = crawler.CrawlCatalogPage(base.GetSystemContext(), &catalogPageTask)
+	}
+	if err != nil {
 		zap.L().Warn("CrawlCatalogPage error", zap.String("catalogUrl", catalogPageTask.Url), zap.Error(err))
 
 		//save failed, update the status
@@ -120,6 +148,18 @@ func (d DefaultTaskProcessor) HandleCatalogPageTask(jsonData string) (novelMsgs
 		}
 	}
 	updateTaskStatus(&catalogPageTask, existingTask != nil, err == nil)
+	if err == nil {
+		continuePages(&catalogPageTask, len(novelMsgs))
+		followNextPage(&catalogPageTask, nextUrl)
+	}
+
+	//the novels belong to the catalog of the page and are downloaded now if the page is downloaded now
+	for i := 0; i < len(novelMsgs); i++ {
+		if novelMsgs[i].CatalogId.IsZero() {
+			novelMsgs[i].CatalogId = catalogPageTask.CatalogId
+		}
+		novelMsgs[i].DownloadNow = novelMsgs[i].DownloadNow || catalogPageTask.DownloadNow
+	}
 
 	if c, ok := catalogPageTask.Attributes["onlyCoverImage"]; ok {
 		for i := 0; i < len(novelMsgs); i++ {
@@ -142,6 +182,65 @@ func (d DefaultTaskProcessor) HandleCatalogPageTask(jsonData string) (novelMsgs
 	return
 }
 
+// continuePages publishes the task of the next page for an open-ended page range, it stops once a page has
+// no items or the max pages are reached
+func continuePages(catalogPageTask *entity.CatalogPageTask, count int) {
+	if catalogPageTask.Continuation == nil {
+		return
+	}
+	if count == 0 {
+		zap.L().Info("the open-ended page range ends with an empty page", zap.String("url", catalogPageTask.Url))
+		return
+	}
+	nextUrl, continuation := catalogPageTask.Continuation.Next()
+	if continuation == nil {
+		zap.L().Warn("the open-ended page range stops at the max pages", zap.String("url", catalogPageTask.Url))
+		return
+	}
+	nextTask := &entity.CatalogPageTask{
+		CatalogId:    catalogPageTask.CatalogId,
+		Url:          nextUrl,
+		Attributes:   catalogPageTask.Attributes,
+		Status:       base.TaskStatusNotStared,
+		SiteName:     catalogPageTask.SiteName,
+		DownloadNow:  catalogPageTask.DownloadNow,
+		Continuation: continuation,
+	}
+	if err := PublishTask(base.GetSystemContext(), nextTask); err != nil {
+		zap.L().Error("failed to publish the next catalog page task", zap.String("url", nextUrl), zap.Error(err))
+	}
+}
+
+// followNextPage publishes the task of the next page returned by the crawler, it stops once there's no next
+// page, the max pages are reached or the next page is crawled already in the chain
+func followNextPage(catalogPageTask *entity.CatalogPageTask, nextUrl string) {
+	if catalogPageTask.NextPages == nil {
+		return
+	}
+	if nextUrl == "" {
+		zap.L().Info("no next page found", zap.String("url", catalogPageTask.Url),
+			zap.Int("pages", catalogPageTask.NextPages.Pages))
+		return
+	}
+	nextPages, err := catalogPageTask.NextPages.Next(catalogPageTask.Url, nextUrl)
+	if err != nil {
+		zap.L().Warn("stop following the next page", zap.String("url", catalogPageTask.Url), zap.Error(err))
+		return
+	}
+	nextTask := &entity.CatalogPageTask{
+		CatalogId:   catalogPageTask.CatalogId,
+		Url:         nextUrl,
+		Attributes:  catalogPageTask.Attributes,
+		Status:      base.TaskStatusNotStared,
+		SiteName:    catalogPageTask.SiteName,
+		DownloadNow: catalogPageTask.DownloadNow,
+		NextPages:   nextPages,
+	}
+	if err = PublishTask(base.GetSystemContext(), nextTask); err != nil {
+		zap.L().Error("failed to publish the next catalog page task", zap.String("url", nextUrl), zap.Error(err))
+	}
+}
+
 func (d DefaultTaskProcessor) HandleNovelTask(jsonData string) (chapterMessages []entity.ChapterTask) {
 	var novelTask entity.NovelTask
 	var err error
@@ -171,9 +270,10 @@ func (d DefaultTaskProcessor) HandleNovelTask(jsonData string) (chapterMessages
 	cfg := service.ConfigService.GetSiteConfig(novelTask.SiteName)
 
 	//whether to skip specific operations
-	var skipIfPresent = getSettingValue[bool](cfg, "Novel", "skipIfPresent", true)
-	var skipSaveIfPresent = getSettingValue[bool](cfg, "Novel", "skipIfPresent", true)
-	var enableChapter = getSettingValue[bool](cfg, "Novel", "enabled", true)
+	stage := cfg.Stages().Novel
+	var skipIfPresent = stage.ShouldSkipIfPresent()
+	var skipSaveIfPresent = stage.ShouldSkipSaveIfPresent()
+	var enableChapter = stage.IsEnabled()
 
 	//check if page url is duplicated
 	exists, err := isDuplicatedTask(&entity.NovelTask{},
@@ -240,7 +340,10 @@ func (d DefaultTaskProcessor) HandleNovelTask(jsonData string) (chapterMessages
 			chapterMessages = nil
 		}
 	} else {
+		//deferred, it's started later via the api with DownloadNow=true
+		currentTime := time.Now()
 		novelTask.Status = base.TaskStatusNotStared
+		novelTask.CreatedDate = &currentTime
 	}
 
 	if !exists || !skipSaveIfPresent {
@@ -277,9 +380,10 @@ func (d DefaultTaskProcessor) HandleChapterTask(jsonData string) interface{} {
 	cfg := service.ConfigService.GetSiteConfig(chapterTask.SiteName)
 
 	//whether to skip specific operations
-	var skipIfPresent = getSettingValue[bool](cfg, "Chapter", "skipIfPresent", true)
-	var skipSaveIfPresent = getSettingValue[bool](cfg, "Chapter", "skipSaveIfPresent", true)
-	var enableChapter = getSettingValue[bool](cfg, "Chapter", "enabled", true)
+	stage := cfg.Stages().Chapter
+	var skipIfPresent = stage.ShouldSkipIfPresent()
+	var skipSaveIfPresent = stage.ShouldSkipSaveIfPresent()
+	var enableChapter = stage.IsEnabled()
 
 	//check if page url is duplicated
 	exists, err := isDuplicatedTask(&entity.ChapterTask{},
@@ -392,28 +496,6 @@ func isDuplicatedTask[T any](task *T, collectionName,
 	return false, err
 }
 
-func getSettingValue[T any](cfg *entity.SiteSettings, mapField, mapKey string, defaultValue T) T {
-	if cfg != nil && cfg.CrawlerSettings != nil {
-		s := structs.New(cfg.CrawlerSettings)
-
-		if field, ok := s.FieldOk(mapField); ok {
-			kind := field.Kind()
-			if kind == reflect.Struct {
-				if valueField, ok := field.FieldOk(mapKey); ok {
-					return valueField.Value().(T)
-				}
-			}
-			if kind == reflect.Map {
-				m := field.Value().(map[string]any)
-				if v, ok := m[mapKey]; ok {
-					return v.(T)
-				}
-			}
-		}
-	}
-	return defaultValue
-}
-
 // update the value of fields of task
 func updateTaskStatus[T any](task *T, taskExists bool, succeed bool) {
 	t := reflect.ValueOf(task)
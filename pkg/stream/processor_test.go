@@ -1,8 +1,13 @@
 package stream
 
 import (
+	"context"
 	"crawlers/pkg/base"
+	"crawlers/pkg/extension/sites/sitetest"
 	"crawlers/pkg/model/entity"
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -87,3 +92,58 @@ func TestUpdateTaskStatusFour(t *testing.T) {
 		t.Error("LastUpdated shouldn't be set")
 	}
 }
+
+const probeConfig = `
+webSites:
+  - name: probe-site
+    regexSettings:
+      parsePageRegex: page=([^\&]+)
+      pagePrefix: "page="
+`
+
+// fakeCrawler fails the catalog pages, they shouldn't be crawled to probe the last page
+type fakeCrawler struct {
+	SiteCrawler
+}
+
+func (fakeCrawler) CrawlCatalogPage(context.Context, *entity.CatalogPageTask) ([]entity.NovelTask, error) {
+	return nil, errors.New("the catalog page shouldn't be crawled")
+}
+
+// fakeProber the pages up to the last one have items
+type fakeProber struct {
+	fakeCrawler
+	lastPage int
+}
+
+func (f fakeProber) HasItems(_ context.Context, url string) (bool, error) {
+	var page int
+	_, err := fmt.Sscanf(url, "https://abc.com/list?page=%d", &page)
+	return page <= f.lastPage, err
+}
+
+func TestParsePageUrlsLastPages(t *testing.T) {
+	sitetest.LoadConfig(t, probeConfig)
+	t.Cleanup(func() {
+		delete(siteCrawlerMap, "probe-site")
+	})
+	processor := NewTaskProcessor()
+	url := "https://abc.com/list?page=last2"
+
+	siteCrawlerMap["probe-site"] = fakeCrawler{}
+	_, err := processor.ParsePageUrls(context.Background(), "probe-site", url)
+	var paramErr *base.ParamError
+	if !errors.As(err, &paramErr) {
+		t.Errorf("last N should be rejected if the crawler can't probe the pages, but the error is %v", err)
+	}
+
+	siteCrawlerMap["probe-site"] = fakeProber{lastPage: 7}
+	pageUrls, err := processor.ParsePageUrls(context.Background(), "probe-site", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"https://abc.com/list?page=6", "https://abc.com/list?page=7"}
+	if !reflect.DeepEqual(pageUrls.Urls, expected) {
+		t.Errorf("the last pages should be %v, but they're %v", expected, pageUrls.Urls)
+	}
+}
@@ -3,9 +3,9 @@ package stream
 import (
 	"context"
 	"fmt"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/reugn/go-streams/flow"
-	"log"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -20,141 +20,85 @@ const redisStream = "sourceStream"
 const consumersGroup = "group"
 
 func TestRedisStream(t *testing.T) {
+	client := getClient(t)
+	ctx := context.Background()
 
-	//send
-	sendMsg()
-	//
-	//if receiveMsg() {
-	//	return
-	//}
-
-	select {}
-}
-
-func receiveMsg() bool {
-	//--------------------------------------------------------------------------------------------
-	client := getClient()
-	defer client.Close()
-
-	//创建一个消费group, 不同消费相同的消息
-	groups, err := client.XInfoGroups(context.Background(), redisStream).Result()
-	if err != nil {
-		log.Println(err)
-		return true
+	//创建一个消费group, 同一个group中的消费者分摊消息
+	if err := client.XGroupCreateMkStream(ctx, redisStream, consumersGroup, "0").Err(); err != nil {
+		t.Fatal(err)
 	}
+	send(t, client, 6)
 
-	var groupExists bool
-	for _, g := range groups {
-		if g.Name == consumersGroup {
-			println("group exists")
-			groupExists = true
-			break
+	received := map[string]bool{}
+	for _, consumer := range []string{"consumer1", "consumer2", "consumer3"} {
+		names := read(t, client, consumer)
+		if len(names) != 2 {
+			t.Errorf("%v should receive 2 messages, but it receives %v", consumer, names)
 		}
-	}
-
-	if !groupExists {
-		err = client.XGroupCreate(context.Background(), redisStream, consumersGroup, "0").Err()
-		if err != nil {
-			log.Println(err)
+		for _, name := range names {
+			received[name] = true
 		}
 	}
-	go func() {
-		client2 := getClient()
-		defer client2.Close()
-		read("thr1: ", client2)
-	}()
-
-	go func() {
-		client3 := getClient()
-		defer client3.Close()
-		read("thr3: ", client3)
-	}()
-
-	go func() {
-		client4 := getClient()
-		defer client4.Close()
-		read("thr4: ", client4)
-	}()
-	return false
-}
-
-func sendMsg() {
-	go func() {
-		client := getClient()
-		defer client.Close()
-
-		send(client)
-
-	}()
-}
-
-func send(client *redis.Client) {
-	tick := time.Tick(2 * time.Second)
-	var i int
-	for {
-		if i > 5 {
-			return
-		}
-		select {
-		case <-tick:
-			err := client.XAdd(context.Background(), &redis.XAddArgs{Stream: redisStream,
-				NoMkStream: false,  // * 默认false,当为false时,key不存在，会新建
-				MaxLen:     100000, // * 指定stream的最大长度,当队列长度超过上限后，旧消息会被删除，只保留固定长度的新消息
-				Approx:     false,  // * 默认false,当为true时,模糊指定stream的长度
-				ID:         "*",    // 消息 id，我们使用 * 表示由 redis 生成
-				// MinID: "id",            // * 超过阈值，丢弃设置的小于MinID消息id【基本不用】
-				// Limit: 1000,            // * 限制长度【基本不用】
-				Values: map[string]string{
-					"name": "wang" + strconv.Itoa(i),
-				}}).Err()
-			if err != nil {
-				log.Println(err)
-				return
-			}
-			println("send a message" + strconv.Itoa(i))
-			i++
-		}
+	if len(received) != 6 {
+		t.Errorf("all the messages should be received once, but they're %v", received)
+	}
 
+	pending, err := client.XPending(ctx, redisStream, consumersGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("all the messages should be acknowledged, but %v are pending", pending.Count)
 	}
 }
 
-func read(prefix string, client *redis.Client) {
-	for {
-		entries, err := client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
-			Group:    consumersGroup,
-			Consumer: "abc2",
-
-			Streams: []string{redisStream, ">"},
-			Count:   2,
-			Block:   0,
-			//NoAck:   false,
-		}).Result()
+func send(t *testing.T, client *redis.Client, count int) {
+	for i := 0; i < count; i++ {
+		err := client.XAdd(context.Background(), &redis.XAddArgs{Stream: redisStream,
+			NoMkStream: false,  // * 默认false,当为false时,key不存在，会新建
+			MaxLen:     100000, // * 指定stream的最大长度,当队列长度超过上限后，旧消息会被删除，只保留固定长度的新消息
+			Approx:     false,  // * 默认false,当为true时,模糊指定stream的长度
+			ID:         "*",    // 消息 id，我们使用 * 表示由 redis 生成
+			Values: map[string]string{
+				"name": "wang" + strconv.Itoa(i),
+			}}).Err()
 		if err != nil {
-			println(err.Error())
-			return
+			t.Fatal(err)
 		}
-		for i := 0; i < len(entries[0].Messages); i++ {
-			messageID := entries[0].Messages[i].ID
-			values := entries[0].Messages[i].Values
-			name := fmt.Sprintf("%v", values["name"])
-			println(prefix + " " + name)
-			client.XAck(context.Background(), "redisStream", "consumer", messageID)
+	}
+}
 
+// read reads two messages for the consumer and acknowledges them
+func read(t *testing.T, client *redis.Client, consumer string) []string {
+	entries, err := client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    consumersGroup,
+		Consumer: consumer,
+		Streams:  []string{redisStream, ">"},
+		Count:    2,
+		Block:    -1, //不阻塞
+	}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, message := range entries[0].Messages {
+		names = append(names, fmt.Sprintf("%v", message.Values["name"]))
+		if err = client.XAck(context.Background(), redisStream, consumersGroup, message.ID).Err(); err != nil {
+			t.Fatal(err)
 		}
 	}
+	return names
 }
 
-func getClient() (client *redis.Client) {
-	client = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "pwd", // no password set
-		DB:       0,     // use default DB
-		PoolSize: 3,     // 默认一个CPU 10个连接
+// getClient the client of an in-memory redis, which is closed once the test finishes
+func getClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     miniredis.RunT(t).Addr(),
+		PoolSize: 3, // 默认一个CPU 10个连接
+	})
+	t.Cleanup(func() {
+		_ = client.Close()
 	})
-	_, err := client.Ping(context.Background()).Result()
-	if err != nil {
-		log.Fatal("Unable to connect to Redis", err)
-	}
 	return client
 }
 
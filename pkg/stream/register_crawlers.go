@@ -19,6 +19,18 @@ type SiteCrawler interface {
 	CrawlChapterPage(ctx context.Context, chapterMsg *entity.ChapterTask, skipSaveIfPresent bool) error
 }
 
+// NextPageCrawler is implemented by the crawlers of the sites whose catalog pages are paged by the next page
+// links, see base.PageModeNext. The next url is empty if it's the last page.
+type NextPageCrawler interface {
+	CrawlCatalogPageWithNext(ctx context.Context, catalogPageMsg *entity.CatalogPageTask) ([]entity.NovelTask, string, error)
+}
+
+// PageProber is implemented by the crawlers able to check whether a catalog page has any items without side
+// effects, e.g. the urls marked as handled, it's required to probe the last page for "last N"
+type PageProber interface {
+	HasItems(ctx context.Context, url string) (bool, error)
+}
+
 // customized processors should be registered
 var siteCrawlerMap = make(map[string]SiteCrawler)
 var siteTaskProcessorMap = make(map[string]TaskProcessor)
@@ -0,0 +1,25 @@
+package stream
+
+import (
+	"context"
+	"crawlers/pkg/model/entity"
+	"fmt"
+	"github.com/jeven2016/mylibs/system"
+)
+
+// PublishTask publishes a task into the stream of its type, the separated stream is used if the site
+// is configured with a separate space
+func PublishTask(ctx context.Context, task entity.Resource) error {
+	var streamName string
+	switch t := task.(type) {
+	case *entity.CatalogPageTask:
+		streamName = GenStreamTaskParams(t.SiteName).CatalogPageStreamName
+	case *entity.NovelTask:
+		streamName = GenStreamTaskParams(t.SiteName).NovelPageStreamName
+	case *entity.ChapterTask:
+		streamName = GenStreamTaskParams(t.SiteName).ChapterPageStreamName
+	default:
+		return fmt.Errorf("unsupported task %T", task)
+	}
+	return system.GetSystem().RedisClient.PublishMessage(ctx, task, streamName)
+}